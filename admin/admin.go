@@ -0,0 +1,236 @@
+// Package admin serves the HTTP API operators use to inspect and steer a running ingester.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.uber.org/zap"
+)
+
+// Server is the admin API:
+//
+//	GET  /assignment                                        the assigned partitions and their positions
+//	POST /pause?topic=T[&partition=P]                       stop consuming the topic or one of its partitions
+//	POST /resume?topic=T[&partition=P]                      the inverse of pause
+//	POST /seek?topic=T&partition=P&offset=O|timestamp=TS    move a partition to an offset or a time
+//	GET  /config                                            the effective configuration, without the secrets
+//
+// The timestamp is RFC 3339 or milliseconds since the epoch. Every request must
+// carry the admin token of the current configuration as a bearer token.
+type Server struct {
+	controller receiver.Controller
+	config     atomic.Value
+	server     *http.Server
+	listener   net.Listener
+	sugar      *zap.SugaredLogger
+}
+
+// NewServer creates the admin API of the configuration, Start must be called to serve it.
+func NewServer(config *configure.Configuration, controller receiver.Controller, sugar *zap.SugaredLogger) *Server {
+	s := &Server{controller: controller, sugar: sugar}
+	s.config.Store(config)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/assignment", s.handle(http.MethodGet, s.assignment))
+	mux.HandleFunc("/pause", s.handle(http.MethodPost, s.pause))
+	mux.HandleFunc("/resume", s.handle(http.MethodPost, s.resume))
+	mux.HandleFunc("/seek", s.handle(http.MethodPost, s.seek))
+	mux.HandleFunc("/config", s.handle(http.MethodGet, s.effectiveConfig))
+	s.server = &http.Server{Addr: config.AdminAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// SetConfig replaces the configuration shown by the API and its token, it is called on reload.
+func (s *Server) SetConfig(config *configure.Configuration) {
+	s.config.Store(config)
+}
+
+// Start listens on the admin address and serves the API in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.sugar.Warnw("The admin API stopped.", "exception", err)
+		}
+	}()
+	s.sugar.Infow("Serving the admin API.", "addr", listener.Addr().String())
+	return nil
+}
+
+// Addr is the address the API listens on.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.server.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops serving the API.
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = s.server.Shutdown(ctx)
+}
+
+func (s *Server) currentConfig() *configure.Configuration {
+	return s.config.Load().(*configure.Configuration)
+}
+
+type handlerFunc func(r *http.Request) (interface{}, error)
+
+// badRequest is an error caused by the parameters of the request.
+type badRequest struct {
+	error
+}
+
+func (s *Server) handle(method string, handler handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="zipkin-ingester"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		result, err := handler(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.As(err, &badRequest{}):
+				status = http.StatusBadRequest
+			case errors.Is(err, receiver.ErrNotAssigned):
+				status = http.StatusNotFound
+			case errors.Is(err, receiver.ErrNotControllable):
+				status = http.StatusNotImplemented
+			}
+			s.sugar.Warnw("Admin request failed.", "path", r.URL.Path, "query", r.URL.RawQuery, "exception", err)
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.sugar.Infow("Admin request.", "path", r.URL.Path, "query", r.URL.RawQuery, "remote", r.RemoteAddr)
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := s.currentConfig().AdminToken
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if token == "" || !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(token)) == 1
+}
+
+func (s *Server) assignment(r *http.Request) (interface{}, error) {
+	return s.controller.Assignment()
+}
+
+func (s *Server) pause(r *http.Request) (interface{}, error) {
+	topic, partition, err := topicPartition(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.controller.Pause(topic, partition)
+}
+
+func (s *Server) resume(r *http.Request) (interface{}, error) {
+	topic, partition, err := topicPartition(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.controller.Resume(topic, partition)
+}
+
+func (s *Server) seek(r *http.Request) (interface{}, error) {
+	topic, partition, err := topicPartition(r, true)
+	if err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	offsetParam, timestampParam := query.Get("offset"), query.Get("timestamp")
+	if (offsetParam == "") == (timestampParam == "") {
+		return nil, badRequest{errors.New("exactly one of offset and timestamp is required")}
+	}
+
+	var offset int64
+	if offsetParam != "" {
+		if offset, err = strconv.ParseInt(offsetParam, 10, 64); err != nil || offset < 0 {
+			return nil, badRequest{fmt.Errorf("invalid offset %q", offsetParam)}
+		}
+		err = s.controller.Seek(topic, *partition, offset)
+	} else {
+		timestamp, parseErr := parseTimestamp(timestampParam)
+		if parseErr != nil {
+			return nil, badRequest{parseErr}
+		}
+		offset, err = s.controller.SeekTimestamp(topic, *partition, timestamp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"topic": topic, "partition": *partition, "offset": offset}, nil
+}
+
+func (s *Server) effectiveConfig(r *http.Request) (interface{}, error) {
+	return s.currentConfig().Redacted(), nil
+}
+
+func topicPartition(r *http.Request, partitionRequired bool) (string, *int32, error) {
+	query := r.URL.Query()
+	topic := query.Get("topic")
+	if topic == "" {
+		return "", nil, badRequest{errors.New("the topic is required")}
+	}
+	value := query.Get("partition")
+	if value == "" {
+		if partitionRequired {
+			return "", nil, badRequest{errors.New("the partition is required")}
+		}
+		return topic, nil, nil
+	}
+	partition, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || partition < 0 {
+		return "", nil, badRequest{fmt.Errorf("invalid partition %q", value)}
+	}
+	p := int32(partition)
+	return topic, &p, nil
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(0, millis*int64(time.Millisecond)), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, expected RFC 3339 or milliseconds since the epoch", value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
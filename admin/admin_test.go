@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.uber.org/zap"
+)
+
+type fakeController struct {
+	paused    map[int32]bool
+	seeked    int64
+	seekedAt  time.Time
+	partition int32
+}
+
+func (f *fakeController) Assignment() ([]receiver.PartitionState, error) {
+	return []receiver.PartitionState{{Topic: "zipkin", Partition: 0, Position: 10, Committed: 8, HighWatermark: 15, Lag: 5, Paused: f.paused[0]}}, nil
+}
+
+func (f *fakeController) Pause(topic string, partition *int32) ([]receiver.PartitionState, error) {
+	f.paused[*partition] = true
+	return []receiver.PartitionState{{Topic: topic, Partition: *partition, Paused: true}}, nil
+}
+
+func (f *fakeController) Resume(topic string, partition *int32) ([]receiver.PartitionState, error) {
+	delete(f.paused, *partition)
+	return []receiver.PartitionState{{Topic: topic, Partition: *partition}}, nil
+}
+
+func (f *fakeController) Seek(topic string, partition int32, offset int64) error {
+	if topic != "zipkin" {
+		return fmt.Errorf("the partition %s/%d %w", topic, partition, receiver.ErrNotAssigned)
+	}
+	f.partition, f.seeked = partition, offset
+	return nil
+}
+
+func (f *fakeController) SeekTimestamp(topic string, partition int32, timestamp time.Time) (int64, error) {
+	f.partition, f.seekedAt, f.seeked = partition, timestamp, 77
+	return 77, nil
+}
+
+func newTestServer() (*Server, *fakeController) {
+	controller := &fakeController{paused: make(map[int32]bool)}
+	config := &configure.Configuration{
+		AdminAddr:    "127.0.0.1:0",
+		AdminToken:   "s3cret-token",
+		AccessKey:    "LTAI5tAccessKey",
+		AccessSecret: "AccessSecretValue",
+		Routes:       []configure.Route{{Name: "team", AccessKey: "LTAIroute", AccessSecret: "routeSecret"}},
+		Sources:      []configure.Source{{Name: "secure", SaslUsername: "ingester", SaslPassword: "saslSecret"}},
+	}
+	return NewServer(config, controller, zap.NewNop().Sugar()), controller
+}
+
+func request(s *Server, method, target, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthentication(t *testing.T) {
+	s, _ := newTestServer()
+	for _, token := range []string{"", "wrong"} {
+		if w := request(s, http.MethodGet, "/assignment", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 with token %q, got %d", token, w.Code)
+		}
+	}
+	if w := request(s, http.MethodGet, "/assignment", "s3cret-token"); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	// the token follows the reloaded configuration
+	s.SetConfig(&configure.Configuration{AdminToken: "rotated"})
+	if w := request(s, http.MethodGet, "/assignment", "s3cret-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the old token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestPauseResumeAndSeek(t *testing.T) {
+	s, controller := newTestServer()
+	if w := request(s, http.MethodGet, "/pause?topic=zipkin&partition=0", "s3cret-token"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+	if w := request(s, http.MethodPost, "/pause?topic=zipkin&partition=0", "s3cret-token"); w.Code != http.StatusOK || !controller.paused[0] {
+		t.Fatalf("the partition is not paused: %d %s", w.Code, w.Body)
+	}
+
+	var states []receiver.PartitionState
+	w := request(s, http.MethodGet, "/assignment", "s3cret-token")
+	if err := json.Unmarshal(w.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || !states[0].Paused || states[0].Lag != 5 {
+		t.Errorf("unexpected assignment %+v", states)
+	}
+
+	if w := request(s, http.MethodPost, "/resume?topic=zipkin&partition=0", "s3cret-token"); w.Code != http.StatusOK || controller.paused[0] {
+		t.Errorf("the partition is not resumed: %d %s", w.Code, w.Body)
+	}
+
+	if w := request(s, http.MethodPost, "/seek?topic=zipkin&partition=2&offset=1234", "s3cret-token"); w.Code != http.StatusOK || controller.partition != 2 || controller.seeked != 1234 {
+		t.Errorf("unexpected seek: %d %s", w.Code, w.Body)
+	}
+	if w := request(s, http.MethodPost, "/seek?topic=zipkin&partition=1&timestamp=2021-08-01T00:00:00Z", "s3cret-token"); w.Code != http.StatusOK ||
+		controller.seekedAt.Unix() != 1627776000 || !strings.Contains(w.Body.String(), `"offset":77`) {
+		t.Errorf("unexpected seek to time: %d %s", w.Code, w.Body)
+	}
+
+	for _, target := range []string{
+		"/seek?topic=zipkin&offset=1",
+		"/seek?topic=zipkin&partition=1",
+		"/seek?topic=zipkin&partition=1&offset=1&timestamp=1627776000000",
+		"/seek?topic=zipkin&partition=x&offset=1",
+		"/pause?partition=1",
+	} {
+		if w := request(s, http.MethodPost, target, "s3cret-token"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", target, w.Code)
+		}
+	}
+	if w := request(s, http.MethodPost, "/seek?topic=other&partition=0&offset=1", "s3cret-token"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a partition not assigned, got %d", w.Code)
+	}
+}
+
+func TestConfigIsRedacted(t *testing.T) {
+	s, _ := newTestServer()
+	w := request(s, http.MethodGet, "/config", "s3cret-token")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, secret := range []string{"AccessSecretValue", "s3cret-token", "routeSecret", "LTAI5tAccessKey", "saslSecret"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("the config leaks %s: %s", secret, body)
+		}
+	}
+	if !strings.Contains(body, `"AdminAddr":"127.0.0.1:0"`) {
+		t.Errorf("unexpected config %s", body)
+	}
+}
@@ -1,12 +1,30 @@
 package configure
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/credentials"
+	"github.com/aliyun-sls/zipkin-ingester/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
 
 type Configuration struct {
 	BootstrapServers string
 	GroupID          string
 	AutoOffsetRest   string
 	Topic            []string
+	// Assignor is the partition assignment strategy of the consumer group, e.g. cooperative-sticky.
+	Assignor string
+
+	// The security settings of the kafka consumer.
+	SecurityProtocol string
+	SaslMechanism    string
+	SaslUsername     string
+	SaslPassword     string
+	SslCaLocation    string
 
 	Project      string
 	Instance     string
@@ -14,12 +32,363 @@ type Configuration struct {
 	AccessSecret string
 	Endpoint     string
 	Protocol     string
+	Logstore     string
+
+	CredentialProvider string
+	StsTokenFile       string
+	RamRole            string
+	MetadataEndpoint   string
+	// Credentials is the shared provider created from the fields above, see CredentialsProvider.
+	Credentials credentials.Provider
+
+	ProvisionLogstore bool
+	LogstoreTTL       int
+	LogstoreShards    int
+
+	TimestampPolicy string
+
+	Exporters      []string
+	ZipkinURL      string
+	ZipkinEncoding string
+	ZipkinTimeout  time.Duration
+
+	SpanMetricsEnabled    bool
+	SpanMetricsStore      string
+	SpanMetricsInterval   time.Duration
+	SpanMetricsDimensions []string
+	SpanMetricsMaxSeries  int
+	SelfMetricsEnabled    bool
+
+	// The self tracing sends the traces of the ingester's own pipeline to the OTLP
+	// SelfTracingEndpoint, or to the trace instance if it is empty. SelfTracingSampling
+	// is the ratio of the sampled messages.
+	SelfTracingEnabled  bool
+	SelfTracingEndpoint string
+	SelfTracingInsecure bool
+	SelfTracingService  string
+	SelfTracingSampling float64
+
+	ClockSkewEnabled   bool
+	ClockSkewWindow    time.Duration
+	ClockSkewMaxTraces int
+
+	TraceSummaryEnabled   bool
+	TraceSummaryStore     string
+	TraceSummaryWindow    time.Duration
+	TraceSummaryMaxTraces int
+
+	DedupEnabled    bool
+	DedupWindow     time.Duration
+	DedupMaxEntries int
+
+	// The span size limits, zero means unlimited.
+	SpanMaxTagCount       int
+	SpanMaxTagValueLength int
+	SpanMaxAnnotations    int
+	SpanMaxSize           int
+
+	// The rate limits are per second, zero means unlimited. RateLimitServices
+	// overrides the per service limits for some services.
+	RateLimitSpans        float64
+	RateLimitBytes        float64
+	RateLimitServiceSpans float64
+	RateLimitServiceBytes float64
+	RateLimitServices     map[string]ServiceRateLimit
+	RateLimitAction       string
+
+	LogLevel string
+	// LogFormat is json or console. The logs go to LogFile, rotated at LogMaxSize
+	// megabytes, instead of stdout when it is set. Past LogSampleInitial entries
+	// with the same level and message in a second only every LogSampleThereafter-th
+	// one is logged.
+	LogFormat           string
+	LogFile             string
+	LogMaxSize          int
+	LogMaxBackups       int
+	LogMaxAge           int
+	LogSampleInitial    int
+	LogSampleThereafter int
+	// ReloadOnChange reloads the configuration file when it changes, in addition to SIGHUP.
+	ReloadOnChange bool
+
+	// ShutdownTimeout bounds how long the exporters flush the pending spans at shutdown.
+	ShutdownTimeout time.Duration
+
+	// AdminAddr is the listen address of the admin API, it is disabled if empty.
+	// The requests must carry AdminToken as a bearer token.
+	AdminAddr  string
+	AdminToken string
+
+	Routes []Route
+	// Sources are the kafka sources consumed concurrently, the top level kafka
+	// settings are the only source if there is none.
+	Sources []Source
+}
+
+// DefaultSourceName is the name of the source built from the top level kafka settings.
+const DefaultSourceName = "default"
+
+// Source is a kafka source with its own consumer. Empty fields fall back to the
+// top level configuration, except the topics.
+type Source struct {
+	Name             string   `mapstructure:"name"`
+	BootstrapServers string   `mapstructure:"bootstrap_servers"`
+	GroupID          string   `mapstructure:"consumer_group"`
+	Topics           []string `mapstructure:"topics"`
+	// TopicPattern subscribes to all the topics matching the regular expression.
+	TopicPattern    string `mapstructure:"topic_pattern"`
+	AutoOffsetReset string `mapstructure:"auto_offset_reset"`
+	Assignor        string `mapstructure:"assignor"`
+	// Protocol is the encoding of the spans of the source.
+	Protocol string `mapstructure:"protocol"`
+	// Route is the name of the route all the spans of the source go to, the
+	// route is selected by its conditions if it is empty.
+	Route string `mapstructure:"route"`
+
+	SecurityProtocol string `mapstructure:"security_protocol"`
+	SaslMechanism    string `mapstructure:"sasl_mechanism"`
+	SaslUsername     string `mapstructure:"sasl_username"`
+	SaslPassword     string `mapstructure:"sasl_password"`
+	SslCaLocation    string `mapstructure:"ssl_ca_location"`
+}
+
+// Subscription is the topics the source subscribes to, the pattern is prefixed by ^ as kafka expects.
+func (s Source) Subscription() []string {
+	topics := append([]string(nil), s.Topics...)
+	if s.TopicPattern != "" {
+		pattern := s.TopicPattern
+		if !strings.HasPrefix(pattern, "^") {
+			pattern = "^" + pattern
+		}
+		topics = append(topics, pattern)
+	}
+	return topics
+}
+
+// Route selects the SLS target of the spans matching all of its non-empty conditions.
+// Empty target fields fall back to the top level configuration.
+type Route struct {
+	Name         string   `mapstructure:"name"`
+	Topics       []string `mapstructure:"topics"`
+	Environments []string `mapstructure:"environments"`
+	Services     []string `mapstructure:"services"`
+
+	Project      string `mapstructure:"project"`
+	Instance     string `mapstructure:"instance"`
+	Logstore     string `mapstructure:"logstore"`
+	Endpoint     string `mapstructure:"endpoint"`
+	AccessKey    string `mapstructure:"access_key"`
+	AccessSecret string `mapstructure:"access_secret"`
+}
+
+// ServiceRateLimit is the rate limit of a service, in spans and bytes per second.
+type ServiceRateLimit struct {
+	Spans float64 `mapstructure:"spans"`
+	Bytes float64 `mapstructure:"bytes"`
+}
+
+// ExportsTo tells whether the exporter is enabled, the SLS exporter is enabled by default.
+func (c *Configuration) ExportsTo(exporter string) bool {
+	if len(c.Exporters) == 0 {
+		return exporter == "sls"
+	}
+	for _, name := range c.Exporters {
+		if strings.EqualFold(strings.TrimSpace(name), exporter) {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialsConfig returns the configuration of the credential provider.
+func (c *Configuration) CredentialsConfig() credentials.Config {
+	return credentials.Config{
+		Provider:         c.CredentialProvider,
+		AccessKey:        c.AccessKey,
+		AccessSecret:     c.AccessSecret,
+		StsTokenFile:     c.StsTokenFile,
+		RamRole:          c.RamRole,
+		MetadataEndpoint: c.MetadataEndpoint,
+	}
+}
+
+// LoggingConfig is the configuration of the logger.
+func (c *Configuration) LoggingConfig() logging.Config {
+	return logging.Config{
+		Format:           c.LogFormat,
+		File:             c.LogFile,
+		MaxSizeMB:        c.LogMaxSize,
+		MaxBackups:       c.LogMaxBackups,
+		MaxAgeDays:       c.LogMaxAge,
+		SampleInitial:    c.LogSampleInitial,
+		SampleThereafter: c.LogSampleThereafter,
+	}
+}
+
+// CredentialsProvider returns the shared credential provider, or the static access key if there is none.
+func (c *Configuration) CredentialsProvider() credentials.Provider {
+	if c.Credentials != nil {
+		return c.Credentials
+	}
+	return credentials.NewStaticProvider(c.AccessKey, c.AccessSecret)
+}
+
+// TraceLogstore returns the logstore the spans are written to, default <instance>-traces.
+func (c *Configuration) TraceLogstore() string {
+	if c.Logstore != "" {
+		return c.Logstore
+	}
+	return fmt.Sprintf("%s-traces", c.Instance)
+}
+
+// TraceSummaryLogstore returns the logstore the trace summaries are written to, default <instance>-trace-summaries.
+func (c *Configuration) TraceSummaryLogstore() string {
+	if c.TraceSummaryStore != "" {
+		return c.TraceSummaryStore
+	}
+	return fmt.Sprintf("%s-trace-summaries", c.Instance)
+}
+
+// ForRoute returns a copy of the configuration targeting the route.
+func (c *Configuration) ForRoute(route Route) *Configuration {
+	dest := *c
+	dest.Routes = nil
+	if route.Project != "" {
+		dest.Project = route.Project
+	}
+	if route.Instance != "" {
+		dest.Instance = route.Instance
+		dest.Logstore = ""
+	}
+	if route.Logstore != "" {
+		dest.Logstore = route.Logstore
+	}
+	if route.Endpoint != "" {
+		dest.Endpoint = route.Endpoint
+	}
+	if route.AccessKey != "" {
+		dest.AccessKey = route.AccessKey
+		dest.AccessSecret = route.AccessSecret
+		dest.Credentials = credentials.NewStaticProvider(route.AccessKey, route.AccessSecret)
+	}
+	return &dest
+}
+
+// EffectiveSources returns the sources with the fields they don't set taken from
+// the top level configuration, or the top level source if there is none.
+func (c *Configuration) EffectiveSources() []Source {
+	if len(c.Sources) == 0 {
+		return []Source{c.inherit(Source{Name: DefaultSourceName, Topics: c.Topic})}
+	}
+	sources := make([]Source, 0, len(c.Sources))
+	for _, source := range c.Sources {
+		sources = append(sources, c.inherit(source))
+	}
+	return sources
+}
+
+func (c *Configuration) inherit(source Source) Source {
+	fallback := func(value *string, defaultValue string) {
+		if *value == "" {
+			*value = defaultValue
+		}
+	}
+	fallback(&source.BootstrapServers, c.BootstrapServers)
+	fallback(&source.GroupID, c.GroupID)
+	fallback(&source.AutoOffsetReset, c.AutoOffsetRest)
+	fallback(&source.Assignor, c.Assignor)
+	fallback(&source.Protocol, c.Protocol)
+	fallback(&source.SecurityProtocol, c.SecurityProtocol)
+	fallback(&source.SaslMechanism, c.SaslMechanism)
+	fallback(&source.SaslUsername, c.SaslUsername)
+	fallback(&source.SaslPassword, c.SaslPassword)
+	fallback(&source.SslCaLocation, c.SslCaLocation)
+	return source
+}
+
+// ForSource returns a copy of the configuration consuming the effective source.
+func (c *Configuration) ForSource(source Source) *Configuration {
+	dest := *c
+	dest.Sources = nil
+	dest.BootstrapServers = source.BootstrapServers
+	dest.GroupID = source.GroupID
+	dest.Topic = source.Subscription()
+	dest.AutoOffsetRest = source.AutoOffsetReset
+	dest.Assignor = source.Assignor
+	dest.Protocol = source.Protocol
+	dest.SecurityProtocol = source.SecurityProtocol
+	dest.SaslMechanism = source.SaslMechanism
+	dest.SaslUsername = source.SaslUsername
+	dest.SaslPassword = source.SaslPassword
+	dest.SslCaLocation = source.SslCaLocation
+	return &dest
+}
+
+// Redacted returns a copy of the configuration whose secrets are redacted, for display.
+func (c *Configuration) Redacted() *Configuration {
+	dest := *c
+	dest.AccessKey = credentials.Redact(c.AccessKey)
+	dest.AccessSecret = credentials.Redact(c.AccessSecret)
+	dest.AdminToken = credentials.Redact(c.AdminToken)
+	dest.SaslPassword = credentials.Redact(c.SaslPassword)
+	dest.Sources = make([]Source, len(c.Sources))
+	for i, source := range c.Sources {
+		source.SaslPassword = credentials.Redact(source.SaslPassword)
+		dest.Sources[i] = source
+	}
+	dest.Credentials = nil
+	dest.Routes = make([]Route, len(c.Routes))
+	for i, route := range c.Routes {
+		route.AccessKey = credentials.Redact(route.AccessKey)
+		route.AccessSecret = credentials.Redact(route.AccessSecret)
+		dest.Routes[i] = route
+	}
+	return &dest
+}
+
+// Load reads the configuration file, using the given configuration for the keys absent from the file.
+func Load(path string, defaults *Configuration) (*Configuration, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	defaults.setViperDefaults(v)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	c := &Configuration{AutoOffsetRest: defaults.AutoOffsetRest}
+	c.InitFromViper(v)
+	if err := v.UnmarshalKey("routes", &c.Routes); err != nil {
+		return nil, err
+	}
+	if err := v.UnmarshalKey("sources", &c.Sources); err != nil {
+		return nil, err
+	}
+	if err := v.UnmarshalKey("rate_limit_services", &c.RateLimitServices); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Watch calls onChange whenever the configuration file is written.
+func Watch(path string, onChange func()) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.OnConfigChange(func(fsnotify.Event) {
+		onChange()
+	})
+	v.WatchConfig()
 }
 
 func (c *Configuration) InitFromViper(v *viper.Viper) {
 	c.BootstrapServers = v.GetString("kafka_bootstrap_services")
 	c.GroupID = v.GetString("kafka_consumer_group")
-	c.GroupID = v.GetString("kafka_topic")
+	c.Topic = v.GetStringSlice("kafka_topic")
+	c.Assignor = v.GetString("kafka_assignor")
+	c.SecurityProtocol = v.GetString("kafka_security_protocol")
+	c.SaslMechanism = v.GetString("kafka_sasl_mechanism")
+	c.SaslUsername = v.GetString("kafka_sasl_username")
+	c.SaslPassword = v.GetString("kafka_sasl_password")
+	c.SslCaLocation = v.GetString("kafka_ssl_ca_location")
 
 	c.Project = v.GetString("project")
 	c.Instance = v.GetString("instance")
@@ -27,4 +396,141 @@ func (c *Configuration) InitFromViper(v *viper.Viper) {
 	c.AccessSecret = v.GetString("access_secret")
 	c.Endpoint = v.GetString("endpoint")
 	c.Protocol = v.GetString("protocol")
+	c.Logstore = v.GetString("logstore")
+	c.CredentialProvider = v.GetString("credential_provider")
+	c.StsTokenFile = v.GetString("sts_token_file")
+	c.RamRole = v.GetString("ram_role")
+	c.MetadataEndpoint = v.GetString("metadata_endpoint")
+	c.TimestampPolicy = v.GetString("timestamp_policy")
+
+	c.ProvisionLogstore = v.GetBool("provision_logstore")
+	c.LogstoreTTL = v.GetInt("logstore_ttl")
+	c.LogstoreShards = v.GetInt("logstore_shards")
+
+	c.Exporters = v.GetStringSlice("exporters")
+	c.ZipkinURL = v.GetString("zipkin_url")
+	c.ZipkinEncoding = v.GetString("zipkin_encoding")
+	c.ZipkinTimeout = v.GetDuration("zipkin_timeout")
+
+	c.SpanMetricsEnabled = v.GetBool("span_metrics")
+	c.SpanMetricsStore = v.GetString("span_metrics_store")
+	c.SpanMetricsInterval = v.GetDuration("span_metrics_interval")
+	c.SpanMetricsDimensions = v.GetStringSlice("span_metrics_dimensions")
+	c.SpanMetricsMaxSeries = v.GetInt("span_metrics_max_series")
+	c.SelfMetricsEnabled = v.GetBool("self_metrics")
+	c.SelfTracingEnabled = v.GetBool("self_tracing")
+	c.SelfTracingEndpoint = v.GetString("self_tracing_endpoint")
+	c.SelfTracingInsecure = v.GetBool("self_tracing_insecure")
+	c.SelfTracingService = v.GetString("self_tracing_service")
+	c.SelfTracingSampling = v.GetFloat64("self_tracing_sampling")
+
+	c.ClockSkewEnabled = v.GetBool("clock_skew")
+	c.ClockSkewWindow = v.GetDuration("clock_skew_window")
+	c.ClockSkewMaxTraces = v.GetInt("clock_skew_max_traces")
+	c.ShutdownTimeout = v.GetDuration("shutdown_timeout")
+	c.AdminAddr = v.GetString("admin_addr")
+	c.AdminToken = v.GetString("admin_token")
+	c.TraceSummaryEnabled = v.GetBool("trace_summary")
+	c.TraceSummaryStore = v.GetString("trace_summary_store")
+	c.TraceSummaryWindow = v.GetDuration("trace_summary_window")
+	c.TraceSummaryMaxTraces = v.GetInt("trace_summary_max_traces")
+	c.DedupEnabled = v.GetBool("dedup")
+	c.DedupWindow = v.GetDuration("dedup_window")
+	c.DedupMaxEntries = v.GetInt("dedup_max_entries")
+	c.SpanMaxTagCount = v.GetInt("span_max_tag_count")
+	c.SpanMaxTagValueLength = v.GetInt("span_max_tag_value_length")
+	c.SpanMaxAnnotations = v.GetInt("span_max_annotation_count")
+	c.SpanMaxSize = v.GetInt("span_max_size")
+	c.RateLimitSpans = v.GetFloat64("rate_limit_spans")
+	c.RateLimitBytes = v.GetFloat64("rate_limit_bytes")
+	c.RateLimitServiceSpans = v.GetFloat64("rate_limit_service_spans")
+	c.RateLimitServiceBytes = v.GetFloat64("rate_limit_service_bytes")
+	c.RateLimitAction = v.GetString("rate_limit_action")
+	c.LogLevel = v.GetString("log_level")
+	c.LogFormat = v.GetString("log_format")
+	c.LogFile = v.GetString("log_file")
+	c.LogMaxSize = v.GetInt("log_max_size")
+	c.LogMaxBackups = v.GetInt("log_max_backups")
+	c.LogMaxAge = v.GetInt("log_max_age")
+	c.LogSampleInitial = v.GetInt("log_sample_initial")
+	c.LogSampleThereafter = v.GetInt("log_sample_thereafter")
+	c.ReloadOnChange = v.GetBool("reload_on_change")
+}
+
+func (c *Configuration) setViperDefaults(v *viper.Viper) {
+	v.SetDefault("kafka_bootstrap_services", c.BootstrapServers)
+	v.SetDefault("kafka_consumer_group", c.GroupID)
+	v.SetDefault("kafka_topic", c.Topic)
+	v.SetDefault("kafka_assignor", c.Assignor)
+	v.SetDefault("kafka_security_protocol", c.SecurityProtocol)
+	v.SetDefault("kafka_sasl_mechanism", c.SaslMechanism)
+	v.SetDefault("kafka_sasl_username", c.SaslUsername)
+	v.SetDefault("kafka_sasl_password", c.SaslPassword)
+	v.SetDefault("kafka_ssl_ca_location", c.SslCaLocation)
+
+	v.SetDefault("project", c.Project)
+	v.SetDefault("instance", c.Instance)
+	v.SetDefault("access_key", c.AccessKey)
+	v.SetDefault("access_secret", c.AccessSecret)
+	v.SetDefault("endpoint", c.Endpoint)
+	v.SetDefault("protocol", c.Protocol)
+	v.SetDefault("logstore", c.Logstore)
+	v.SetDefault("credential_provider", c.CredentialProvider)
+	v.SetDefault("sts_token_file", c.StsTokenFile)
+	v.SetDefault("ram_role", c.RamRole)
+	v.SetDefault("metadata_endpoint", c.MetadataEndpoint)
+	v.SetDefault("timestamp_policy", c.TimestampPolicy)
+
+	v.SetDefault("provision_logstore", c.ProvisionLogstore)
+	v.SetDefault("logstore_ttl", c.LogstoreTTL)
+	v.SetDefault("logstore_shards", c.LogstoreShards)
+
+	v.SetDefault("exporters", c.Exporters)
+	v.SetDefault("zipkin_url", c.ZipkinURL)
+	v.SetDefault("zipkin_encoding", c.ZipkinEncoding)
+	v.SetDefault("zipkin_timeout", c.ZipkinTimeout)
+
+	v.SetDefault("span_metrics", c.SpanMetricsEnabled)
+	v.SetDefault("span_metrics_store", c.SpanMetricsStore)
+	v.SetDefault("span_metrics_interval", c.SpanMetricsInterval)
+	v.SetDefault("span_metrics_dimensions", c.SpanMetricsDimensions)
+	v.SetDefault("span_metrics_max_series", c.SpanMetricsMaxSeries)
+	v.SetDefault("self_metrics", c.SelfMetricsEnabled)
+	v.SetDefault("self_tracing", c.SelfTracingEnabled)
+	v.SetDefault("self_tracing_endpoint", c.SelfTracingEndpoint)
+	v.SetDefault("self_tracing_insecure", c.SelfTracingInsecure)
+	v.SetDefault("self_tracing_service", c.SelfTracingService)
+	v.SetDefault("self_tracing_sampling", c.SelfTracingSampling)
+
+	v.SetDefault("clock_skew", c.ClockSkewEnabled)
+	v.SetDefault("clock_skew_window", c.ClockSkewWindow)
+	v.SetDefault("clock_skew_max_traces", c.ClockSkewMaxTraces)
+	v.SetDefault("shutdown_timeout", c.ShutdownTimeout)
+	v.SetDefault("admin_addr", c.AdminAddr)
+	v.SetDefault("admin_token", c.AdminToken)
+	v.SetDefault("trace_summary", c.TraceSummaryEnabled)
+	v.SetDefault("trace_summary_store", c.TraceSummaryStore)
+	v.SetDefault("trace_summary_window", c.TraceSummaryWindow)
+	v.SetDefault("trace_summary_max_traces", c.TraceSummaryMaxTraces)
+	v.SetDefault("dedup", c.DedupEnabled)
+	v.SetDefault("dedup_window", c.DedupWindow)
+	v.SetDefault("dedup_max_entries", c.DedupMaxEntries)
+	v.SetDefault("span_max_tag_count", c.SpanMaxTagCount)
+	v.SetDefault("span_max_tag_value_length", c.SpanMaxTagValueLength)
+	v.SetDefault("span_max_annotation_count", c.SpanMaxAnnotations)
+	v.SetDefault("span_max_size", c.SpanMaxSize)
+	v.SetDefault("rate_limit_spans", c.RateLimitSpans)
+	v.SetDefault("rate_limit_bytes", c.RateLimitBytes)
+	v.SetDefault("rate_limit_service_spans", c.RateLimitServiceSpans)
+	v.SetDefault("rate_limit_service_bytes", c.RateLimitServiceBytes)
+	v.SetDefault("rate_limit_action", c.RateLimitAction)
+	v.SetDefault("log_level", c.LogLevel)
+	v.SetDefault("log_format", c.LogFormat)
+	v.SetDefault("log_file", c.LogFile)
+	v.SetDefault("log_max_size", c.LogMaxSize)
+	v.SetDefault("log_max_backups", c.LogMaxBackups)
+	v.SetDefault("log_max_age", c.LogMaxAge)
+	v.SetDefault("log_sample_initial", c.LogSampleInitial)
+	v.SetDefault("log_sample_thereafter", c.LogSampleThereafter)
+	v.SetDefault("reload_on_change", c.ReloadOnChange)
 }
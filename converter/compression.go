@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingSnappy = "snappy"
+	EncodingLz4    = "lz4"
+
+	// HeaderContentEncoding is the kafka header naming the compression of the payload,
+	// the compression is detected from the magic bytes of the payload without it.
+	HeaderContentEncoding = "Content-Encoding"
+
+	// MaxDecompressedSize bounds the size of a decompressed payload.
+	MaxDecompressedSize = 64 << 20
+
+	MetricPayloadDecompressed = "zipkin_ingester_payload_decompressed_total"
+)
+
+var (
+	gzipMagic   = []byte{0x1f, 0x8b}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic    = []byte{0x04, 0x22, 0x4d, 0x18}
+	snappyMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+// ContentEncoding returns the value of the Content-Encoding header, the header name is case insensitive.
+func ContentEncoding(headers map[string]string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, HeaderContentEncoding) {
+			return value
+		}
+	}
+	return ""
+}
+
+// DetectEncoding returns the compression of the payload from its magic bytes, or
+// an empty string if it is not compressed. The snappy payloads are only detected
+// in the framing format, the raw snappy blocks require the header.
+func DetectEncoding(payload []byte) string {
+	switch {
+	case bytes.HasPrefix(payload, gzipMagic):
+		return EncodingGzip
+	case bytes.HasPrefix(payload, zstdMagic):
+		return EncodingZstd
+	case bytes.HasPrefix(payload, lz4Magic):
+		return EncodingLz4
+	case bytes.HasPrefix(payload, snappyMagic):
+		return EncodingSnappy
+	}
+	return ""
+}
+
+// Decompress returns the payload decompressed with the encoding, or with the
+// encoding detected from its magic bytes if it is empty. The payloads which are
+// not compressed are returned as is.
+func Decompress(payload []byte, encoding string) ([]byte, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "identity" || encoding == "none" {
+		encoding = DetectEncoding(payload)
+	}
+
+	var data []byte
+	var err error
+	switch encoding {
+	case "":
+		return payload, nil
+	case EncodingGzip:
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(payload)); err == nil {
+			data, err = readAll(reader)
+		}
+	case EncodingZstd:
+		data, err = decodeZstd(payload)
+	case EncodingLz4:
+		data, err = readAll(lz4.NewReader(bytes.NewReader(payload)))
+	case EncodingSnappy:
+		data, err = decodeSnappy(payload)
+	default:
+		return nil, fmt.Errorf("unknown content encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress the %s payload: %w", encoding, err)
+	}
+	metrics.NewCounter(MetricPayloadDecompressed, map[string]string{"encoding": encoding}).Inc()
+	return data, nil
+}
+
+func decodeZstd(payload []byte) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		// the decoder is safe for concurrent DecodeAll calls
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+	})
+	if zstdDecoderErr != nil {
+		return nil, zstdDecoderErr
+	}
+	return zstdDecoder.DecodeAll(payload, nil)
+}
+
+func decodeSnappy(payload []byte) ([]byte, error) {
+	if bytes.HasPrefix(payload, snappyMagic) {
+		return readAll(snappy.NewReader(bytes.NewReader(payload)))
+	}
+	size, err := snappy.DecodedLen(payload)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxDecompressedSize {
+		return nil, fmt.Errorf("the decompressed payload exceeds %d bytes", MaxDecompressedSize)
+	}
+	return snappy.Decode(nil, payload)
+}
+
+func readAll(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxDecompressedSize {
+		return nil, fmt.Errorf("the decompressed payload exceeds %d bytes", MaxDecompressedSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+const compressedSpans = `[{"traceId":"0000000000000001","id":"0000000000000002","name":"get","timestamp":1659409534000000,"duration":1000,"localEndpoint":{"serviceName":"frontend"}}]`
+
+func TestDecompress(t *testing.T) {
+	payload := []byte(compressedSpans)
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write(payload)
+	_ = gw.Close()
+
+	encoder, _ := zstd.NewWriter(nil)
+	zstded := encoder.EncodeAll(payload, nil)
+
+	var lz4ed bytes.Buffer
+	lw := lz4.NewWriter(&lz4ed)
+	_, _ = lw.Write(payload)
+	_ = lw.Close()
+
+	var framed bytes.Buffer
+	sw := snappy.NewBufferedWriter(&framed)
+	_, _ = sw.Write(payload)
+	_ = sw.Close()
+
+	cases := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"plain", payload, ""},
+		{"gzip", gzipped.Bytes(), ""},
+		{"zstd", zstded, ""},
+		{"lz4", lz4ed.Bytes(), ""},
+		{"snappy framed", framed.Bytes(), ""},
+		{"snappy block", snappy.Encode(nil, payload), "Snappy"},
+		{"gzip header", gzipped.Bytes(), "gzip"},
+		{"identity header", gzipped.Bytes(), "identity"},
+	}
+	for _, c := range cases {
+		data, err := Decompress(c.data, c.encoding)
+		if err != nil {
+			t.Errorf("%s: Failed to decompress. %v", c.name, err)
+			continue
+		}
+		if !bytes.Equal(data, payload) {
+			t.Errorf("%s: Expected %s, Actual: %q", c.name, payload, data)
+		}
+		spans, err := NewConverter("json").ParseSpans(data, false)
+		if err != nil || len(spans) != 1 {
+			t.Errorf("%s: Failed to parse the decompressed spans. %v", c.name, err)
+		}
+	}
+
+	if _, err := Decompress(payload, "brotli"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+	if _, err := Decompress(gzipped.Bytes()[:10], ""); err == nil {
+		t.Error("Expected an error for a truncated payload")
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	if encoding := ContentEncoding(map[string]string{"content-encoding": "zstd"}); encoding != EncodingZstd {
+		t.Errorf("Expected zstd, Actual: %q", encoding)
+	}
+	if encoding := ContentEncoding(nil); encoding != "" {
+		t.Errorf("Expected no encoding, Actual: %q", encoding)
+	}
+}
@@ -5,9 +5,17 @@ const (
 	TagSpanKind               = "span.kind"
 	TagStatusCode             = "status.code"
 	TagStatusMsg              = "status.message"
+	TagOtelStatusCode         = "otel.status_code"
 	TagError                  = "error"
 	TagHTTPStatusCode         = "http.status_code"
 	TagHTTPStatusMsg          = "http.status_message"
+	TagHTTPMethod             = "http.method"
+	TagHTTPPath               = "http.path"
+	TagHTTPURL                = "http.url"
+	TagHTTPHost               = "http.host"
+	TagMVCControllerClass     = "mvc.controller.class"
+	TagMVCControllerMethod    = "mvc.controller.method"
+	TagSQLQuery               = "sql.query"
 	TagZipkinCensusCode       = "census.status_code"
 	TagZipkinCensusMsg        = "census.status_description"
 	TagZipkinOpenCensusMsg    = "opencensus.status_description"
@@ -15,6 +23,8 @@ const (
 	TagServiceNameSource      = "otlp.service.name.source"
 	TagInstrumentationName    = "otlp.instrumentation.library.name"
 	TagInstrumentationVersion = "otlp.instrumentation.library.version"
+	TagDroppedAttributesCount = "otlp.dropped_attributes_count"
+	TagDroppedEventsCount     = "otlp.dropped_events_count"
 
 	ResourceNoServiceName = "OTLPResourceNoServiceName"
 
@@ -82,13 +92,24 @@ const (
 	AttributeEnduserRole  = "enduser.role"
 	AttributeEnduserScope = "enduser.scope"
 	AttributeNetHostIP    = "net.host.ip"
+	AttributeNetHostIPv6  = "net.host.ipv6"
 	AttributeNetHostName  = "net.host.name"
 	AttributeNetHostPort  = "net.host.port"
 	AttributeNetPeerIP    = "net.peer.ip"
+	AttributeNetPeerIPv6  = "net.peer.ipv6"
 	AttributeNetPeerName  = "net.peer.name"
 	AttributeNetPeerPort  = "net.peer.port"
 	AttributeNetTransport = "net.transport"
 	AttributePeerService  = "peer.service"
+
+	AttributeHTTPRequestMethod      = "http.request.method"
+	AttributeHTTPResponseStatusCode = "http.response.status_code"
+	AttributeURLPath                = "url.path"
+	AttributeURLFull                = "url.full"
+	AttributeServerAddress          = "server.address"
+	AttributeCodeNamespace          = "code.namespace"
+	AttributeCodeFunction           = "code.function"
+	AttributeDBQueryText            = "db.query.text"
 )
 
 const (
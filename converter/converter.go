@@ -1,15 +1,70 @@
 package converter
 
 import (
-	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"fmt"
 	"strings"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 )
 
+// Converter parses the spans of a message. If only some of the spans are
+// invalid, the valid spans are returned with a *PartialError.
 type Converter interface {
 	ParseSpans(protoBlob []byte, debugWasSet bool) (zss []*zipkinmodel.SpanModel, err error)
 }
 
+// SpanError is the failure to parse a span, Index is its position in the message.
+type SpanError struct {
+	Index int
+	Err   error
+}
+
+func (e SpanError) Error() string {
+	return fmt.Sprintf("span %d: %v", e.Index, e.Err)
+}
+
+func (e SpanError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError lists the invalid spans of a message whose other spans are valid.
+type PartialError struct {
+	Spans []SpanError
+}
+
+func (e *PartialError) Error() string {
+	if len(e.Spans) == 1 {
+		return e.Spans[0].Error()
+	}
+	return fmt.Sprintf("%d invalid spans, first %v", len(e.Spans), e.Spans[0])
+}
+
+// partialResult returns the valid spans and the errors of the invalid ones, a
+// message without any valid span fails as a whole.
+func partialResult(zss []*zipkinmodel.SpanModel, errs []SpanError) ([]*zipkinmodel.SpanModel, error) {
+	switch {
+	case len(errs) == 0:
+		return zss, nil
+	case len(zss) == 0:
+		if len(errs) == 1 {
+			return nil, errs[0].Err
+		}
+		return nil, &PartialError{Spans: errs}
+	default:
+		return zss, &PartialError{Spans: errs}
+	}
+}
+
+// NewConverter returns a converter of the protocol which drops the spans without timestamp.
 func NewConverter(protocol string) Converter {
+	return NewConverterWithPolicy(protocol, TimestampPolicyDrop)
+}
+
+func NewConverterWithPolicy(protocol string, policy TimestampPolicy) *TimestampConverter {
+	return NewTimestampConverter(newProtocolConverter(protocol), policy)
+}
+
+func newProtocolConverter(protocol string) Converter {
 	if strings.ToUpper(protocol) == "JSON" {
 		return &JsonConvertor{}
 	} else {
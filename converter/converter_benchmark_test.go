@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"google.golang.org/protobuf/proto"
+)
+
+// The benchmarks convert batches of benchmarkSpans spans and report the spans
+// converted per second, run them with -cpu 1 to get the throughput per core.
+const benchmarkSpans = 100
+
+// benchmarkProtoSpans returns a batch of spans looking like the ones of an
+// instrumented HTTP service calling a database.
+func benchmarkProtoSpans() []*zipkin_proto3.Span {
+	spans := make([]*zipkin_proto3.Span, 0, benchmarkSpans)
+	start := uint64(time.Date(2022, 8, 2, 3, 5, 34, 0, time.UTC).UnixNano() / 1e3)
+	for i := 0; i < benchmarkSpans; i++ {
+		span := &zipkin_proto3.Span{
+			TraceId:   UInt64ToTraceID(0x62e8947e4f34d012, uint64(i/10+1)),
+			Id:        UInt64ToSpanID(uint64(i + 1)),
+			Kind:      zipkin_proto3.Span_SERVER,
+			Name:      "post /notice-message/v1/auth/query-valid",
+			Timestamp: start + uint64(i)*1000,
+			Duration:  49781,
+			LocalEndpoint: &zipkin_proto3.Endpoint{
+				ServiceName: fmt.Sprintf("service-%d", i%5),
+				Ipv4:        net.ParseIP("172.16.8.163").To4(),
+				Port:        8080,
+			},
+			RemoteEndpoint: &zipkin_proto3.Endpoint{
+				ServiceName: "mysql",
+				Ipv4:        net.ParseIP("172.16.8.198").To4(),
+				Port:        3306,
+			},
+			Annotations: []*zipkin_proto3.Annotation{
+				{Timestamp: start + uint64(i)*1000 + 10, Value: "ws"},
+				{Timestamp: start + uint64(i)*1000 + 40000, Value: "wr"},
+			},
+			Tags: map[string]string{
+				"http.method":       "POST",
+				"http.path":         "/notice-message/v1/auth/query-valid",
+				"http.status_code":  "200",
+				"http.latency_ms":   "49.781",
+				"error":             "false",
+				"db.statement":      "SELECT * FROM notice WHERE id = ?",
+				"db.rows":           "12",
+				"request.headers":   `{"accept":"application/json"}`,
+				"request.ids":       "[1,2,3]",
+				"otel.library.name": "io.opentelemetry.http",
+			},
+		}
+		if i%10 != 0 {
+			span.Kind = zipkin_proto3.Span_CLIENT
+			span.ParentId = UInt64ToSpanID(uint64(i/10*10 + 1))
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func reportSpansPerSecond(b *testing.B, start time.Time) {
+	b.ReportMetric(float64(b.N*benchmarkSpans)/time.Since(start).Seconds(), "spans/s")
+}
+
+func BenchmarkProtobufParseSpans(b *testing.B) {
+	payload, err := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: benchmarkProtoSpans()})
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := &ProtobufConvertor{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ParseSpans(payload, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportSpansPerSecond(b, start)
+}
+
+func BenchmarkConvert2OtelSpan(b *testing.B) {
+	payload, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: benchmarkProtoSpans()})
+	spans, err := (&ProtobufConvertor{}).ParseSpans(payload, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := Convert2OtelSpan(spans); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportSpansPerSecond(b, start)
+}
+
+// BenchmarkProtobufToOtel is the whole conversion of a proto3 message into OTLP.
+func BenchmarkProtobufToOtel(b *testing.B) {
+	payload, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: benchmarkProtoSpans()})
+	c := &ProtobufConvertor{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		spans, err := c.ParseSpans(payload, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Convert2OtelSpan(spans); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportSpansPerSecond(b, start)
+}
+
+func BenchmarkDetermineValueType(b *testing.B) {
+	values := []string{"", "POST", "200", "-12", "49.781", "true", "false", `{"accept":"application/json"}`, "[1,2,3]", "/notice-message/v1/auth/query-valid"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, value := range values {
+			DetermineValueType(value, false)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package converter
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var errInvalidTraceID = errors.New("trace id must be 16 bytes")
+var errInvalidSpanID = errors.New("span id must be 8 bytes")
+
+// ConvertOtel2ZipkinSpans is the inverse of Convert2OtelSpan: the resource and
+// instrumentation library become tags, the events become annotations and the
+// links become otlp.link.N tags.
+func ConvertOtel2ZipkinSpans(data []*tracepb.ResourceSpans) (spans []*zipkinmodel.SpanModel, e error) {
+	for _, rs := range data {
+		if rs == nil {
+			continue
+		}
+		resourceTags := make(map[string]string)
+		localServiceName := ""
+		if rs.Resource != nil {
+			for _, attr := range rs.Resource.Attributes {
+				if attr.Key == AttributeServiceName {
+					localServiceName = anyValueToString(attr.Value)
+				} else {
+					resourceTags[attr.Key] = anyValueToString(attr.Value)
+				}
+			}
+		}
+
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				zspan, err := otelSpanToZipkinSpan(span, localServiceName, resourceTags, ils.InstrumentationLibrary)
+				if err != nil {
+					return nil, err
+				}
+				spans = append(spans, zspan)
+			}
+		}
+	}
+	return spans, nil
+}
+
+func otelSpanToZipkinSpan(span *tracepb.Span, localServiceName string, resourceTags map[string]string, il *v11.InstrumentationLibrary) (*zipkinmodel.SpanModel, error) {
+	traceID, err := bytesToTraceID(span.TraceId)
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := bytesToSpanID(span.SpanId)
+	if err != nil {
+		return nil, err
+	}
+
+	zspan := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID: traceID,
+			ID:      spanID,
+		},
+		Name:      span.Name,
+		Timestamp: time.Unix(0, int64(span.StartTimeUnixNano)).UTC(),
+		Tags:      make(map[string]string, len(resourceTags)+len(span.Attributes)),
+	}
+	if span.EndTimeUnixNano > span.StartTimeUnixNano {
+		zspan.Duration = time.Duration(span.EndTimeUnixNano - span.StartTimeUnixNano)
+	}
+	if len(span.ParentSpanId) > 0 {
+		parentID, err := bytesToSpanID(span.ParentSpanId)
+		if err != nil {
+			return nil, err
+		}
+		zspan.ParentID = &parentID
+	}
+
+	for key, value := range resourceTags {
+		zspan.Tags[key] = value
+	}
+	if il != nil && il.Name != "" {
+		zspan.Tags[TagInstrumentationName] = il.Name
+		if il.Version != "" {
+			zspan.Tags[TagInstrumentationVersion] = il.Version
+		}
+	}
+	if span.TraceState != "" {
+		zspan.Tags[TagW3CTraceState] = span.TraceState
+	}
+	if span.DroppedAttributesCount > 0 {
+		zspan.Tags[TagDroppedAttributesCount] = strconv.FormatUint(uint64(span.DroppedAttributesCount), 10)
+	}
+	if span.DroppedEventsCount > 0 {
+		zspan.Tags[TagDroppedEventsCount] = strconv.FormatUint(uint64(span.DroppedEventsCount), 10)
+	}
+
+	switch span.Kind {
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		zspan.Kind = zipkinmodel.Client
+	case tracepb.Span_SPAN_KIND_SERVER:
+		zspan.Kind = zipkinmodel.Server
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		zspan.Kind = zipkinmodel.Producer
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		zspan.Kind = zipkinmodel.Consumer
+	case tracepb.Span_SPAN_KIND_INTERNAL:
+		zspan.Tags[TagSpanKind] = "internal"
+	}
+
+	// as the OpenTelemetry Zipkin exporter does, the failed spans have an error
+	// tag so that the Zipkin backends show them as errors
+	if span.Status != nil && span.Status.Code != tracepb.Status_STATUS_CODE_UNSET {
+		zspan.Tags[TagOtelStatusCode] = StatusCodeName(span.Status.Code)
+		if span.Status.Code == tracepb.Status_STATUS_CODE_ERROR {
+			zspan.Tags[TagError] = "true"
+			if span.Status.Message != "" {
+				zspan.Tags[TagError] = span.Status.Message
+			}
+		}
+	}
+
+	local := &zipkinmodel.Endpoint{ServiceName: localServiceName}
+	var remote *zipkinmodel.Endpoint
+	for _, attr := range span.Attributes {
+		value := anyValueToString(attr.Value)
+		switch attr.Key {
+		case AttributeNetHostIP, AttributeNetHostIPv6:
+			setEndpointIP(local, value)
+		case AttributeNetHostPort:
+			local.Port = parsePort(value)
+		case AttributePeerService:
+			remote = ensureEndpoint(remote)
+			remote.ServiceName = value
+		case AttributeNetPeerIP, AttributeNetPeerIPv6:
+			remote = ensureEndpoint(remote)
+			setEndpointIP(remote, value)
+		case AttributeNetPeerPort:
+			remote = ensureEndpoint(remote)
+			remote.Port = parsePort(value)
+		default:
+			zspan.Tags[attr.Key] = value
+		}
+	}
+	zspan.LocalEndpoint = local
+	zspan.RemoteEndpoint = remote
+
+	for _, event := range span.Events {
+		zspan.Annotations = append(zspan.Annotations, zipkinmodel.Annotation{
+			Timestamp: time.Unix(0, int64(event.TimeUnixNano)).UTC(),
+			Value:     eventToAnnotationValue(event),
+		})
+	}
+
+	for i, link := range span.Links {
+		value, err := linkToTagValue(link)
+		if err != nil {
+			return nil, err
+		}
+		zspan.Tags[fmt.Sprintf("otlp.link.%d", i)] = value
+	}
+
+	if len(zspan.Tags) == 0 {
+		zspan.Tags = nil
+	}
+	return zspan, nil
+}
+
+// eventToAnnotationValue encodes the event as name|attributes|dropped attributes count,
+// or as its name alone if it has no attributes.
+func eventToAnnotationValue(event *tracepb.Span_Event) string {
+	if len(event.Attributes) == 0 && event.DroppedAttributesCount == 0 {
+		return event.Name
+	}
+	attrs, _ := json.Marshal(attributesToJSONMap(event.Attributes))
+	return fmt.Sprintf("%s|%s|%d", event.Name, attrs, event.DroppedAttributesCount)
+}
+
+// linkToTagValue encodes the link as trace id|span id|trace state|attributes|dropped attributes count.
+func linkToTagValue(link *tracepb.Span_Link) (string, error) {
+	if len(link.TraceId) != 16 {
+		return "", errInvalidTraceID
+	}
+	if len(link.SpanId) != 8 {
+		return "", errInvalidSpanID
+	}
+	attrs, err := json.Marshal(attributesToJSONMap(link.Attributes))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%d", hex.EncodeToString(link.TraceId), hex.EncodeToString(link.SpanId),
+		link.TraceState, attrs, link.DroppedAttributesCount), nil
+}
+
+func attributesToJSONMap(attrs []*v11.KeyValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(attrs))
+	for _, attr := range attrs {
+		result[attr.Key] = anyValueToInterface(attr.Value)
+	}
+	return result
+}
+
+func anyValueToInterface(value *v11.AnyValue) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch v := value.Value.(type) {
+	case *v11.AnyValue_StringValue:
+		return v.StringValue
+	case *v11.AnyValue_IntValue:
+		return v.IntValue
+	case *v11.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *v11.AnyValue_BoolValue:
+		return v.BoolValue
+	case *v11.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, anyValueToInterface(item))
+		}
+		return values
+	case *v11.AnyValue_KvlistValue:
+		return attributesToJSONMap(v.KvlistValue.GetValues())
+	case *v11.AnyValue_BytesValue:
+		return v.BytesValue
+	}
+	return nil
+}
+
+func anyValueToString(value *v11.AnyValue) string {
+	if value == nil {
+		return ""
+	}
+	switch v := value.Value.(type) {
+	case *v11.AnyValue_StringValue:
+		return v.StringValue
+	case *v11.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *v11.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case *v11.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *v11.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	default:
+		data, _ := json.Marshal(anyValueToInterface(value))
+		return string(data)
+	}
+}
+
+func bytesToTraceID(data []byte) (zipkinmodel.TraceID, error) {
+	if len(data) != 16 {
+		return zipkinmodel.TraceID{}, errInvalidTraceID
+	}
+	return zipkinmodel.TraceID{
+		High: binary.BigEndian.Uint64(data[:8]),
+		Low:  binary.BigEndian.Uint64(data[8:]),
+	}, nil
+}
+
+func bytesToSpanID(data []byte) (zipkinmodel.ID, error) {
+	if len(data) != 8 {
+		return 0, errInvalidSpanID
+	}
+	return zipkinmodel.ID(binary.BigEndian.Uint64(data)), nil
+}
+
+func ensureEndpoint(endpoint *zipkinmodel.Endpoint) *zipkinmodel.Endpoint {
+	if endpoint == nil {
+		return &zipkinmodel.Endpoint{}
+	}
+	return endpoint
+}
+
+func setEndpointIP(endpoint *zipkinmodel.Endpoint, value string) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return
+	}
+	if ipv4 := ip.To4(); ipv4 != nil && !strings.Contains(value, ":") {
+		endpoint.IPv4 = ipv4
+	} else {
+		endpoint.IPv6 = ip
+	}
+}
+
+func parsePort(value string) uint16 {
+	port, _ := strconv.ParseUint(value, 10, 16)
+	return uint16(port)
+}
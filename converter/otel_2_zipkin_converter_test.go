@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestConvertOtel2ZipkinSpans(t *testing.T) {
+	parentID := zipkinmodel.ID(0x072e15a0c445ae66)
+	origin := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID:  zipkinmodel.TraceID{High: 0x62e8947e4f34d012, Low: 0x072e15a0c445ae66},
+			ID:       zipkinmodel.ID(0xe9938a2b5b8d2f05),
+			ParentID: &parentID,
+		},
+		Name:           "post",
+		Kind:           zipkinmodel.Client,
+		Timestamp:      time.Unix(0, 1659409534155206000).UTC(),
+		Duration:       3597 * time.Microsecond,
+		LocalEndpoint:  &zipkinmodel.Endpoint{ServiceName: "thinggateway", IPv4: net.ParseIP("172.16.8.163").To4()},
+		RemoteEndpoint: &zipkinmodel.Endpoint{IPv4: net.ParseIP("172.16.8.198").To4(), Port: 30003},
+		Tags: map[string]string{
+			AttributeHTTPRequestMethod: "POST",
+			TagStatusCode:              "STATUS_CODE_ERROR",
+			TagStatusMsg:               "timeout",
+			"otlp.link.0":              "62e8947edb471059e29c4e5ea43c2f2c|e29c4e5ea43c2f2c|congo=t61|{\"retry\":1}|2",
+		},
+		Annotations: []zipkinmodel.Annotation{
+			{Timestamp: time.Unix(0, 1659409534156206000).UTC(), Value: "retry|{\"attempt\":2}|0"},
+			{Timestamp: time.Unix(0, 1659409534157206000).UTC(), Value: "done"},
+		},
+	}
+
+	otelSpans, err := Convert2OtelSpan([]*zipkinmodel.SpanModel{origin})
+	if err != nil {
+		t.Fatalf("Convert Failed. %v", err)
+	}
+	spans, err := ConvertOtel2ZipkinSpans(otelSpans)
+	if err != nil {
+		t.Fatalf("Convert Failed. %v", err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("Span Size: Expected 1, Actual: %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.TraceID != origin.TraceID || span.ID != origin.ID || *span.ParentID != *origin.ParentID {
+		t.Errorf("IDs: Expected %v, Actual: %v", origin.SpanContext, span.SpanContext)
+	}
+	if span.Name != origin.Name || span.Kind != origin.Kind || !span.Timestamp.Equal(origin.Timestamp) || span.Duration != origin.Duration {
+		t.Errorf("Span: Expected %v, Actual: %v", origin, span)
+	}
+	if span.LocalEndpoint.ServiceName != "thinggateway" || !span.LocalEndpoint.IPv4.Equal(origin.LocalEndpoint.IPv4) {
+		t.Errorf("LocalEndpoint: Expected %v, Actual: %v", origin.LocalEndpoint, span.LocalEndpoint)
+	}
+	if span.RemoteEndpoint == nil || span.RemoteEndpoint.Port != 30003 || !span.RemoteEndpoint.IPv4.Equal(origin.RemoteEndpoint.IPv4) {
+		t.Errorf("RemoteEndpoint: Expected %v, Actual: %v", origin.RemoteEndpoint, span.RemoteEndpoint)
+	}
+	for _, key := range []string{AttributeHTTPRequestMethod, "otlp.link.0"} {
+		if span.Tags[key] != origin.Tags[key] {
+			t.Errorf("Tag %s: Expected %s, Actual: %s", key, origin.Tags[key], span.Tags[key])
+		}
+	}
+	if span.Tags[TagOtelStatusCode] != "ERROR" || span.Tags[TagError] != "timeout" {
+		t.Errorf("Status: Expected the error tag, Actual: %v", span.Tags)
+	}
+	if len(span.Annotations) != 2 {
+		t.Fatalf("Annotations: Expected %v, Actual: %v", origin.Annotations, span.Annotations)
+	}
+	for i, anno := range origin.Annotations {
+		if span.Annotations[i].Value != anno.Value || !span.Annotations[i].Timestamp.Equal(anno.Timestamp) {
+			t.Errorf("Annotation %d: Expected %v, Actual: %v", i, anno, span.Annotations[i])
+		}
+	}
+}
+
+func TestConvertOtel2ZipkinStatusRoundTrip(t *testing.T) {
+	cases := []struct {
+		tags    map[string]string
+		zipkin  map[string]string
+		code    tracepb.Status_StatusCode
+		message string
+	}{
+		{map[string]string{TagStatusCode: "STATUS_CODE_ERROR", TagStatusMsg: "timeout"}, map[string]string{TagOtelStatusCode: "ERROR", TagError: "timeout"}, tracepb.Status_STATUS_CODE_ERROR, "timeout"},
+		{map[string]string{TagError: "true"}, map[string]string{TagOtelStatusCode: "ERROR", TagError: "true"}, tracepb.Status_STATUS_CODE_ERROR, ""},
+		{map[string]string{TagStatusCode: "STATUS_CODE_OK"}, map[string]string{TagOtelStatusCode: "OK"}, tracepb.Status_STATUS_CODE_OK, ""},
+		{map[string]string{}, map[string]string{}, tracepb.Status_STATUS_CODE_UNSET, ""},
+	}
+	for i, c := range cases {
+		origin := &zipkinmodel.SpanModel{
+			SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: 1},
+			Name:          "get",
+			LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "frontend"},
+			Tags:          c.tags,
+		}
+		otelSpans, err := Convert2OtelSpan([]*zipkinmodel.SpanModel{origin})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spans, err := ConvertOtel2ZipkinSpans(otelSpans)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{TagOtelStatusCode, TagError, TagStatusCode, TagStatusMsg} {
+			if spans[0].Tags[key] != c.zipkin[key] {
+				t.Errorf("Case %d: Expected the tags %v, Actual: %v", i, c.zipkin, spans[0].Tags)
+				break
+			}
+		}
+		// and back to OTLP
+		status := SpanStatus(spans[0])
+		if status.Code != c.code || status.Message != c.message {
+			t.Errorf("Case %d: Expected %v %q, Actual: %v", i, c.code, c.message, status)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"strconv"
+	"strings"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// legacyTagNames maps the tags of Brave and the other Zipkin instrumentations to
+// the OpenTelemetry semantic conventions.
+var legacyTagNames = map[string]string{
+	TagHTTPMethod:          AttributeHTTPRequestMethod,
+	TagHTTPPath:            AttributeURLPath,
+	TagHTTPURL:             AttributeURLFull,
+	TagHTTPHost:            AttributeServerAddress,
+	TagHTTPStatusCode:      AttributeHTTPResponseStatusCode,
+	TagMVCControllerClass:  AttributeCodeNamespace,
+	TagMVCControllerMethod: AttributeCodeFunction,
+	TagSQLQuery:            AttributeDBQueryText,
+}
+
+// renameLegacyTags renames the legacy tags, the tags already named after the
+// semantic conventions take precedence.
+func renameLegacyTags(tags map[string]string) {
+	for legacy, name := range legacyTagNames {
+		value, ok := tags[legacy]
+		if !ok {
+			continue
+		}
+		delete(tags, legacy)
+		if _, exists := tags[name]; !exists {
+			tags[name] = value
+		}
+	}
+}
+
+// SpanStatus returns the status of the span without modifying its tags.
+func SpanStatus(span *zipkinmodel.SpanModel) *tracepb.Status {
+	return populateSpanStatus(span.Kind, copySpanTags(span.Tags))
+}
+
+// populateSpanStatus derives the status from, in order of precedence, the status
+// tags of OpenTelemetry, the census status code, the HTTP status code, and the
+// error tag, which also holds the message of the otel.status_code tag. The
+// status tags are removed from tags, the HTTP ones are kept as attributes.
+func populateSpanStatus(kind zipkinmodel.Kind, tags map[string]string) *tracepb.Status {
+	status := &tracepb.Status{}
+	if value, ok := tags[TagOtelStatusCode]; ok {
+		status.Code = tracepb.Status_StatusCode(Status_StatusCode_value["STATUS_CODE_"+value])
+		delete(tags, TagOtelStatusCode)
+	} else if value, ok := tags[TagStatusCode]; ok {
+		status.Code = tracepb.Status_StatusCode(Status_StatusCode_value[value])
+		delete(tags, TagStatusCode)
+		if value, ok := tags[TagStatusMsg]; ok {
+			status.Message = value
+			delete(tags, TagStatusMsg)
+		}
+	} else if value, ok := tags[TagZipkinCensusCode]; ok {
+		if code, err := strconv.Atoi(value); err == nil {
+			if code == 0 {
+				status.Code = tracepb.Status_STATUS_CODE_OK
+			} else {
+				status.Code = tracepb.Status_STATUS_CODE_ERROR
+			}
+		}
+		delete(tags, TagZipkinCensusCode)
+		for _, key := range []string{TagZipkinCensusMsg, TagZipkinOpenCensusMsg} {
+			if value, ok := tags[key]; ok {
+				status.Message = value
+				delete(tags, key)
+			}
+		}
+	} else if value, ok := tags[TagHTTPStatusCode]; ok {
+		if code, err := strconv.Atoi(value); err == nil && isHTTPError(kind, code) {
+			status.Code = tracepb.Status_STATUS_CODE_ERROR
+			status.Message = tags[TagHTTPStatusMsg]
+		}
+	}
+
+	if value, ok := tags[TagError]; ok && value != "false" {
+		status.Code = tracepb.Status_STATUS_CODE_ERROR
+		if status.Message == "" && value != "true" {
+			status.Message = value
+		}
+		delete(tags, TagError)
+	}
+
+	return status
+}
+
+// isHTTPError follows the semantic conventions: 5xx are errors on both sides,
+// 4xx only on the client spans.
+func isHTTPError(kind zipkinmodel.Kind, code int) bool {
+	if code >= 500 && code < 600 {
+		return true
+	}
+	return kind == zipkinmodel.Client && code >= 400 && code < 500
+}
+
+// StatusCodeName returns the name of the status code written to SLS, e.g. ERROR.
+func StatusCodeName(code tracepb.Status_StatusCode) string {
+	return strings.TrimPrefix(code.String(), "STATUS_CODE_")
+}
+
+// endpointIPs keys the IPv4 address of the endpoint with ipKey and the IPv6 one
+// with ipv6Key, or with ipKey when the endpoint has no IPv4 address.
+func endpointIPs(endpoint *zipkinmodel.Endpoint, ipKey, ipv6Key string) map[string]string {
+	ips := make(map[string]string, 2)
+	if endpoint.IPv4 != nil {
+		ips[ipKey] = endpoint.IPv4.String()
+	}
+	if endpoint.IPv6 != nil {
+		if endpoint.IPv4 != nil {
+			ips[ipv6Key] = endpoint.IPv6.String()
+		} else {
+			ips[ipKey] = endpoint.IPv6.String()
+		}
+	}
+	return ips
+}
@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"net"
+	"testing"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestPopulateSpanStatus(t *testing.T) {
+	cases := []struct {
+		kind    zipkinmodel.Kind
+		tags    map[string]string
+		code    tracepb.Status_StatusCode
+		message string
+	}{
+		{zipkinmodel.Server, map[string]string{TagHTTPStatusCode: "503", TagHTTPStatusMsg: "Service Unavailable"}, tracepb.Status_STATUS_CODE_ERROR, "Service Unavailable"},
+		{zipkinmodel.Server, map[string]string{TagHTTPStatusCode: "404"}, tracepb.Status_STATUS_CODE_UNSET, ""},
+		{zipkinmodel.Client, map[string]string{TagHTTPStatusCode: "404"}, tracepb.Status_STATUS_CODE_ERROR, ""},
+		{zipkinmodel.Undetermined, map[string]string{TagHTTPStatusCode: "404"}, tracepb.Status_STATUS_CODE_UNSET, ""},
+		{zipkinmodel.Producer, map[string]string{TagHTTPStatusCode: "429"}, tracepb.Status_STATUS_CODE_UNSET, ""},
+		{zipkinmodel.Client, map[string]string{TagZipkinCensusCode: "0"}, tracepb.Status_STATUS_CODE_OK, ""},
+		{zipkinmodel.Client, map[string]string{TagZipkinCensusCode: "14", TagZipkinOpenCensusMsg: "unavailable"}, tracepb.Status_STATUS_CODE_ERROR, "unavailable"},
+		{zipkinmodel.Server, map[string]string{TagError: "NullPointerException"}, tracepb.Status_STATUS_CODE_ERROR, "NullPointerException"},
+		{zipkinmodel.Server, map[string]string{TagError: "false"}, tracepb.Status_STATUS_CODE_UNSET, ""},
+		{zipkinmodel.Server, map[string]string{TagStatusCode: "STATUS_CODE_OK", TagHTTPStatusCode: "500"}, tracepb.Status_STATUS_CODE_OK, ""},
+	}
+
+	for i, c := range cases {
+		status := populateSpanStatus(c.kind, c.tags)
+		if status.Code != c.code || status.Message != c.message {
+			t.Errorf("Case %d: Expected %v %q, Actual: %v %q", i, c.code, c.message, status.Code, status.Message)
+		}
+	}
+}
+
+func TestRenameLegacyTags(t *testing.T) {
+	tags := map[string]string{
+		TagHTTPMethod:              "GET",
+		TagHTTPPath:                "/api",
+		TagSQLQuery:                "select 1",
+		AttributeHTTPRequestMethod: "POST",
+	}
+	renameLegacyTags(tags)
+
+	expected := map[string]string{
+		AttributeHTTPRequestMethod: "POST",
+		AttributeURLPath:           "/api",
+		AttributeDBQueryText:       "select 1",
+	}
+	if len(tags) != len(expected) {
+		t.Fatalf("Expected %v, Actual: %v", expected, tags)
+	}
+	for key, value := range expected {
+		if tags[key] != value {
+			t.Errorf("Tag %s: Expected %s, Actual: %s", key, value, tags[key])
+		}
+	}
+}
+
+func TestEndpointIPs(t *testing.T) {
+	endpoint := &zipkinmodel.Endpoint{IPv4: net.ParseIP("172.16.8.1"), IPv6: net.ParseIP("::1")}
+	ips := endpointIPs(endpoint, AttributeNetHostIP, AttributeNetHostIPv6)
+	if ips[AttributeNetHostIP] != "172.16.8.1" || ips[AttributeNetHostIPv6] != "::1" {
+		t.Errorf("Dual stack: Actual: %v", ips)
+	}
+
+	endpoint.IPv4 = nil
+	ips = endpointIPs(endpoint, AttributeNetHostIP, AttributeNetHostIPv6)
+	if len(ips) != 1 || ips[AttributeNetHostIP] != "::1" {
+		t.Errorf("IPv6 only: Actual: %v", ips)
+	}
+}
@@ -0,0 +1,270 @@
+package converter
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+const (
+	MetricTruncatedSpans = "zipkin_ingester_truncated_spans_total"
+
+	// TruncatedMarker is appended to the truncated tag and annotation values.
+	TruncatedMarker = "...[truncated]"
+
+	// MinSpanSize is the room kept for the dropped count tags when a span is shrunk,
+	// the max span size cannot be smaller.
+	MinSpanSize = 96
+
+	// minTruncatedLength is the length tag values keep before they are dropped to fit the span size.
+	minTruncatedLength = 64
+)
+
+// protectedTags are kept whatever the tag count limit, since the span status depends on them.
+var protectedTags = map[string]bool{
+	TagError:            true,
+	TagStatusCode:       true,
+	TagStatusMsg:        true,
+	TagOtelStatusCode:   true,
+	TagHTTPStatusCode:   true,
+	TagZipkinCensusCode: true,
+	TagSpanKind:         true,
+}
+
+var truncatedSpans = metrics.NewCounter(MetricTruncatedSpans, nil)
+
+// SpanLimits bounds the size of a span, zero means unlimited.
+type SpanLimits struct {
+	MaxTagCount        int
+	MaxTagValueLength  int
+	MaxAnnotationCount int
+	// MaxSpanSize is the max estimated serialized size of a span in bytes.
+	MaxSpanSize int
+}
+
+func (l SpanLimits) Enabled() bool {
+	return l.MaxTagCount > 0 || l.MaxTagValueLength > 0 || l.MaxAnnotationCount > 0 || l.MaxSpanSize > 0
+}
+
+// Apply truncates the span in place: it drops the tags and the annotations over
+// the limits, truncates the long values, and records the dropped counts in the
+// otlp.dropped_attributes_count and otlp.dropped_events_count tags.
+func (l SpanLimits) Apply(span *zipkinmodel.SpanModel) bool {
+	droppedTags, droppedAnnotations, truncated := 0, 0, false
+
+	if l.MaxTagCount > 0 && len(span.Tags) > l.MaxTagCount {
+		keys := sortedTagKeys(span.Tags)
+		kept := 0
+		for _, key := range keys {
+			if protectedTags[key] {
+				kept++
+			}
+		}
+		for _, key := range keys {
+			if protectedTags[key] {
+				continue
+			}
+			if kept < l.MaxTagCount {
+				kept++
+				continue
+			}
+			delete(span.Tags, key)
+			droppedTags++
+		}
+	}
+
+	if l.MaxTagValueLength > 0 {
+		for key, value := range span.Tags {
+			if len(value) > l.MaxTagValueLength {
+				span.Tags[key] = truncate(value, l.MaxTagValueLength)
+				truncated = true
+			}
+		}
+		for i := range span.Annotations {
+			if len(span.Annotations[i].Value) > l.MaxTagValueLength {
+				span.Annotations[i].Value = truncate(span.Annotations[i].Value, l.MaxTagValueLength)
+				truncated = true
+			}
+		}
+	}
+
+	if l.MaxAnnotationCount > 0 && len(span.Annotations) > l.MaxAnnotationCount {
+		droppedAnnotations += len(span.Annotations) - l.MaxAnnotationCount
+		span.Annotations = span.Annotations[:l.MaxAnnotationCount]
+	}
+
+	if l.MaxSpanSize > 0 {
+		tags, annotations, shortened := l.fitSize(span)
+		droppedTags += tags
+		droppedAnnotations += annotations
+		truncated = truncated || shortened
+	}
+
+	if droppedTags == 0 && droppedAnnotations == 0 && !truncated {
+		return false
+	}
+	if droppedTags > 0 || droppedAnnotations > 0 {
+		if span.Tags == nil {
+			span.Tags = make(map[string]string)
+		}
+		if droppedTags > 0 {
+			span.Tags[TagDroppedAttributesCount] = strconv.Itoa(droppedTags + droppedCount(span.Tags, TagDroppedAttributesCount))
+		}
+		if droppedAnnotations > 0 {
+			span.Tags[TagDroppedEventsCount] = strconv.Itoa(droppedAnnotations + droppedCount(span.Tags, TagDroppedEventsCount))
+		}
+	}
+	truncatedSpans.Inc()
+	return true
+}
+
+// fitSize shrinks the span under the max size: the last annotations go first,
+// then the longest tag values are truncated, then dropped.
+func (l SpanLimits) fitSize(span *zipkinmodel.SpanModel) (droppedTags, droppedAnnotations int, truncated bool) {
+	// keep room for the dropped count tags
+	max := l.MaxSpanSize - MinSpanSize
+	if max < 0 {
+		max = 0
+	}
+	size := EstimateSpanSize(span)
+	for size > max && len(span.Annotations) > 0 {
+		last := span.Annotations[len(span.Annotations)-1]
+		span.Annotations = span.Annotations[:len(span.Annotations)-1]
+		size -= annotationSize(last.Value)
+		droppedAnnotations++
+	}
+	if size <= max {
+		return
+	}
+
+	keys := sortedTagKeys(span.Tags)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(span.Tags[keys[i]]) > len(span.Tags[keys[j]])
+	})
+	for _, key := range keys {
+		if size <= max {
+			return
+		}
+		value := span.Tags[key]
+		// the marker is appended to the kept part
+		if target := escapedLen(value) - (size - max) - len(TruncatedMarker); target >= minTruncatedLength {
+			span.Tags[key] = truncate(value, escapedPrefix(value, target))
+			size += tagSize(key, span.Tags[key]) - tagSize(key, value)
+			truncated = true
+			return
+		}
+		if len(value) > minTruncatedLength+len(TruncatedMarker) {
+			span.Tags[key] = truncate(value, escapedPrefix(value, minTruncatedLength))
+			size += tagSize(key, span.Tags[key]) - tagSize(key, value)
+			truncated = true
+		}
+	}
+	for _, key := range keys {
+		if size <= max {
+			return
+		}
+		if protectedTags[key] {
+			continue
+		}
+		size -= tagSize(key, span.Tags[key])
+		delete(span.Tags, key)
+		droppedTags++
+	}
+	return
+}
+
+// EstimateSpanSize approximates the serialized size of the span in bytes,
+// without serializing it. The tags and the annotations are counted as escaped
+// in the JSON fields of the SLS log.
+func EstimateSpanSize(span *zipkinmodel.SpanModel) int {
+	// ids, timestamps, kind and the json structure
+	size := 128 + len(span.Name)
+	for _, endpoint := range []*zipkinmodel.Endpoint{span.LocalEndpoint, span.RemoteEndpoint} {
+		if endpoint != nil {
+			size += len(endpoint.ServiceName) + 48
+		}
+	}
+	for k, v := range span.Tags {
+		size += tagSize(k, v)
+	}
+	for _, annotation := range span.Annotations {
+		size += annotationSize(annotation.Value)
+	}
+	return size
+}
+
+func tagSize(key, value string) int {
+	return escapedLen(key) + escapedLen(value) + 6
+}
+
+func annotationSize(value string) int {
+	return escapedLen(value) + 32
+}
+
+// escapedLen is the length of the value encoded as a JSON string by encoding/json,
+// without the quotes.
+func escapedLen(value string) int {
+	_, encoded := escapedRunes(value, math.MaxInt32)
+	return encoded
+}
+
+// escapedPrefix returns the length in bytes of the longest prefix of the value
+// whose encoded length is at most budget.
+func escapedPrefix(value string, budget int) int {
+	length, _ := escapedRunes(value, budget)
+	return length
+}
+
+// escapedRunes encodes the runes of the value until the budget is spent, it
+// returns their length in bytes and once encoded: <, >, & and the control
+// characters take 6 bytes as \u003c, the quote and the backslash 2 bytes.
+func escapedRunes(value string, budget int) (length, encoded int) {
+	for length < len(value) {
+		b, size, width := value[length], 1, 1
+		switch {
+		case b == '"' || b == '\\' || b == '\n' || b == '\r' || b == '\t':
+			width = 2
+		case b < 0x20 || b == '<' || b == '>' || b == '&':
+			width = 6
+		case b >= utf8.RuneSelf:
+			var r rune
+			r, size = utf8.DecodeRuneInString(value[length:])
+			width = size
+			if (r == utf8.RuneError && size == 1) || r == '\u2028' || r == '\u2029' {
+				width = 6
+			}
+		}
+		if encoded+width > budget {
+			break
+		}
+		length += size
+		encoded += width
+	}
+	return length, encoded
+}
+
+// truncate cuts the value to at most length bytes on a rune boundary and appends the marker.
+func truncate(value string, length int) string {
+	for length > 0 && !utf8.RuneStart(value[length]) {
+		length--
+	}
+	return value[:length] + TruncatedMarker
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func droppedCount(tags map[string]string, key string) int {
+	count, _ := strconv.Atoi(tags[key])
+	return count
+}
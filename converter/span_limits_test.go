@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func limitedSpan() *zipkinmodel.SpanModel {
+	span := &zipkinmodel.SpanModel{
+		SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: 2},
+		Name:          "get",
+		Timestamp:     time.Unix(1659409534, 0),
+		LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "order"},
+		Tags:          map[string]string{TagError: "timeout"},
+	}
+	for i := 0; i < 10; i++ {
+		span.Tags[fmt.Sprintf("tag.%d", i)] = strings.Repeat("v", 10)
+		span.Annotations = append(span.Annotations, zipkinmodel.Annotation{Timestamp: span.Timestamp, Value: fmt.Sprintf("event %d", i)})
+	}
+	return span
+}
+
+func TestSpanLimitsCounts(t *testing.T) {
+	span := limitedSpan()
+	if !(SpanLimits{MaxTagCount: 4, MaxAnnotationCount: 3}).Apply(span) {
+		t.Fatal("The span should be truncated")
+	}
+
+	if _, ok := span.Tags[TagError]; !ok {
+		t.Error("The error tag is protected")
+	}
+	for _, key := range []string{"tag.0", "tag.1", "tag.2"} {
+		if _, ok := span.Tags[key]; !ok {
+			t.Errorf("Expected the tag %s to be kept", key)
+		}
+	}
+	if _, ok := span.Tags["tag.3"]; ok {
+		t.Error("Expected tag.3 to be dropped")
+	}
+	if span.Tags[TagDroppedAttributesCount] != "7" {
+		t.Errorf("Expected 7 dropped attributes, Actual: %s", span.Tags[TagDroppedAttributesCount])
+	}
+	if len(span.Annotations) != 3 || span.Tags[TagDroppedEventsCount] != "7" {
+		t.Errorf("Expected 3 annotations and 7 dropped, Actual: %d, %s", len(span.Annotations), span.Tags[TagDroppedEventsCount])
+	}
+}
+
+func TestSpanLimitsValueLength(t *testing.T) {
+	span := limitedSpan()
+	span.Tags["sql"] = "select * from 订单 where id = 1"
+	if !(SpanLimits{MaxTagValueLength: 19}).Apply(span) {
+		t.Fatal("The span should be truncated")
+	}
+	// the cut falls in the middle of a multi-byte rune
+	if span.Tags["sql"] != "select * from 订"+TruncatedMarker {
+		t.Errorf("Unexpected truncated value %q", span.Tags["sql"])
+	}
+	if _, ok := span.Tags[TagDroppedAttributesCount]; ok {
+		t.Error("No tag is dropped")
+	}
+	if (SpanLimits{MaxTagValueLength: 100}).Apply(limitedSpan()) {
+		t.Error("The span is under the limits")
+	}
+}
+
+func TestSpanLimitsSize(t *testing.T) {
+	span := limitedSpan()
+	span.Tags["http.response.body"] = strings.Repeat("x", 4096)
+	limits := SpanLimits{MaxSpanSize: 1024}
+	limits.Apply(span)
+
+	if size := EstimateSpanSize(span); size > limits.MaxSpanSize {
+		t.Errorf("Expected the span under %d bytes, Actual: %d", limits.MaxSpanSize, size)
+	}
+	if len(span.Annotations) != 0 || span.Tags[TagDroppedEventsCount] != "10" {
+		t.Errorf("Expected the annotations to be dropped first, Actual: %d", len(span.Annotations))
+	}
+	if body := span.Tags["http.response.body"]; !strings.HasSuffix(body, TruncatedMarker) {
+		t.Errorf("Expected the longest tag to be truncated, Actual length: %d", len(body))
+	}
+	if span.Tags["tag.9"] != strings.Repeat("v", 10) {
+		t.Error("The short tags should be kept")
+	}
+}
+
+func TestSpanLimitsSizeCountsTheMarker(t *testing.T) {
+	span := &zipkinmodel.SpanModel{Name: "get", Tags: map[string]string{"http.response.body": strings.Repeat("x", 4096)}}
+	limits := SpanLimits{MaxSpanSize: 1024}
+	limits.Apply(span)
+
+	if size := EstimateSpanSize(span); size > limits.MaxSpanSize-MinSpanSize {
+		t.Errorf("Expected the span under %d bytes, Actual: %d", limits.MaxSpanSize-MinSpanSize, size)
+	}
+	if body := span.Tags["http.response.body"]; !strings.HasSuffix(body, TruncatedMarker) {
+		t.Errorf("Expected the tag to be truncated, Actual length: %d", len(body))
+	}
+
+	// a max size smaller than the room of the dropped counts keeps the protected tags only
+	span = limitedSpan()
+	span.Tags[TagError] = "true"
+	(SpanLimits{MaxSpanSize: 50}).Apply(span)
+	if span.Tags[TagError] != "true" || span.Tags["tag.0"] != "" || len(span.Annotations) != 0 {
+		t.Errorf("Expected only the protected tags to be kept, Actual: %v", span.Tags)
+	}
+}
+
+func TestSpanLimitsSizeCountsTheEscaping(t *testing.T) {
+	span := &zipkinmodel.SpanModel{Name: "get", Tags: map[string]string{
+		"http.response.body": strings.Repeat("<a href=\"x\">&</a>\n", 256),
+		"sql.query":          "select 1",
+	}}
+	encoded, _ := json.Marshal(span.Tags)
+	if size := EstimateSpanSize(span); size < len(encoded) {
+		t.Errorf("Expected the escaped tags to be counted, Actual: %d < %d", size, len(encoded))
+	}
+
+	limits := SpanLimits{MaxSpanSize: 1024}
+	limits.Apply(span)
+	if encoded, _ = json.Marshal(span.Tags); len(encoded) > limits.MaxSpanSize-MinSpanSize {
+		t.Errorf("Expected the encoded tags under %d bytes, Actual: %d", limits.MaxSpanSize-MinSpanSize, len(encoded))
+	}
+	if body := span.Tags["http.response.body"]; !strings.HasSuffix(body, TruncatedMarker) || span.Tags["sql.query"] != "select 1" {
+		t.Errorf("Expected the body to be truncated, Actual: %v", span.Tags)
+	}
+	if actual := escapedLen("a<\"\u2028é\x01"); actual != 1+6+2+6+2+6 {
+		t.Errorf("Expected %d, Actual: %d", 1+6+2+6+2+6, actual)
+	}
+}
+
+func TestSpanLimitsOtlpDroppedCounts(t *testing.T) {
+	span := limitedSpan()
+	(SpanLimits{MaxTagCount: 4, MaxAnnotationCount: 3}).Apply(span)
+
+	resourceSpans, err := Convert2OtelSpan([]*zipkinmodel.SpanModel{span})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otlpSpan := resourceSpans[0].InstrumentationLibrarySpans[0].Spans[0]
+	if otlpSpan.DroppedAttributesCount != 7 || otlpSpan.DroppedEventsCount != 7 {
+		t.Errorf("Expected 7 dropped attributes and events, Actual: %d, %d", otlpSpan.DroppedAttributesCount, otlpSpan.DroppedEventsCount)
+	}
+	for _, attr := range otlpSpan.Attributes {
+		if attr.Key == TagDroppedAttributesCount || attr.Key == TagDroppedEventsCount {
+			t.Errorf("The dropped count %s should not be an attribute", attr.Key)
+		}
+	}
+
+	back, err := ConvertOtel2ZipkinSpans(resourceSpans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back[0].Tags[TagDroppedAttributesCount] != "7" || back[0].Tags[TagDroppedEventsCount] != "7" {
+		t.Errorf("The dropped counts are lost in the round trip: %v", back[0].Tags)
+	}
+}
@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+type TimestampPolicy string
+
+const (
+	// TimestampPolicyDrop drops the spans without timestamp.
+	TimestampPolicyDrop TimestampPolicy = "drop"
+	// TimestampPolicyKafka fills the missing timestamp from the kafka message timestamp.
+	TimestampPolicyKafka TimestampPolicy = "kafka"
+	// TimestampPolicyAnnotation fills the missing timestamp from the earliest annotation.
+	TimestampPolicyAnnotation TimestampPolicy = "annotation"
+
+	MetricTimestampFilled  = "zipkin_ingester_timestamp_filled_total"
+	MetricTimestampDropped = "zipkin_ingester_timestamp_dropped_total"
+	MetricInvalidSpans     = "zipkin_ingester_invalid_spans_total"
+)
+
+func ParseTimestampPolicy(policy string) (TimestampPolicy, error) {
+	switch p := TimestampPolicy(strings.ToLower(policy)); p {
+	case "":
+		return TimestampPolicyDrop, nil
+	case TimestampPolicyDrop, TimestampPolicyKafka, TimestampPolicyAnnotation:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown timestamp policy %q", policy)
+	}
+}
+
+// TimestampConverter applies a TimestampPolicy to the spans parsed by the underlying converter.
+type TimestampConverter struct {
+	converter Converter
+	policy    TimestampPolicy
+	filled    *metrics.Counter
+	dropped   *metrics.Counter
+	invalid   *metrics.Counter
+}
+
+func NewTimestampConverter(converter Converter, policy TimestampPolicy) *TimestampConverter {
+	labels := map[string]string{"policy": string(policy)}
+	return &TimestampConverter{
+		converter: converter,
+		policy:    policy,
+		filled:    metrics.NewCounter(MetricTimestampFilled, labels),
+		dropped:   metrics.NewCounter(MetricTimestampDropped, labels),
+		invalid:   metrics.NewCounter(MetricInvalidSpans, nil),
+	}
+}
+
+func (c *TimestampConverter) ParseSpans(protoBlob []byte, debugWasSet bool) (zss []*zipkinmodel.SpanModel, err error) {
+	return c.ParseMessage(protoBlob, debugWasSet, time.Time{})
+}
+
+// ParseMessage parses the spans of a message received at messageTime. The valid
+// spans of a partially invalid message are returned with a *PartialError.
+func (c *TimestampConverter) ParseMessage(protoBlob []byte, debugWasSet bool, messageTime time.Time) (zss []*zipkinmodel.SpanModel, err error) {
+	zss, err = c.converter.ParseSpans(protoBlob, debugWasSet)
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		c.invalid.Add(uint64(len(partial.Spans)))
+	}
+	if err != nil && len(zss) == 0 {
+		return nil, err
+	}
+	return c.FixTimestamps(zss, messageTime), err
+}
+
+// FixTimestamps fills or drops the spans with zero or missing timestamp.
+func (c *TimestampConverter) FixTimestamps(spans []*zipkinmodel.SpanModel, messageTime time.Time) []*zipkinmodel.SpanModel {
+	result := spans[:0]
+	for _, span := range spans {
+		if span == nil {
+			continue
+		}
+		if hasTimestamp(span.Timestamp) {
+			result = append(result, span)
+			continue
+		}
+
+		var fallback time.Time
+		switch c.policy {
+		case TimestampPolicyKafka:
+			fallback = messageTime
+		case TimestampPolicyAnnotation:
+			fallback = earliestAnnotation(span)
+		}
+
+		if hasTimestamp(fallback) {
+			span.Timestamp = fallback
+			c.filled.Inc()
+			result = append(result, span)
+		} else {
+			c.dropped.Inc()
+		}
+	}
+	return result
+}
+
+func hasTimestamp(t time.Time) bool {
+	return !t.IsZero() && t.UnixNano() > 0
+}
+
+func earliestAnnotation(span *zipkinmodel.SpanModel) (earliest time.Time) {
+	for _, anno := range span.Annotations {
+		if hasTimestamp(anno.Timestamp) && (earliest.IsZero() || anno.Timestamp.Before(earliest)) {
+			earliest = anno.Timestamp
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func TestFixTimestamps(t *testing.T) {
+	messageTime := time.Unix(1659409534, 0)
+	annotationTime := time.Unix(1659409533, 0)
+	newSpans := func() []*zipkinmodel.SpanModel {
+		return []*zipkinmodel.SpanModel{
+			{Name: "valid", Timestamp: time.Unix(1659409530, 0)},
+			{Name: "annotated", Annotations: []zipkinmodel.Annotation{
+				{Timestamp: annotationTime.Add(time.Second), Value: "ws"},
+				{Timestamp: annotationTime, Value: "wr"},
+			}},
+			{Name: "epoch", Timestamp: time.Unix(0, 0)},
+		}
+	}
+
+	cases := []struct {
+		policy   TimestampPolicy
+		expected map[string]time.Time
+	}{
+		{TimestampPolicyDrop, map[string]time.Time{"valid": time.Unix(1659409530, 0)}},
+		{TimestampPolicyKafka, map[string]time.Time{"valid": time.Unix(1659409530, 0), "annotated": messageTime, "epoch": messageTime}},
+		{TimestampPolicyAnnotation, map[string]time.Time{"valid": time.Unix(1659409530, 0), "annotated": annotationTime}},
+	}
+
+	for _, c := range cases {
+		spans := NewTimestampConverter(nil, c.policy).FixTimestamps(newSpans(), messageTime)
+		if len(spans) != len(c.expected) {
+			t.Errorf("Policy %s: Expected %d spans, Actual: %d", c.policy, len(c.expected), len(spans))
+			continue
+		}
+		for _, span := range spans {
+			if !span.Timestamp.Equal(c.expected[span.Name]) {
+				t.Errorf("Policy %s, span %s: Expected %v, Actual: %v", c.policy, span.Name, c.expected[span.Name], span.Timestamp)
+			}
+		}
+	}
+}
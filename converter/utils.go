@@ -5,7 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"regexp"
+	"strings"
 	"time"
 )
 
@@ -69,53 +69,84 @@ func getNonSpanAttributes() map[string]struct{} {
 	return attrs
 }
 
-var complexAttrValDescriptions = getComplexAttrValDescripts()
-var attrValDescriptions = getAttrValDescripts()
-
-type attrValDescript struct {
-	regex    *regexp.Regexp
-	attrType AttributeValueType
+// DetermineValueType classifies a tag value the way the regular expressions
+// ^$, ^-?\d+$, ^-?\d+\.\d+$, ^(true|false)$, ^\{"\w+":.+\}$ and ^\[.*\]$ did,
+// without running them. omitSimpleTypes only looks for the maps and the arrays.
+func DetermineValueType(value string, omitSimpleTypes bool) AttributeValueType {
+	if !omitSimpleTypes {
+		if value == "" {
+			return AttributeValueNULL
+		}
+		if value == "true" || value == "false" {
+			return AttributeValueBOOL
+		}
+		if valueType, ok := numberValueType(value); ok {
+			return valueType
+		}
+	}
+	if isMapValue(value) {
+		return AttributeValueMAP
+	}
+	if isArrayValue(value) {
+		return AttributeValueARRAY
+	}
+	return AttributeValueSTRING
 }
 
-func getComplexAttrValDescripts() []*attrValDescript {
-	descriptions := getAttrValDescripts()
-	return descriptions[4:]
+// numberValueType matches -?\d+ and -?\d+\.\d+.
+func numberValueType(value string) (AttributeValueType, bool) {
+	i := 0
+	if value[0] == '-' {
+		i++
+	}
+	integer := skipDigits(value, i)
+	if integer == i {
+		return 0, false
+	}
+	if integer == len(value) {
+		return AttributeValueINT, true
+	}
+	if value[integer] != '.' {
+		return 0, false
+	}
+	fraction := skipDigits(value, integer+1)
+	if fraction == integer+1 || fraction != len(value) {
+		return 0, false
+	}
+	return AttributeValueDOUBLE, true
 }
 
-func getAttrValDescripts() []*attrValDescript {
-	descriptions := make([]*attrValDescript, 0, 5)
-	descriptions = append(descriptions, constructAttrValDescript("^$", AttributeValueNULL))
-	descriptions = append(descriptions, constructAttrValDescript(`^-?\d+$`, AttributeValueINT))
-	descriptions = append(descriptions, constructAttrValDescript(`^-?\d+\.\d+$`, AttributeValueDOUBLE))
-	descriptions = append(descriptions, constructAttrValDescript(`^(true|false)$`, AttributeValueBOOL))
-	descriptions = append(descriptions, constructAttrValDescript(`^\{"\w+":.+\}$`, AttributeValueMAP))
-	descriptions = append(descriptions, constructAttrValDescript(`^\[.*\]$`, AttributeValueARRAY))
-	return descriptions
+func skipDigits(value string, i int) int {
+	for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+		i++
+	}
+	return i
 }
 
-func constructAttrValDescript(regex string, attrType AttributeValueType) *attrValDescript {
-	regexc := regexp.MustCompile(regex)
-	return &attrValDescript{
-		regex:    regexc,
-		attrType: attrType,
+// isMapValue matches \{"\w+":.+\}, the dot does not match the line feeds.
+func isMapValue(value string) bool {
+	if !strings.HasPrefix(value, `{"`) || value[len(value)-1] != '}' {
+		return false
 	}
+	i := 2
+	for i < len(value) && isWordChar(value[i]) {
+		i++
+	}
+	if i == 2 || !strings.HasPrefix(value[i:], `":`) {
+		return false
+	}
+	rest := value[i+2 : len(value)-1]
+	return i+2 < len(value)-1 && strings.IndexByte(rest, '\n') < 0
 }
 
-func DetermineValueType(value string, omitSimpleTypes bool) AttributeValueType {
-	if omitSimpleTypes {
-		for _, desc := range complexAttrValDescriptions {
-			if desc.regex.MatchString(value) {
-				return desc.attrType
-			}
-		}
-	} else {
-		for _, desc := range attrValDescriptions {
-			if desc.regex.MatchString(value) {
-				return desc.attrType
-			}
-		}
-	}
-	return AttributeValueSTRING
+// isArrayValue matches \[.*\].
+func isArrayValue(value string) bool {
+	return len(value) >= 2 && value[0] == '[' && value[len(value)-1] == ']' &&
+		strings.IndexByte(value[1:len(value)-1], '\n') < 0
+}
+
+func isWordChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
 }
 
 type AttributeValueType int
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+// regexpValueTypes are the expressions DetermineValueType used to run, in order.
+var regexpValueTypes = []struct {
+	regex     *regexp.Regexp
+	valueType AttributeValueType
+}{
+	{regexp.MustCompile(`^$`), AttributeValueNULL},
+	{regexp.MustCompile(`^-?\d+$`), AttributeValueINT},
+	{regexp.MustCompile(`^-?\d+\.\d+$`), AttributeValueDOUBLE},
+	{regexp.MustCompile(`^(true|false)$`), AttributeValueBOOL},
+	{regexp.MustCompile(`^\{"\w+":.+\}$`), AttributeValueMAP},
+	{regexp.MustCompile(`^\[.*\]$`), AttributeValueARRAY},
+}
+
+func regexpValueType(value string, omitSimpleTypes bool) AttributeValueType {
+	descriptions := regexpValueTypes
+	if omitSimpleTypes {
+		descriptions = descriptions[4:]
+	}
+	for _, desc := range descriptions {
+		if desc.regex.MatchString(value) {
+			return desc.valueType
+		}
+	}
+	return AttributeValueSTRING
+}
+
+func assertSameValueType(t *testing.T, value string) {
+	t.Helper()
+	for _, omitSimpleTypes := range []bool{false, true} {
+		if expected, actual := regexpValueType(value, omitSimpleTypes), DetermineValueType(value, omitSimpleTypes); expected != actual {
+			t.Errorf("%q (omitSimpleTypes %v): Expected %v, Actual: %v", value, omitSimpleTypes, expected, actual)
+		}
+	}
+}
+
+func TestDetermineValueType(t *testing.T) {
+	values := []string{
+		"", "a", "0", "-", "-0", "--1", "12", "-12", "1.", ".5", "1.5", "-1.5", "1.5.2", "1e5", "+1", "1 ", "١٢",
+		"true", "false", "True", "truex", " true",
+		`{"a":1}`, `{"a":}`, `{"a"}`, `{"":1}`, `{"a_1":{"b":2}}`, `{"a-b":1}`, `{"a":"b"`, `{"é":1}`, `{"a":` + "\n" + `}`,
+		`{"a":é}`, `{"a":` + "\xff" + `}`, "{\"a\":1}\n", "{", `{"`, `{"a":1}}`,
+		"[]", "[1,2]", "[", "]", "[\n]", "[\xff]", "[1]\n", "x[1]",
+	}
+	for _, value := range values {
+		assertSameValueType(t, value)
+	}
+
+	alphabet := []byte("{}[]\":-.0123456789aefltrsu_é\n\xff ")
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		value := make([]byte, random.Intn(8))
+		for j := range value {
+			value[j] = alphabet[random.Intn(len(alphabet))]
+		}
+		assertSameValueType(t, string(value))
+	}
+	for i := 0; i < 20000; i++ {
+		value := []byte(values[random.Intn(len(values))])
+		if len(value) > 0 {
+			value[random.Intn(len(value))] = alphabet[random.Intn(len(alphabet))]
+		}
+		assertSameValueType(t, string(value))
+	}
+}
@@ -9,7 +9,10 @@ import (
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	"github.com/spf13/cast"
 	v11 "go.opentelemetry.io/proto/otlp/common/v1"
+	"go.uber.org/zap"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 func ToSLSSpans(spans []*zipkinmodel.SpanModel) (lg *slsSdk.LogGroup, err error) {
@@ -28,22 +31,39 @@ func ToSLSSpans(spans []*zipkinmodel.SpanModel) (lg *slsSdk.LogGroup, err error)
 	return lg, nil
 }
 
-func SendToSls(spans []*zipkinmodel.SpanModel, instance *producer.Producer, callback producer.CallBack, project string, log string) error {
+// SendToSls converts the spans and hands them to the producer, it returns once
+// all of them are queued and reports how many were queued.
+func SendToSls(spans []*zipkinmodel.SpanModel, instance *producer.Producer, callback producer.CallBack, project string, log string, sugar *zap.SugaredLogger) (int, error) {
+	var wg sync.WaitGroup
+	var queued int64
+	wg.Add(len(spans))
 	for _, span := range spans {
-		go convertAndSend(span, instance, callback, project, log)
+		go func(span *zipkinmodel.SpanModel) {
+			defer wg.Done()
+			if convertAndSend(span, instance, callback, project, log, sugar) {
+				atomic.AddInt64(&queued, 1)
+			}
+		}(span)
 	}
+	wg.Wait()
 
-	return nil
+	if int(queued) < len(spans) {
+		return int(queued), fmt.Errorf("%d of %d spans were not sent", len(spans)-int(queued), len(spans))
+	}
+	return int(queued), nil
 }
 
-func convertAndSend(span *zipkinmodel.SpanModel, instance *producer.Producer, callback producer.CallBack, project string, traceLogstore string) {
+func convertAndSend(span *zipkinmodel.SpanModel, instance *producer.Producer, callback producer.CallBack, project string, traceLogstore string, sugar *zap.SugaredLogger) bool {
 	if log, err := spanToLog(span); err == nil {
 		error := instance.SendLogWithCallBack(project, traceLogstore, "0.0.0.0", "", log, callback)
 		if error != nil {
-			fmt.Printf("%v", error)
+			sugar.Warnw("Failed to queue the span", "exception", error, "logstore", traceLogstore, "traceID", span.TraceID, "spanID", span.ID)
+			return false
 		}
+		return true
 	} else {
-		fmt.Printf("%v, %v\n", err, span)
+		sugar.Warnw("Failed to convert the span", "exception", err, "traceID", span.TraceID, "spanID", span.ID)
+		return false
 	}
 }
 
@@ -61,6 +81,7 @@ func spanToLog(span *zipkinmodel.SpanModel) (*slsSdk.Log, error) {
 func ToSLSSpan(span *zipkinmodel.SpanModel) ([]*slsSdk.LogContent, error) {
 	contents := make([]*slsSdk.LogContent, 0)
 	tags := copySpanTags(span.Tags)
+	defer releaseSpanTags(tags, len(span.Tags))
 	localServiceName := extractLocalServiceName(span)
 	contents = appendAttributeToLogContent(contents, OperationName, span.Name)
 	contents = appendAttributeToLogContent(contents, StartTime, cast.ToString(span.Timestamp.UnixNano()/1000))
@@ -75,11 +96,10 @@ func ToSLSSpan(span *zipkinmodel.SpanModel) ([]*slsSdk.LogContent, error) {
 		contents = appendAttributeToLogContent(contents, Resource, string(resource))
 	}
 
-	if tags[TagStatusCode] != "" {
-		contents = appendAttributeToLogContent(contents, StatusCode, tags[TagStatusCode])
-	} else {
-		contents = appendAttributeToLogContent(contents, StatusCode, "UNSET")
-	}
+	status := populateSpanStatus(span.Kind, tags)
+	contents = appendAttributeToLogContent(contents, StatusCode, StatusCodeName(status.Code))
+	contents = appendAttributeToLogContent(contents, StatusMessage, status.Message)
+	renameLegacyTags(tags)
 
 	if span.ParentID != nil {
 		contents = appendAttributeToLogContent(contents, ParentSpanID, span.ParentID.String())
@@ -118,7 +138,7 @@ func extractResource(tags map[string]string, localServiceName string) ([]byte, e
 	}
 	delete(tags, TagServiceNameSource)
 
-	for key := range getNonSpanAttributes() {
+	for key := range nonSpanAttributes {
 		if key == TagInstrumentationName || key == TagInstrumentationVersion {
 			continue
 		}
@@ -132,7 +152,7 @@ func extractResource(tags map[string]string, localServiceName string) ([]byte, e
 }
 
 func extractLogs(zspan *zipkinmodel.SpanModel) ([]byte, error) {
-	slsLogs := make([]*SpanLog, len(zspan.Annotations))
+	slsLogs := make([]*SpanLog, 0, len(zspan.Annotations))
 	for _, anno := range zspan.Annotations {
 		event := &SpanLog{
 			Attribute: make(map[string]interface{}),
@@ -143,6 +163,7 @@ func extractLogs(zspan *zipkinmodel.SpanModel) ([]byte, error) {
 		partCnt := len(parts)
 		event.Attribute["Name"] = parts[0]
 		if partCnt < 3 {
+			slsLogs = append(slsLogs, event)
 			continue
 		}
 
@@ -181,31 +202,22 @@ func extractTags(zspan *zipkinmodel.SpanModel, tags map[string]string) ([]byte,
 	}
 
 	if zspan.LocalEndpoint != nil {
-		if zspan.LocalEndpoint.IPv4 != nil {
-			result[AttributeNetHostIP] = zspan.LocalEndpoint.IPv4.String()
-		}
-		if zspan.LocalEndpoint.IPv6 != nil {
-			result[AttributeNetHostIP] = zspan.LocalEndpoint.IPv6.String()
+		for key, ip := range endpointIPs(zspan.LocalEndpoint, AttributeNetHostIP, AttributeNetHostIPv6) {
+			result[key] = ip
 		}
 		if zspan.LocalEndpoint.Port > 0 {
 			result[AttributeNetHostPort] = zspan.LocalEndpoint.Port
 		}
 	}
 	if zspan.RemoteEndpoint != nil {
-		attr := &v11.KeyValue{}
-		attr.Key = AttributeNetHostIP
-
 		if zspan.RemoteEndpoint.ServiceName != "" {
 			result[AttributePeerService] = zspan.RemoteEndpoint.ServiceName
 		}
-		if zspan.RemoteEndpoint.IPv4 != nil {
-			result[AttributeNetPeerIP] = zspan.RemoteEndpoint.IPv4.String()
-		}
-		if zspan.RemoteEndpoint.IPv6 != nil {
-			result[AttributeNetPeerIP] = zspan.RemoteEndpoint.IPv6.String()
+		for key, ip := range endpointIPs(zspan.RemoteEndpoint, AttributeNetPeerIP, AttributeNetPeerIPv6) {
+			result[key] = ip
 		}
 		if zspan.RemoteEndpoint.Port > 0 {
-			result[AttributeNetPeerIP] = zspan.RemoteEndpoint.Port
+			result[AttributeNetPeerPort] = zspan.RemoteEndpoint.Port
 		}
 	}
 	return json.Marshal(result)
@@ -214,8 +226,7 @@ func extractTags(zspan *zipkinmodel.SpanModel, tags map[string]string) ([]byte,
 func extractLinks(tags map[string]string) ([]byte, error) {
 	links := make([]map[string]string, 0)
 
-	for i := 0; i < 128; i++ {
-		key := fmt.Sprintf("otlp.link.%d", i)
+	for _, key := range linkTagKeys {
 		val, ok := tags[key]
 		if !ok {
 			return []byte("[]"), nil
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
@@ -11,6 +12,31 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+)
+
+const (
+	// maxSpanLinks is the number of otlp.link.<n> tags looked up on a span.
+	maxSpanLinks = 128
+	// maxPooledTags bounds the size of the tag maps kept in tagMapPool.
+	maxPooledTags = 64
+)
+
+var (
+	// linkTagKeys are the otlp.link.<n> tag keys, built once rather than for every span.
+	linkTagKeys = func() (keys [maxSpanLinks]string) {
+		for i := range keys {
+			keys[i] = fmt.Sprintf("otlp.link.%d", i)
+		}
+		return keys
+	}()
+
+	// tagMapPool holds the emptied copies of the span tags, see copySpanTags.
+	tagMapPool = sync.Pool{
+		New: func() interface{} {
+			return make(map[string]string)
+		},
+	}
 )
 
 type byOTLPTypes []*zipkinmodel.SpanModel
@@ -70,14 +96,13 @@ func Convert2OtelSpan(spans []*zipkinmodel.SpanModel) (data []*tracepb.ResourceS
 			curlIlSpans.InstrumentationLibrary = populateILFromZipkinSpan(tags, instrLibName)
 			count++
 		}
-		if curSpan, err := zSpanToInternal(zspan, tags, true); err != nil {
-			if err != nil {
-				return trace, err
-			}
-		} else {
-			if curSpan != nil {
-				curlIlSpans.Spans = append(curlIlSpans.Spans, curSpan)
-			}
+		curSpan, err := zSpanToInternal(zspan, tags, true)
+		releaseSpanTags(tags, len(zspan.Tags))
+		if err != nil {
+			return trace, err
+		}
+		if curSpan != nil {
+			curlIlSpans.Spans = append(curlIlSpans.Spans, curSpan)
 		}
 	}
 
@@ -87,15 +112,21 @@ func Convert2OtelSpan(spans []*zipkinmodel.SpanModel) (data []*tracepb.ResourceS
 func zSpanToInternal(zspan *zipkinmodel.SpanModel, tags map[string]string, parseStringTags bool) (*tracepb.Span, error) {
 	dest := &tracepb.Span{}
 
-	dest.TraceId = UInt64ToTraceID(zspan.TraceID.High, zspan.TraceID.Low)
-	dest.SpanId = UInt64ToSpanID(uint64(zspan.ID))
+	// a single buffer backs the trace, span and parent ids
+	ids := make([]byte, 32)
+	binary.BigEndian.PutUint64(ids[0:8], zspan.TraceID.High)
+	binary.BigEndian.PutUint64(ids[8:16], zspan.TraceID.Low)
+	binary.BigEndian.PutUint64(ids[16:24], uint64(zspan.ID))
+	dest.TraceId = ids[0:16:16]
+	dest.SpanId = ids[16:24:24]
 	if value, ok := tags[TagW3CTraceState]; ok {
 		dest.TraceState = value
 		delete(tags, TagW3CTraceState)
 	}
 	parentID := zspan.ParentID
 	if parentID != nil && *parentID != zspan.ID {
-		dest.ParentSpanId = UInt64ToSpanID(uint64(*parentID))
+		binary.BigEndian.PutUint64(ids[24:32], uint64(*parentID))
+		dest.ParentSpanId = ids[24:32:32]
 	}
 
 	dest.Name = zspan.Name
@@ -103,7 +134,10 @@ func zSpanToInternal(zspan *zipkinmodel.SpanModel, tags map[string]string, parse
 	dest.EndTimeUnixNano = TimestampFromTime(zspan.Timestamp.Add(zspan.Duration))
 	dest.Kind = zipkinKindToSpanKind(zspan.Kind, tags)
 
-	dest.Status = populateSpanStatus(tags)
+	dest.Status = populateSpanStatus(zspan.Kind, tags)
+	dest.DroppedAttributesCount = popDroppedCount(tags, TagDroppedAttributesCount)
+	dest.DroppedEventsCount = popDroppedCount(tags, TagDroppedEventsCount)
+	renameLegacyTags(tags)
 	if link, err := zTagsToSpanLinks(tags); err != nil {
 		return dest, err
 	} else {
@@ -120,25 +154,14 @@ func zSpanToInternal(zspan *zipkinmodel.SpanModel, tags map[string]string, parse
 	return dest, nil
 }
 
-func populateSpanStatus(tags map[string]string) *tracepb.Status {
-	status := &tracepb.Status{}
-	if value, ok := tags[TagStatusCode]; ok {
-		status.Code = tracepb.Status_StatusCode(Status_StatusCode_value[value])
-		delete(tags, TagStatusCode)
-		if value, ok := tags[TagStatusMsg]; ok {
-			status.Message = value
-			delete(tags, TagStatusMsg)
-		}
-	}
-
-	if val, ok := tags[TagError]; ok {
-		if val == "true" {
-			status.Code = tracepb.Status_STATUS_CODE_ERROR
-			delete(tags, TagError)
-		}
+func popDroppedCount(tags map[string]string, key string) uint32 {
+	value, ok := tags[key]
+	if !ok {
+		return 0
 	}
-
-	return status
+	delete(tags, key)
+	count, _ := strconv.ParseUint(value, 10, 32)
+	return uint32(count)
 }
 
 func zipkinKindToSpanKind(kind zipkinmodel.Kind, tags map[string]string) tracepb.Span_SpanKind {
@@ -164,11 +187,10 @@ func zipkinKindToSpanKind(kind zipkinmodel.Kind, tags map[string]string) tracepb
 
 func zTagsToSpanLinks(tags map[string]string) ([]*tracepb.Span_Link, error) {
 	var links []*tracepb.Span_Link
-	for i := 0; i < 128; i++ {
-		key := fmt.Sprintf("otlp.link.%d", i)
+	for _, key := range linkTagKeys {
 		val, ok := tags[key]
 		if !ok {
-			return nil, nil
+			break
 		}
 		delete(tags, key)
 
@@ -221,15 +243,27 @@ func zTagsToSpanLinks(tags map[string]string) ([]*tracepb.Span_Link, error) {
 }
 
 func populateSpanEvents(zspan *zipkinmodel.SpanModel) (data []*tracepb.Span_Event, e error) {
-	data = make([]*tracepb.Span_Event, len(zspan.Annotations))
-	for _, anno := range zspan.Annotations {
-		event := &tracepb.Span_Event{}
+	data = make([]*tracepb.Span_Event, 0, len(zspan.Annotations))
+	events := make([]tracepb.Span_Event, len(zspan.Annotations))
+	for i, anno := range zspan.Annotations {
+		event := &events[i]
 		event.TimeUnixNano = TimestampFromTime(anno.Timestamp)
 
+		// the annotations without attributes are not split
+		if strings.Count(anno.Value, "|") < 2 {
+			event.Name = anno.Value
+			if end := strings.IndexByte(anno.Value, '|'); end >= 0 {
+				event.Name = anno.Value[:end]
+			}
+			data = append(data, event)
+			continue
+		}
+
 		parts := strings.Split(anno.Value, "|")
 		partCnt := len(parts)
 		event.Name = parts[0]
 		if partCnt < 3 {
+			data = append(data, event)
 			continue
 		}
 
@@ -275,90 +309,82 @@ func jsonMapToAttributeMap(attrs map[string]interface{}) (data []*v11.KeyValue,
 			}
 		} else if b, ok := val.(bool); ok {
 			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_BoolValue{BoolValue: b}}
+		} else {
+			continue
 		}
-
+		data = append(data, attr)
 	}
 	return data, nil
 }
 
 func zTagsToInternalAttrs(zspan *zipkinmodel.SpanModel, tags map[string]string, parseStringTags bool) (data []*v11.KeyValue) {
-	data = tagsToAttributeMap(tags, parseStringTags)
+	// the tags and at most three attributes per endpoint
+	attrs := newKeyValues(len(tags) + 6)
+	data = tagsToAttributeMap(tags, parseStringTags, &attrs)
 	if zspan.LocalEndpoint != nil {
-		if zspan.LocalEndpoint.IPv4 != nil {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetHostIP
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: zspan.LocalEndpoint.IPv4.String()}}
-			data = append(data, attr)
-		}
-		if zspan.LocalEndpoint.IPv6 != nil {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetHostIP
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: zspan.LocalEndpoint.IPv6.String()}}
-			data = append(data, attr)
-		}
+		data = appendEndpointIPs(data, &attrs, zspan.LocalEndpoint, AttributeNetHostIP, AttributeNetHostIPv6)
 		if zspan.LocalEndpoint.Port > 0 {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetHostPort
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: int64(zspan.LocalEndpoint.Port)}}
+			attr := attrs.next(AttributeNetHostPort)
+			attr.Value.Value = &v11.AnyValue_IntValue{IntValue: int64(zspan.LocalEndpoint.Port)}
 			data = append(data, attr)
 		}
 	}
 	if zspan.RemoteEndpoint != nil {
-		attr := &v11.KeyValue{}
-		attr.Key = AttributeNetHostIP
-
 		if zspan.RemoteEndpoint.ServiceName != "" {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributePeerService
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: zspan.RemoteEndpoint.ServiceName}}
-			data = append(data, attr)
-		}
-		if zspan.RemoteEndpoint.IPv4 != nil {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetPeerIP
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: zspan.RemoteEndpoint.IPv4.String()}}
-			data = append(data, attr)
-		}
-		if zspan.RemoteEndpoint.IPv6 != nil {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetPeerIP
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: zspan.RemoteEndpoint.IPv6.String()}}
+			attr := attrs.next(AttributePeerService)
+			attr.Value.Value = &v11.AnyValue_StringValue{StringValue: zspan.RemoteEndpoint.ServiceName}
 			data = append(data, attr)
 		}
+		data = appendEndpointIPs(data, &attrs, zspan.RemoteEndpoint, AttributeNetPeerIP, AttributeNetPeerIPv6)
 		if zspan.RemoteEndpoint.Port > 0 {
-			attr := &v11.KeyValue{}
-			attr.Key = AttributeNetPeerPort
-			attr.Value = &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: int64(zspan.RemoteEndpoint.Port)}}
+			attr := attrs.next(AttributeNetPeerPort)
+			attr.Value.Value = &v11.AnyValue_IntValue{IntValue: int64(zspan.RemoteEndpoint.Port)}
 			data = append(data, attr)
 		}
 	}
 	return data
 }
 
-func tagsToAttributeMap(tags map[string]string, parseStringTags bool) (data []*v11.KeyValue) {
+// appendEndpointIPs appends the attributes of endpointIPs without building its map.
+func appendEndpointIPs(data []*v11.KeyValue, attrs *keyValues, endpoint *zipkinmodel.Endpoint, ipKey, ipv6Key string) []*v11.KeyValue {
+	if endpoint.IPv4 != nil {
+		attr := attrs.next(ipKey)
+		attr.Value.Value = &v11.AnyValue_StringValue{StringValue: endpoint.IPv4.String()}
+		data = append(data, attr)
+		ipKey = ipv6Key
+	}
+	if endpoint.IPv6 != nil {
+		attr := attrs.next(ipKey)
+		attr.Value.Value = &v11.AnyValue_StringValue{StringValue: endpoint.IPv6.String()}
+		data = append(data, attr)
+	}
+	return data
+}
+
+func tagsToAttributeMap(tags map[string]string, parseStringTags bool, attrs *keyValues) (data []*v11.KeyValue) {
+	data = make([]*v11.KeyValue, 0, len(attrs.keyValues))
 	for key, val := range tags {
 		if _, ok := nonSpanAttributes[key]; ok {
 			continue
 		}
 
-		d := &v11.KeyValue{}
-		d.Key = key
+		d := attrs.next(key)
 		if parseStringTags {
 			switch DetermineValueType(val, false) {
 			case AttributeValueINT:
 				iValue, _ := strconv.ParseInt(val, 10, 64)
-				d.Value = &v11.AnyValue{Value: &v11.AnyValue_IntValue{IntValue: iValue}}
+				d.Value.Value = &v11.AnyValue_IntValue{IntValue: iValue}
 			case AttributeValueDOUBLE:
 				fValue, _ := strconv.ParseFloat(val, 64)
-				d.Value = &v11.AnyValue{Value: &v11.AnyValue_DoubleValue{DoubleValue: fValue}}
+				d.Value.Value = &v11.AnyValue_DoubleValue{DoubleValue: fValue}
 			case AttributeValueBOOL:
 				bValue, _ := strconv.ParseBool(val)
-				d.Value = &v11.AnyValue{Value: &v11.AnyValue_BoolValue{BoolValue: bValue}}
+				d.Value.Value = &v11.AnyValue_BoolValue{BoolValue: bValue}
 			default:
-				d.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: val}}
+				d.Value.Value = &v11.AnyValue_StringValue{StringValue: val}
 			}
 		} else {
-			d.Value = &v11.AnyValue{Value: &v11.AnyValue_StringValue{StringValue: val}}
+			d.Value.Value = &v11.AnyValue_StringValue{StringValue: val}
 		}
 
 		data = append(data, d)
@@ -366,6 +392,33 @@ func tagsToAttributeMap(tags map[string]string, parseStringTags bool) (data []*v
 	return data
 }
 
+// keyValues hands out the attributes of a span from a few allocations rather
+// than two per attribute.
+type keyValues struct {
+	keyValues []v11.KeyValue
+	values    []v11.AnyValue
+}
+
+func newKeyValues(n int) keyValues {
+	return keyValues{
+		keyValues: make([]v11.KeyValue, n),
+		values:    make([]v11.AnyValue, n),
+	}
+}
+
+// next returns an attribute with the key and an empty value.
+func (k *keyValues) next(key string) *v11.KeyValue {
+	if len(k.keyValues) == 0 {
+		*k = newKeyValues(8)
+	}
+	attr := &k.keyValues[0]
+	attr.Key = key
+	attr.Value = &k.values[0]
+	k.keyValues = k.keyValues[1:]
+	k.values = k.values[1:]
+	return attr
+}
+
 func populateResourceFromZipkinSpan(tags map[string]string, localServiceName string) (data *tracepb.ResourceSpans) {
 	if localServiceName == ResourceNoServiceName {
 		return nil
@@ -397,7 +450,7 @@ func populateResourceFromZipkinSpan(tags map[string]string, localServiceName str
 	}
 	delete(tags, TagServiceNameSource)
 
-	for key := range getNonSpanAttributes() {
+	for key := range nonSpanAttributes {
 		if key == TagInstrumentationName || key == TagInstrumentationVersion {
 			continue
 		}
@@ -429,14 +482,29 @@ func populateILFromZipkinSpan(tags map[string]string, instrLibName string) (data
 	return data
 }
 
+// copySpanTags copies the tags into a map of tagMapPool, the converters modify
+// the copy and hand it back with releaseSpanTags.
 func copySpanTags(tags map[string]string) map[string]string {
-	dest := make(map[string]string, len(tags))
+	dest := tagMapPool.Get().(map[string]string)
 	for key, val := range tags {
 		dest[key] = val
 	}
 	return dest
 }
 
+// releaseSpanTags empties the copy of the tags and puts it back in the pool, it
+// must not be used afterwards. copied is the number of tags copySpanTags copied,
+// the maps grown beyond maxPooledTags are left to the garbage collector.
+func releaseSpanTags(tags map[string]string, copied int) {
+	if copied > maxPooledTags {
+		return
+	}
+	for key := range tags {
+		delete(tags, key)
+	}
+	tagMapPool.Put(tags)
+}
+
 func extractLocalServiceName(zspan *zipkinmodel.SpanModel) string {
 	if zspan == nil || zspan.LocalEndpoint == nil || zspan.LocalEndpoint.ServiceName == "" {
 		return ResourceNoServiceName
@@ -2,7 +2,13 @@ package converter
 
 import (
 	"encoding/hex"
+	"net"
+	"reflect"
 	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	v11 "go.opentelemetry.io/proto/otlp/common/v1"
 )
 
 func TestConvert2OtelSpan(t *testing.T) {
@@ -18,3 +24,73 @@ func TestConvert2OtelSpan(t *testing.T) {
 		}
 	}
 }
+
+func attributeValues(attrs []*v11.KeyValue) map[string]interface{} {
+	values := make(map[string]interface{}, len(attrs))
+	for _, attr := range attrs {
+		switch value := attr.Value.Value.(type) {
+		case *v11.AnyValue_StringValue:
+			values[attr.Key] = value.StringValue
+		case *v11.AnyValue_IntValue:
+			values[attr.Key] = value.IntValue
+		case *v11.AnyValue_DoubleValue:
+			values[attr.Key] = value.DoubleValue
+		case *v11.AnyValue_BoolValue:
+			values[attr.Key] = value.BoolValue
+		}
+	}
+	return values
+}
+
+func TestConvert2OtelSpanAttributes(t *testing.T) {
+	parentID := zipkinmodel.ID(1)
+	first := &zipkinmodel.SpanModel{
+		SpanContext:    zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{High: 1, Low: 2}, ID: 3, ParentID: &parentID},
+		Name:           "get",
+		Kind:           zipkinmodel.Client,
+		Timestamp:      time.Unix(1659409534, 0),
+		LocalEndpoint:  &zipkinmodel.Endpoint{ServiceName: "frontend", IPv4: net.ParseIP("172.16.8.1").To4(), Port: 8080},
+		RemoteEndpoint: &zipkinmodel.Endpoint{ServiceName: "backend", IPv6: net.ParseIP("2001:db8::1"), Port: 9000},
+		Annotations:    []zipkinmodel.Annotation{{Value: "ws"}, {Value: "wr|x"}},
+		Tags:           map[string]string{"http.method": "GET", "count": "12", "ratio": "0.5", "cached": "true", "ids": "[1,2]"},
+	}
+	second := &zipkinmodel.SpanModel{
+		SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 4}, ID: 5},
+		Name:          "query",
+		Timestamp:     time.Unix(1659409534, 0),
+		LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "frontend"},
+		Tags:          map[string]string{"db.system": "mysql"},
+	}
+	tags := copySpanTags(first.Tags)
+
+	data, err := Convert2OtelSpan([]*zipkinmodel.SpanModel{first, second})
+	if err != nil || len(data) != 1 || len(data[0].InstrumentationLibrarySpans) != 1 {
+		t.Fatalf("Convert Failed. %v %v", data, err)
+	}
+	// the spans are sorted by service and library, not kept in order
+	spans := data[0].InstrumentationLibrarySpans[0].Spans
+	if len(spans) == 2 && spans[0].Name != "get" {
+		spans[0], spans[1] = spans[1], spans[0]
+	}
+	if !reflect.DeepEqual(first.Tags, tags) {
+		t.Errorf("Expected the tags of the span to be kept, Actual: %v", first.Tags)
+	}
+	if hex.EncodeToString(spans[0].TraceId) != "00000000000000010000000000000002" || hex.EncodeToString(spans[0].SpanId) != "0000000000000003" ||
+		hex.EncodeToString(spans[0].ParentSpanId) != "0000000000000001" || spans[1].ParentSpanId != nil {
+		t.Errorf("Unexpected ids %x %x %x %x", spans[0].TraceId, spans[0].SpanId, spans[0].ParentSpanId, spans[1].ParentSpanId)
+	}
+	expected := map[string]interface{}{
+		"http.request.method": "GET", "count": int64(12), "ratio": 0.5, "cached": true, "ids": "[1,2]",
+		AttributeNetHostIP: "172.16.8.1", AttributeNetHostPort: int64(8080),
+		AttributePeerService: "backend", AttributeNetPeerIP: "2001:db8::1", AttributeNetPeerPort: int64(9000),
+	}
+	if actual := attributeValues(spans[0].Attributes); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, actual)
+	}
+	if actual := attributeValues(spans[1].Attributes); !reflect.DeepEqual(actual, map[string]interface{}{"db.system": "mysql"}) {
+		t.Errorf("Expected only the tags of the second span, Actual: %v", actual)
+	}
+	if len(spans[0].Events) != 2 || spans[0].Events[0].Name != "ws" || spans[0].Events[1].Name != "wr" {
+		t.Errorf("Unexpected events %v", spans[0].Events)
+	}
+}
@@ -1,7 +1,12 @@
 package converter
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 )
 
@@ -18,21 +23,139 @@ type zipkinJsonModel struct {
 	ParentID  string
 }
 
+var errEmptyJSON = errors.New("expecting a JSON array, object or newline delimited objects")
+
+// ParseSpans accepts a JSON array of spans, a single span object, newline
+// delimited span objects or several concatenated arrays and objects. The arrays and the single spans are decoded in one
+// pass, see jsonDecoder.
 func (c *JsonConvertor) ParseSpans(protoBlob []byte, debugWasSet bool) (zss []*zipkinmodel.SpanModel, err error) {
-	var models []map[string]interface{}
-	if e := json.Unmarshal(protoBlob, &models); e != nil {
-		return nil, e
+	d := &jsonDecoder{data: protoBlob}
+	switch d.peek() {
+	case '[':
+		if zss, errs, ok := c.decodeArray(d); ok {
+			return partialResult(zss, errs)
+		}
+	case '{':
+		if span, ok := d.span(); ok && d.end() {
+			return []*zipkinmodel.SpanModel{span}, nil
+		}
+	}
+	return c.parseFramed(protoBlob, c.decodeItem)
+}
+
+// decodeArray decodes the spans of an array, ok is false if the array is not
+// valid and the reference path must report its error.
+func (c *JsonConvertor) decodeArray(d *jsonDecoder) (zss []*zipkinmodel.SpanModel, errs []SpanError, ok bool) {
+	d.consume('[')
+	if d.consume(']') {
+		return nil, nil, d.end()
+	}
+	for index := 0; ; index++ {
+		d.skipSpace()
+		start := d.pos
+		if span, ok := d.span(); ok {
+			zss = append(zss, span)
+		} else {
+			d.pos = start
+			if !d.skip(1) {
+				return nil, nil, false
+			}
+			if span, e := c.parseItem(d.data[start:d.pos]); e != nil {
+				errs = append(errs, SpanError{Index: index, Err: e})
+			} else {
+				zss = append(zss, span)
+			}
+		}
+		if d.consume(',') {
+			continue
+		}
+		return zss, errs, d.consume(']') && d.end()
 	}
+}
 
-	for _, model := range models {
-		if zms, e := c.parseSpan(model); e != nil {
+// parseFramed is the reference path of ParseSpans, parse is applied to every span.
+func (c *JsonConvertor) parseFramed(protoBlob []byte, parse func(item []byte) (*zipkinmodel.SpanModel, error)) (zss []*zipkinmodel.SpanModel, err error) {
+	blob := bytes.TrimSpace(protoBlob)
+	if len(blob) == 0 {
+		return nil, errEmptyJSON
+	}
+
+	items, e := splitValues(blob)
+	if e != nil {
+		if blob[0] == '[' {
 			return nil, e
+		}
+		// only the invalid lines of newline delimited spans fail
+		items = splitLines(blob)
+	}
+
+	var errs []SpanError
+	for index, item := range items {
+		if zms, e := parse(item); e != nil {
+			errs = append(errs, SpanError{Index: index, Err: e})
 		} else {
 			zss = append(zss, zms)
 		}
 	}
+	return partialResult(zss, errs)
+}
 
-	return zss, nil
+// splitValues splits the successive top level values of the blob, such as
+// concatenated arrays or objects, and flattens the arrays into their items.
+func splitValues(blob []byte) (items []json.RawMessage, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(blob))
+	for {
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err == io.EOF {
+			return items, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if value[0] != '[' {
+			items = append(items, value)
+			continue
+		}
+		var array []json.RawMessage
+		if err = json.Unmarshal(value, &array); err != nil {
+			return nil, err
+		}
+		items = append(items, array...)
+	}
+}
+
+// splitLines splits newline delimited JSON, the blank lines are skipped.
+func splitLines(blob []byte) (items []json.RawMessage) {
+	for _, line := range bytes.Split(blob, []byte{'\n'}) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+// decodeItem decodes a span in one pass, or with the reference path if the decoder cannot.
+func (c *JsonConvertor) decodeItem(item []byte) (*zipkinmodel.SpanModel, error) {
+	d := &jsonDecoder{data: item}
+	if span, ok := d.span(); ok && d.end() {
+		return span, nil
+	}
+	return c.parseItem(item)
+}
+
+// parseItem is the reference path decoding a span, it unmarshals the span into
+// a map before unmarshalling it into SpanModel.
+func (c *JsonConvertor) parseItem(item []byte) (span *zipkinmodel.SpanModel, err error) {
+	// zipkin-go panics on some invalid spans, such as a null annotation
+	defer func() {
+		if r := recover(); r != nil {
+			span, err = nil, fmt.Errorf("invalid span: %v", r)
+		}
+	}()
+	var model map[string]interface{}
+	if e := json.Unmarshal(item, &model); e != nil {
+		return nil, e
+	}
+	return c.parseSpan(model)
 }
 
 func (c *JsonConvertor) parseSpan(model map[string]interface{}) (spanModel *zipkinmodel.SpanModel, e error) {
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	jsonSpan1 = `{"traceId":"0000000000000001","id":"0000000000000001","name":"get","timestamp":1659409534000000,"duration":1000,"localEndpoint":{"serviceName":"frontend"}}`
+	jsonSpan2 = `{"traceId":"0000000000000001","id":"0000000000000002","parentId":"0000000000000001","name":"query","timestamp":1659409534000100,"duration":500}`
+)
+
+func TestJsonConvertorFraming(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		spans   []string
+	}{
+		{"array", "[" + jsonSpan1 + "," + jsonSpan2 + "]", []string{"get", "query"}},
+		{"object", jsonSpan1, []string{"get"}},
+		{"indented object", "{\n  \"traceId\": \"0000000000000001\",\n  \"id\": \"0000000000000003\",\n  \"name\": \"indented\"\n}\n", []string{"indented"}},
+		{"ndjson", jsonSpan1 + "\n\n" + jsonSpan2 + "\n", []string{"get", "query"}},
+		{"concatenated arrays", "[" + jsonSpan1 + "][" + jsonSpan2 + "]", []string{"get", "query"}},
+		{"newline delimited arrays", "[" + jsonSpan1 + "]\n[]\n[" + jsonSpan2 + "]\n", []string{"get", "query"}},
+		{"concatenated objects", jsonSpan1 + jsonSpan2, []string{"get", "query"}},
+		{"array then object", "[" + jsonSpan1 + "] " + jsonSpan2, []string{"get", "query"}},
+	}
+	for _, c := range cases {
+		spans, err := (&JsonConvertor{}).ParseSpans([]byte(c.payload), false)
+		if err != nil {
+			t.Errorf("%s: Failed to parse. %v", c.name, err)
+			continue
+		}
+		if len(spans) != len(c.spans) {
+			t.Errorf("%s: Expected %d spans, Actual: %d", c.name, len(c.spans), len(spans))
+			continue
+		}
+		for i, name := range c.spans {
+			if spans[i].Name != name {
+				t.Errorf("%s: Expected %s, Actual: %s", c.name, name, spans[i].Name)
+			}
+		}
+	}
+}
+
+func TestJsonConvertorPartialFailure(t *testing.T) {
+	for name, payload := range map[string]string{
+		"array":  "[" + jsonSpan1 + `,{"traceId":"xyz","id":"1"},` + jsonSpan2 + "]",
+		"ndjson": jsonSpan1 + "\n{\"traceId\": broken\n" + jsonSpan2,
+	} {
+		spans, err := (&JsonConvertor{}).ParseSpans([]byte(payload), false)
+		var partial *PartialError
+		if !errors.As(err, &partial) {
+			t.Fatalf("%s: Expected a partial error, Actual: %v", name, err)
+		}
+		if len(spans) != 2 || len(partial.Spans) != 1 || partial.Spans[0].Index != 1 {
+			t.Errorf("%s: Expected 2 spans and the span 1 invalid, Actual: %d spans, %v", name, len(spans), err)
+		}
+	}
+
+	if spans, err := (&JsonConvertor{}).ParseSpans([]byte(`{"traceId":"xyz"}`), false); err == nil || spans != nil {
+		t.Errorf("Expected an invalid span to fail the message, Actual: %v", spans)
+	}
+	if _, err := (&JsonConvertor{}).ParseSpans([]byte("  "), false); err == nil {
+		t.Error("Expected an empty message to fail")
+	}
+}
@@ -0,0 +1,557 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+// jsonDecoder decodes Zipkin v2 JSON spans into SpanModel in a single pass. It
+// only decodes the spans whose result is certain to be the one of the reference
+// path, unmarshalling into a map and then into SpanModel. It reports the other
+// spans, such as the invalid ones or those with unusual key casing, duplicate
+// keys or non string ids, as not decoded and the caller falls back to the
+// reference path for them, so both paths always agree.
+type jsonDecoder struct {
+	data []byte
+	pos  int
+}
+
+// maxSkipDepth bounds the nesting of the skipped values, deeper values are
+// left to the reference path which has its own limit.
+const maxSkipDepth = 1000
+
+// The fields of a span, an endpoint and an annotation, used to detect duplicate keys.
+const (
+	fieldTraceID = iota
+	fieldID
+	fieldParentID
+	fieldDebug
+	fieldName
+	fieldKind
+	fieldTimestamp
+	fieldDuration
+	fieldShared
+	fieldLocalEndpoint
+	fieldRemoteEndpoint
+	fieldAnnotations
+	fieldTags
+)
+
+var spanKeys = []string{"traceId", "id", "parentId", "debug", "name", "kind", "timestamp", "duration", "shared", "localEndpoint", "remoteEndpoint", "annotations", "tags"}
+
+const (
+	fieldServiceName = iota
+	fieldIPv4
+	fieldIPv6
+	fieldPort
+)
+
+var endpointKeys = []string{"serviceName", "ipv4", "ipv6", "port"}
+
+const (
+	fieldAnnotationTimestamp = iota
+	fieldAnnotationValue
+)
+
+var annotationKeys = []string{"timestamp", "value"}
+
+// field returns the index of the key in keys, -1 if it is unknown. ok is false
+// if the key only matches case insensitively, which encoding/json resolves in
+// ways not worth replicating.
+func field(key []byte, keys []string) (index int, ok bool) {
+	for i, name := range keys {
+		if string(key) == name {
+			return i, true
+		}
+	}
+	for _, name := range keys {
+		if bytes.EqualFold(key, []byte(name)) {
+			return -1, false
+		}
+	}
+	return -1, true
+}
+
+func (d *jsonDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non space byte, or 0 at the end of the data.
+func (d *jsonDecoder) peek() byte {
+	d.skipSpace()
+	if d.pos < len(d.data) {
+		return d.data[d.pos]
+	}
+	return 0
+}
+
+func (d *jsonDecoder) consume(c byte) bool {
+	if d.peek() == c {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// end tells whether only spaces are left.
+func (d *jsonDecoder) end() bool {
+	d.skipSpace()
+	return d.pos == len(d.data)
+}
+
+func (d *jsonDecoder) literal(value string) bool {
+	d.skipSpace()
+	if bytes.HasPrefix(d.data[d.pos:], []byte(value)) {
+		d.pos += len(value)
+		return true
+	}
+	return false
+}
+
+// null consumes a null, which has no effect on the decoded fields.
+func (d *jsonDecoder) null() bool {
+	return d.peek() == 'n' && d.literal("null")
+}
+
+func (d *jsonDecoder) bool() (value bool, ok bool) {
+	switch d.peek() {
+	case 't':
+		return true, d.literal("true")
+	case 'f':
+		return false, d.literal("false")
+	}
+	return false, false
+}
+
+// rawString consumes a string and returns its content between the quotes.
+func (d *jsonDecoder) rawString() (raw []byte, escaped bool, ok bool) {
+	if d.peek() != '"' {
+		return nil, false, false
+	}
+	start := d.pos + 1
+	for i := start; i < len(d.data); i++ {
+		switch c := d.data[i]; {
+		case c == '"':
+			d.pos = i + 1
+			return d.data[start:i], escaped, true
+		case c == '\\':
+			escaped = true
+			i++
+			if i >= len(d.data) {
+				return nil, false, false
+			}
+			switch d.data[i] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if i+4 >= len(d.data) {
+					return nil, false, false
+				}
+				for _, h := range d.data[i+1 : i+5] {
+					if _, ok := hexDigit(h); !ok {
+						return nil, false, false
+					}
+				}
+				i += 4
+			default:
+				return nil, false, false
+			}
+		case c < 0x20:
+			return nil, false, false
+		}
+	}
+	return nil, false, false
+}
+
+// string consumes a string and unescapes it like encoding/json.
+func (d *jsonDecoder) string() (string, bool) {
+	start := d.pos
+	raw, escaped, ok := d.rawString()
+	if !ok {
+		return "", false
+	}
+	if !escaped && utf8.Valid(raw) {
+		return string(raw), true
+	}
+	var value string
+	if err := json.Unmarshal(d.data[start:d.pos], &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// number consumes a number literal following the JSON grammar.
+func (d *jsonDecoder) number() ([]byte, bool) {
+	d.skipSpace()
+	start, i := d.pos, d.pos
+	digits := func() bool {
+		begin := i
+		for i < len(d.data) && d.data[i] >= '0' && d.data[i] <= '9' {
+			i++
+		}
+		return i > begin
+	}
+	if i < len(d.data) && d.data[i] == '-' {
+		i++
+	}
+	if i < len(d.data) && d.data[i] == '0' {
+		i++
+	} else if !digits() {
+		return nil, false
+	}
+	if i < len(d.data) && d.data[i] == '.' {
+		i++
+		if !digits() {
+			return nil, false
+		}
+	}
+	if i < len(d.data) && (d.data[i] == 'e' || d.data[i] == 'E') {
+		i++
+		if i < len(d.data) && (d.data[i] == '+' || d.data[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return nil, false
+		}
+	}
+	d.pos = i
+	return d.data[start:i], true
+}
+
+// float consumes a number and converts it like encoding/json into an interface{}.
+func (d *jsonDecoder) float() (float64, bool) {
+	literal, ok := d.number()
+	if !ok {
+		return 0, false
+	}
+	// the short integers are exact in a float64
+	if len(literal) <= 15 && literal[0] != '-' && bytes.IndexAny(literal, ".eE") < 0 {
+		var n uint64
+		for _, c := range literal {
+			n = n*10 + uint64(c-'0')
+		}
+		return float64(n), true
+	}
+	value, err := strconv.ParseFloat(string(literal), 64)
+	return value, err == nil
+}
+
+// uint consumes a number of the reference path, which converts it into a float64,
+// marshals it and unmarshals it into an unsigned integer lower than limit.
+func (d *jsonDecoder) uint(limit float64) (uint64, bool) {
+	value, ok := d.float()
+	if !ok || value < 0 || math.Signbit(value) || value != math.Trunc(value) || value >= limit {
+		return 0, false
+	}
+	if value < 1<<53 {
+		return uint64(value), true
+	}
+	// marshalled with the shortest representation, which is not the exact value
+	n, err := strconv.ParseUint(strconv.FormatFloat(value, 'f', -1, 64), 10, 64)
+	return n, err == nil
+}
+
+// skip consumes a value, it fails on the values the reference path fails to unmarshal.
+func (d *jsonDecoder) skip(depth int) bool {
+	if depth > maxSkipDepth {
+		return false
+	}
+	switch d.peek() {
+	case '{':
+		d.pos++
+		if d.consume('}') {
+			return true
+		}
+		for {
+			if _, _, ok := d.rawString(); !ok || !d.consume(':') || !d.skip(depth+1) {
+				return false
+			}
+			if d.consume(',') {
+				continue
+			}
+			return d.consume('}')
+		}
+	case '[':
+		d.pos++
+		if d.consume(']') {
+			return true
+		}
+		for {
+			if !d.skip(depth + 1) {
+				return false
+			}
+			if d.consume(',') {
+				continue
+			}
+			return d.consume(']')
+		}
+	case '"':
+		_, _, ok := d.rawString()
+		return ok
+	case 't':
+		return d.literal("true")
+	case 'f':
+		return d.literal("false")
+	case 'n':
+		return d.literal("null")
+	}
+	_, ok := d.float()
+	return ok
+}
+
+// object consumes an object, calling member for every key not seen before.
+func (d *jsonDecoder) object(keys []string, member func(field int) bool) bool {
+	if !d.consume('{') {
+		return false
+	}
+	if d.consume('}') {
+		return true
+	}
+	var seen uint32
+	for {
+		key, escaped, ok := d.rawString()
+		if !ok || escaped || !d.consume(':') {
+			return false
+		}
+		index, ok := field(key, keys)
+		switch {
+		case !ok:
+			return false
+		case index < 0:
+			if !d.skip(1) {
+				return false
+			}
+		case seen&(1<<uint(index)) != 0:
+			return false
+		default:
+			seen |= 1 << uint(index)
+			if !d.null() && !member(index) {
+				return false
+			}
+		}
+		if d.consume(',') {
+			continue
+		}
+		return d.consume('}')
+	}
+}
+
+// span consumes a span object, ok is false if the reference path must decode it.
+func (d *jsonDecoder) span() (span *zipkinmodel.SpanModel, ok bool) {
+	span = &zipkinmodel.SpanModel{}
+	var timestamp, duration uint64
+	ok = d.object(spanKeys, func(index int) bool {
+		var ok bool
+		switch index {
+		case fieldTraceID:
+			span.TraceID, ok = d.traceID()
+		case fieldID:
+			var id *zipkinmodel.ID
+			if id, ok = d.id(); ok {
+				span.ID = *id
+			}
+		case fieldParentID:
+			span.ParentID, ok = d.id()
+		case fieldDebug:
+			span.Debug, ok = d.bool()
+		case fieldName:
+			span.Name, ok = d.string()
+		case fieldKind:
+			var kind string
+			kind, ok = d.string()
+			span.Kind = zipkinmodel.Kind(kind)
+		case fieldTimestamp:
+			timestamp, ok = d.uint(1 << 64)
+		case fieldDuration:
+			duration, ok = d.uint(1 << 64)
+		case fieldShared:
+			span.Shared, ok = d.bool()
+		case fieldLocalEndpoint:
+			span.LocalEndpoint, ok = d.endpoint()
+		case fieldRemoteEndpoint:
+			span.RemoteEndpoint, ok = d.endpoint()
+		case fieldAnnotations:
+			span.Annotations, ok = d.annotations()
+		case fieldTags:
+			span.Tags, ok = d.tags()
+		}
+		return ok
+	})
+	if !ok || span.ID < 1 {
+		return nil, false
+	}
+
+	// the same conversions as SpanModel.UnmarshalJSON
+	if timestamp > 0 {
+		span.Timestamp = time.Unix(0, int64(timestamp)*1e3)
+	}
+	span.Duration = time.Duration(duration*1e3) * time.Nanosecond
+	if span.LocalEndpoint.Empty() {
+		span.LocalEndpoint = nil
+	}
+	if span.RemoteEndpoint.Empty() {
+		span.RemoteEndpoint = nil
+	}
+	return span, true
+}
+
+// traceID decodes the ids TraceID.UnmarshalJSON accepts without an error.
+func (d *jsonDecoder) traceID() (traceID zipkinmodel.TraceID, ok bool) {
+	raw, escaped, ok := d.rawString()
+	if !ok || escaped || len(raw) == 0 {
+		return traceID, false
+	}
+	if len(raw) > 16 {
+		if traceID.High, ok = parseHex(raw[:len(raw)-16]); !ok {
+			return traceID, false
+		}
+		raw = raw[len(raw)-16:]
+	}
+	traceID.Low, ok = parseHex(raw)
+	return traceID, ok
+}
+
+// id decodes an ID like ID.UnmarshalJSON, an empty string is the zero id.
+func (d *jsonDecoder) id() (*zipkinmodel.ID, bool) {
+	raw, escaped, ok := d.rawString()
+	if !ok || escaped {
+		return nil, false
+	}
+	id := new(zipkinmodel.ID)
+	if len(raw) == 0 {
+		return id, true
+	}
+	value, ok := parseHex(raw)
+	*id = zipkinmodel.ID(value)
+	return id, ok
+}
+
+func (d *jsonDecoder) endpoint() (*zipkinmodel.Endpoint, bool) {
+	endpoint := &zipkinmodel.Endpoint{}
+	ok := d.object(endpointKeys, func(index int) bool {
+		var ok bool
+		switch index {
+		case fieldServiceName:
+			endpoint.ServiceName, ok = d.string()
+		case fieldIPv4:
+			endpoint.IPv4, ok = d.ip()
+		case fieldIPv6:
+			endpoint.IPv6, ok = d.ip()
+		case fieldPort:
+			var port uint64
+			port, ok = d.uint(1 << 16)
+			endpoint.Port = uint16(port)
+		}
+		return ok
+	})
+	return endpoint, ok
+}
+
+// ip decodes an address like net.IP.UnmarshalText.
+func (d *jsonDecoder) ip() (net.IP, bool) {
+	text, ok := d.string()
+	if !ok || text == "" {
+		return nil, ok
+	}
+	ip := net.ParseIP(text)
+	return ip, ip != nil
+}
+
+func (d *jsonDecoder) annotations() ([]zipkinmodel.Annotation, bool) {
+	if !d.consume('[') {
+		return nil, false
+	}
+	annotations := []zipkinmodel.Annotation{}
+	if d.consume(']') {
+		return annotations, true
+	}
+	for {
+		var timestamp uint64
+		var value string
+		ok := d.object(annotationKeys, func(index int) bool {
+			var ok bool
+			switch index {
+			case fieldAnnotationTimestamp:
+				timestamp, ok = d.uint(1 << 64)
+			case fieldAnnotationValue:
+				value, ok = d.string()
+			}
+			return ok
+		})
+		if !ok || timestamp < 1 {
+			return nil, false
+		}
+		annotations = append(annotations, zipkinmodel.Annotation{Timestamp: time.Unix(0, int64(timestamp)*1e3), Value: value})
+		if d.consume(',') {
+			continue
+		}
+		return annotations, d.consume(']')
+	}
+}
+
+func (d *jsonDecoder) tags() (map[string]string, bool) {
+	if !d.consume('{') {
+		return nil, false
+	}
+	tags := make(map[string]string)
+	if d.consume('}') {
+		return tags, true
+	}
+	for {
+		key, ok := d.string()
+		if !ok || !d.consume(':') {
+			return nil, false
+		}
+		// a null tag is stored as an empty string by the reference path, left to it
+		value, ok := d.string()
+		if !ok {
+			return nil, false
+		}
+		tags[key] = value
+		if d.consume(',') {
+			continue
+		}
+		return tags, d.consume('}')
+	}
+}
+
+// parseHex parses what strconv.ParseUint(s, 16, 64) accepts.
+func parseHex(raw []byte) (uint64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range raw {
+		digit, ok := hexDigit(c)
+		if !ok || n >= 1<<60 {
+			return 0, false
+		}
+		n = n<<4 | uint64(digit)
+	}
+	return n, true
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+const decoderSpan = `{"traceId":"5af7183fb1d4cf5f0000000000000001","parentId":"6b221d5bc9e6496c","id":"352bff9a74ca9ad2","kind":"CLIENT",` +
+	`"name":"get /api","timestamp":1659409534123456,"duration":207000,"debug":true,"shared":false,` +
+	`"localEndpoint":{"serviceName":"frontend","ipv4":"172.16.8.1","port":8080},` +
+	`"remoteEndpoint":{"serviceName":"backend","ipv6":"2001:db8::c001","port":9000},` +
+	`"annotations":[{"timestamp":1659409534123500,"value":"ws"},{"timestamp":1659409534330000,"value":"wr"}],` +
+	`"tags":{"http.method":"GET","http.path":"/api","error":"","clnt/finagle.version":"6.45.0"}}`
+
+// assertSameResult checks that the decoder and the reference path agree on the payload.
+func assertSameResult(t *testing.T, name string, payload string) {
+	t.Helper()
+	c := &JsonConvertor{}
+	spans, err := c.ParseSpans([]byte(payload), false)
+	expectedSpans, expectedErr := c.parseFramed([]byte(payload), c.parseItem)
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Errorf("%s: Expected error %v, Actual: %v, payload %q", name, expectedErr, err, payload)
+	}
+	if !reflect.DeepEqual(spans, expectedSpans) {
+		t.Errorf("%s: Expected %+v, Actual: %+v, payload %q", name, expectedSpans, spans, payload)
+	}
+}
+
+func TestJsonDecoderMatchesTheReference(t *testing.T) {
+	replace := func(old, new string) string {
+		return strings.Replace(decoderSpan, old, new, 1)
+	}
+	cases := map[string]string{
+		"span":                  decoderSpan,
+		"array":                 "[" + decoderSpan + "," + jsonSpan1 + "," + jsonSpan2 + "]",
+		"empty array":           " [ ] ",
+		"spaces":                " \r\n\t[ \n" + jsonSpan1 + " , " + jsonSpan2 + " ]\n",
+		"ndjson":                jsonSpan1 + "\n" + jsonSpan2,
+		"64 bit trace id":       replace("5af7183fb1d4cf5f0000000000000001", "0000000000000001"),
+		"short ids":             replace(`"352bff9a74ca9ad2"`, `"a"`),
+		"upper case hex":        replace("352bff9a74ca9ad2", "352BFF9A74CA9AD2"),
+		"long trace id":         replace("5af7183fb1d4cf5f0000000000000001", "00005af7183fb1d4cf5f0000000000000001"),
+		"overflowing trace id":  replace("5af7183fb1d4cf5f0000000000000001", "15af7183fb1d4cf5f0000000000000001"),
+		"invalid trace id":      replace("5af7183fb1d4cf5f", "5af7183fb1d4cfzz"),
+		"empty trace id":        replace("5af7183fb1d4cf5f0000000000000001", ""),
+		"numeric trace id":      replace(`"5af7183fb1d4cf5f0000000000000001"`, "123"),
+		"escaped trace id":      replace("5af7183f", `5af7183f`),
+		"prefixed trace id":     replace("5af7183fb1d4cf5f0000000000000001", "0x5af7183fb1d4cf5f"),
+		"empty id":              replace(`"352bff9a74ca9ad2"`, `""`),
+		"zero id":               replace(`"352bff9a74ca9ad2"`, `"0"`),
+		"missing id":            replace(`"id":"352bff9a74ca9ad2",`, ""),
+		"empty parent id":       replace("6b221d5bc9e6496c", ""),
+		"null parent id":        replace(`"6b221d5bc9e6496c"`, "null"),
+		"numeric id":            replace(`"352bff9a74ca9ad2"`, "[1]"),
+		"null span":             "[" + jsonSpan1 + ",null]",
+		"string span":           `["span"]`,
+		"escaped name":          replace("get /api", `get \"\/api\" é😀\n\t`),
+		"lone surrogate":        replace("get /api", `\ud83d`),
+		"invalid utf8":          replace("get /api", "get \xff\xfe"),
+		"html name":             replace("get /api", "<a&b> "),
+		"control character":     replace("get /api", "get\x01"),
+		"invalid escape":        replace("get /api", `get \x`),
+		"short unicode escape":  replace("get /api", `\u00e`),
+		"numeric name":          replace(`"get /api"`, "42"),
+		"null name":             replace(`"get /api"`, "null"),
+		"unknown kind":          replace("CLIENT", "whatever"),
+		"float timestamp":       replace("1659409534123456", "1659409534123456.0"),
+		"exponent timestamp":    replace("1659409534123456", "1.659409534123456e15"),
+		"large timestamp":       replace("1659409534123456", "9007199254740993"),
+		"huge timestamp":        replace("1659409534123456", "18446744073709551616"),
+		"overflow timestamp":    replace("1659409534123456", "1e400"),
+		"fraction timestamp":    replace("1659409534123456", "1659409534123456.5"),
+		"negative timestamp":    replace("1659409534123456", "-1"),
+		"negative zero":         replace("1659409534123456", "-0"),
+		"zero timestamp":        replace("1659409534123456", "0"),
+		"string timestamp":      replace("1659409534123456", `"1659409534123456"`),
+		"leading zero":          replace("1659409534123456", "01659409534123456"),
+		"bare fraction":         replace("207000", ".5"),
+		"sub microsecond":       replace("207000", "1e-7"),
+		"string bool":           replace(`"debug":true`, `"debug":"true"`),
+		"null bool":             replace(`"shared":false`, `"shared":null`),
+		"truncated literal":     replace(`"debug":true`, `"debug":tru`),
+		"empty endpoint":        replace(`{"serviceName":"frontend","ipv4":"172.16.8.1","port":8080}`, "{}"),
+		"null endpoint":         replace(`{"serviceName":"frontend","ipv4":"172.16.8.1","port":8080}`, "null"),
+		"string endpoint":       replace(`{"serviceName":"frontend","ipv4":"172.16.8.1","port":8080}`, `"frontend"`),
+		"empty ip":              replace("172.16.8.1", ""),
+		"invalid ip":            replace("172.16.8.1", "172.16.8"),
+		"ipv6 in ipv4":          replace("172.16.8.1", "::1"),
+		"float port":            replace("8080", "8080.0"),
+		"overflowing port":      replace("8080", "65536"),
+		"negative port":         replace("8080", "-1"),
+		"endpoint key case":     replace("serviceName", "ServiceName"),
+		"endpoint unknown key":  replace(`"port":8080`, `"port":8080,"zone":{"a":[1,2,{"b":null}]}`),
+		"empty annotations":     replace(`[{"timestamp":1659409534123500,"value":"ws"},{"timestamp":1659409534330000,"value":"wr"}]`, "[]"),
+		"null annotation":       replace(`{"timestamp":1659409534123500,"value":"ws"}`, "null"),
+		"zero annotation":       replace("1659409534123500", "0"),
+		"annotation no value":   replace(`,"value":"ws"`, ""),
+		"annotation key case":   replace(`"value":"ws"`, `"Value":"ws"`),
+		"annotation extra key":  replace(`"value":"ws"`, `"value":"ws","endpoint":{}`),
+		"annotations object":    replace(`[{"timestamp":1659409534123500,"value":"ws"},{"timestamp":1659409534330000,"value":"wr"}]`, "{}"),
+		"empty tags":            replace(`{"http.method":"GET","http.path":"/api","error":"","clnt/finagle.version":"6.45.0"}`, "{}"),
+		"null tag":              replace(`"error":""`, `"error":null`),
+		"numeric tag":           replace(`"error":""`, `"error":500`),
+		"duplicate tag":         replace(`"error":""`, `"error":"","error":"true"`),
+		"escaped tag key":       replace(`"error":""`, `"error":"x"`),
+		"duplicate key":         replace(`"kind":"CLIENT"`, `"kind":"CLIENT","kind":"SERVER"`),
+		"key case":              replace(`"traceId"`, `"TraceId"`),
+		"both key cases":        replace(`"kind":"CLIENT"`, `"kind":"CLIENT","KIND":"SERVER"`),
+		"escaped key":           replace(`"kind"`, `"kind"`),
+		"unknown fields":        replace(`"kind"`, `"x":[1,"a",true,null,{"y":-1.5e3}],"kind"`),
+		"overflow unknown":      replace(`"kind"`, `"x":1e400,"kind"`),
+		"sampled":               replace(`"kind"`, `"sampled":true,"err":"x","kind"`),
+		"trailing comma":        replace(`"value":"wr"}]`, `"value":"wr"},]`),
+		"missing colon":         replace(`"kind":`, `"kind" `),
+		"unterminated":          decoderSpan[:len(decoderSpan)-1],
+		"trailing data":         decoderSpan + "x",
+		"two objects":           decoderSpan + decoderSpan,
+		"invalid array element": "[" + jsonSpan1 + ",{\"traceId\":}]",
+		"array trailing data":   "[" + jsonSpan1 + "] x",
+		"vertical tab":          "[" + jsonSpan1 + "]\v",
+		"empty":                 "",
+		"blank":                 " \n ",
+		"deep unknown":          replace(`"kind"`, `"x":`+strings.Repeat("[", 2000)+strings.Repeat("]", 2000)+`,"kind"`),
+	}
+	for name, payload := range cases {
+		assertSameResult(t, name, payload)
+	}
+}
+
+func TestJsonDecoderMatchesTheReferenceOnMutations(t *testing.T) {
+	payload := "[" + decoderSpan + "," + jsonSpan1 + "]"
+	alphabet := []byte(`{}[]:,"\ 0123456789abcdefxyzE.-+nulltrue` + "\x00\xff\n")
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		mutated := []byte(payload)
+		for n := random.Intn(3) + 1; n > 0; n-- {
+			at := random.Intn(len(mutated))
+			switch random.Intn(3) {
+			case 0:
+				mutated[at] = alphabet[random.Intn(len(alphabet))]
+			case 1:
+				mutated = append(mutated[:at], mutated[at+1:]...)
+			default:
+				mutated = append(mutated[:at], append([]byte{alphabet[random.Intn(len(alphabet))]}, mutated[at:]...)...)
+			}
+		}
+		assertSameResult(t, fmt.Sprintf("mutation %d", i), string(mutated))
+	}
+}
+
+func TestJsonDecoderDecodesInOnePass(t *testing.T) {
+	d := &jsonDecoder{data: []byte(decoderSpan)}
+	span, ok := d.span()
+	if !ok || !d.end() {
+		t.Fatal("Expected the span to be decoded without the reference path")
+	}
+	if span.TraceID != (zipkinmodel.TraceID{High: 0x5af7183fb1d4cf5f, Low: 1}) || span.ID != 0x352bff9a74ca9ad2 || *span.ParentID != 0x6b221d5bc9e6496c {
+		t.Errorf("Unexpected ids %v %v %v", span.TraceID, span.ID, span.ParentID)
+	}
+	if span.LocalEndpoint.IPv4.String() != "172.16.8.1" || span.RemoteEndpoint.Port != 9000 || len(span.Annotations) != 2 || span.Tags["http.method"] != "GET" {
+		t.Errorf("Unexpected span %+v", span)
+	}
+}
+
+func benchmarkPayload(spans int) []byte {
+	items := make([]string, spans)
+	for i := range items {
+		items[i] = strings.Replace(decoderSpan, "352bff9a74ca9ad2", fmt.Sprintf("%016x", i+1), 1)
+	}
+	return []byte("[" + strings.Join(items, ",") + "]")
+}
+
+func BenchmarkJsonParseSpans(b *testing.B) {
+	payload := benchmarkPayload(100)
+	c := &JsonConvertor{}
+	b.Run("decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			if _, err := c.ParseSpans(payload, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reference", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			if _, err := c.parseFramed(payload, c.parseItem); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
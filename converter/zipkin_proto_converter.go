@@ -9,114 +9,192 @@ import (
 
 	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
 
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 )
 
-var ZERO_TIME = errors.New("zero_time")
-
 type ProtobufConvertor struct {
 }
 
-// Copy from zipking-go
+// ParseSpans accepts a ListOfSpans or a single Span. The spans of a list are
+// parsed one by one so that an invalid span does not fail the others.
 func (c *ProtobufConvertor) ParseSpans(protoBlob []byte, debugWasSet bool) (zss []*zipkinmodel.SpanModel, err error) {
-	var listOfSpans zipkin_proto3.ListOfSpans
-	if err := proto.Unmarshal(protoBlob, &listOfSpans); err != nil {
+	return parseProtoSpans(protoBlob, debugWasSet, decodeSpan)
+}
+
+// parseProtoSpans parses the spans of protoBlob with parse.
+func parseProtoSpans(protoBlob []byte, debugWasSet bool, parse func([]byte, bool) (*zipkinmodel.SpanModel, error)) ([]*zipkinmodel.SpanModel, error) {
+	zss, errs, isList := parseListOfSpans(protoBlob, debugWasSet, parse)
+	if !isList {
+		// a single Span has fields unknown to ListOfSpans, at least its id
+		zms, err := parse(protoBlob, debugWasSet)
+		if err != nil {
+			return nil, err
+		}
+		return []*zipkinmodel.SpanModel{zms}, nil
+	}
+	return partialResult(zss, errs)
+}
+
+// decodeSpan decodes the span straight from the wire format, or with the
+// reference path when the decoder gives up on it.
+func decodeSpan(protoBlob []byte, debugWasSet bool) (*zipkinmodel.SpanModel, error) {
+	if zms, ok := decodeProtoSpan(protoBlob, debugWasSet); ok {
+		return zms, nil
+	}
+	return parseSpan(protoBlob, debugWasSet)
+}
+
+// parseSpan is the reference path: proto.Unmarshal then protoSpanToModelSpan.
+func parseSpan(protoBlob []byte, debugWasSet bool) (*zipkinmodel.SpanModel, error) {
+	var span zipkin_proto3.Span
+	if err := proto.Unmarshal(protoBlob, &span); err != nil {
 		return nil, err
 	}
-	for _, zps := range listOfSpans.Spans {
-		if zms, err := protoSpanToModelSpan(zps, debugWasSet); err == nil {
-			zss = append(zss, zms)
+	return protoSpanToModelSpan(&span, debugWasSet)
+}
+
+// parseListOfSpans walks the wire format of a ListOfSpans and parses each span
+// with parse, isList is false if the message has other fields than the spans.
+func parseListOfSpans(protoBlob []byte, debugWasSet bool, parse func([]byte, bool) (*zipkinmodel.SpanModel, error)) (zss []*zipkinmodel.SpanModel, errs []SpanError, isList bool) {
+	for index := 0; len(protoBlob) > 0; index++ {
+		num, typ, n := protowire.ConsumeTag(protoBlob)
+		if n < 0 {
+			return zss, append(errs, SpanError{Index: index, Err: protowire.ParseError(n)}), true
+		}
+		if num != 1 || typ != protowire.BytesType {
+			return nil, nil, false
+		}
+		value, m := protowire.ConsumeBytes(protoBlob[n:])
+		if m < 0 {
+			return zss, append(errs, SpanError{Index: index, Err: protowire.ParseError(m)}), true
+		}
+		protoBlob = protoBlob[n+m:]
+
+		if zms, err := parse(value, debugWasSet); err != nil {
+			errs = append(errs, SpanError{Index: index, Err: err})
 		} else {
-			if !errors.Is(err, ZERO_TIME) {
-				return nil, err
-			}
+			zss = append(zss, zms)
 		}
 	}
-	return zss, nil
+	return zss, errs, true
 }
 
 var errNilZipkinSpan = errors.New("expecting a non-nil Span")
 
+// modelSpan allocates a converted span along with its endpoints and parent id.
+type modelSpan struct {
+	span           zipkinmodel.SpanModel
+	localEndpoint  zipkinmodel.Endpoint
+	remoteEndpoint zipkinmodel.Endpoint
+	parentID       zipkinmodel.ID
+}
+
 func protoSpanToModelSpan(s *zipkin_proto3.Span, debugWasSet bool) (*zipkinmodel.SpanModel, error) {
 	if s == nil {
 		return nil, errNilZipkinSpan
 	}
-	traceID, err := zipkinmodel.TraceIDFromHex(fmt.Sprintf("%x", s.TraceId))
+	traceID, err := protoTraceIDToModelTraceID(s.TraceId)
 	if err != nil {
 		return nil, fmt.Errorf("invalid TraceID: %v", err)
 	}
 
-	parentSpanID, _, err := protoSpanIDToModelSpanID(s.ParentId)
+	m := &modelSpan{}
+	parentBlank, err := protoSpanIDToModelSpanID(s.ParentId, &m.parentID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ParentID: %v", err)
 	}
-	spanIDPtr, spanIDBlank, err := protoSpanIDToModelSpanID(s.Id)
+	zms := &m.span
+	spanIDBlank, err := protoSpanIDToModelSpanID(s.Id, &zms.ID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid SpanID: %v", err)
 	}
-	if spanIDBlank || spanIDPtr == nil {
+	if spanIDBlank {
 		// This is a logical error
 		return nil, errors.New("expected a non-nil SpanID")
 	}
 
-	zmsc := zipkinmodel.SpanContext{
-		TraceID:  traceID,
-		ID:       *spanIDPtr,
-		ParentID: parentSpanID,
-		Debug:    debugWasSet,
-	}
-	zms := &zipkinmodel.SpanModel{
-		SpanContext:    zmsc,
-		Name:           s.Name,
-		Kind:           zipkinmodel.Kind(s.Kind.String()),
-		Timestamp:      microsToTime(s.Timestamp),
-		Tags:           s.Tags,
-		Duration:       microsToDuration(s.Duration),
-		LocalEndpoint:  protoEndpointToModelEndpoint(s.LocalEndpoint),
-		RemoteEndpoint: protoEndpointToModelEndpoint(s.RemoteEndpoint),
-		Shared:         s.Shared,
-		Annotations:    protoAnnotationsToModelAnnotations(s.Annotations),
+	zms.TraceID = traceID
+	if !parentBlank {
+		zms.ParentID = &m.parentID
 	}
+	zms.Debug = debugWasSet
+	zms.Name = s.Name
+	zms.Kind = zipkinmodel.Kind(s.Kind.String())
+	zms.Timestamp = microsToTime(s.Timestamp)
+	zms.Tags = s.Tags
+	zms.Duration = microsToDuration(s.Duration)
+	zms.LocalEndpoint = protoEndpointToModelEndpoint(s.LocalEndpoint, &m.localEndpoint)
+	zms.RemoteEndpoint = protoEndpointToModelEndpoint(s.RemoteEndpoint, &m.remoteEndpoint)
+	zms.Shared = s.Shared
+	zms.Annotations = protoAnnotationsToModelAnnotations(s.Annotations)
+
+	return zms, nil
+}
 
-	if uint32(zms.Timestamp.Unix()) <= 0 {
-		return nil, ZERO_TIME
+// protoTraceIDToModelTraceID reads the big endian trace id, the ids longer than
+// 16 bytes are accepted when their extra leading bytes are zero.
+func protoTraceIDToModelTraceID(traceID []byte) (zipkinmodel.TraceID, error) {
+	if len(traceID) == 0 {
+		return zipkinmodel.TraceID{}, errors.New("empty trace id")
 	}
+	for ; len(traceID) > 16; traceID = traceID[1:] {
+		if traceID[0] != 0 {
+			return zipkinmodel.TraceID{}, fmt.Errorf("has length %d yet wanted at most 16 significant bytes", len(traceID))
+		}
+	}
+	var id zipkinmodel.TraceID
+	if len(traceID) > 8 {
+		id.High = bigEndianUint64(traceID[:len(traceID)-8])
+		traceID = traceID[len(traceID)-8:]
+	}
+	id.Low = bigEndianUint64(traceID)
+	return id, nil
+}
 
-	return zms, nil
+func bigEndianUint64(b []byte) (n uint64) {
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
 }
 
 func microsToDuration(us uint64) time.Duration {
 	return time.Duration(us * 1e3)
 }
 
-func protoEndpointToModelEndpoint(zpe *zipkin_proto3.Endpoint) *zipkinmodel.Endpoint {
+func protoEndpointToModelEndpoint(zpe *zipkin_proto3.Endpoint, dest *zipkinmodel.Endpoint) *zipkinmodel.Endpoint {
 	if zpe == nil {
 		return nil
 	}
-	return &zipkinmodel.Endpoint{
+	*dest = zipkinmodel.Endpoint{
 		ServiceName: zpe.ServiceName,
 		IPv4:        net.IP(zpe.Ipv4),
 		IPv6:        net.IP(zpe.Ipv6),
 		Port:        uint16(zpe.Port),
 	}
+	return dest
 }
 
-func protoSpanIDToModelSpanID(spanId []byte) (zid *zipkinmodel.ID, blank bool, err error) {
+// protoSpanIDToModelSpanID stores the span id in zid, blank is true when it is empty.
+func protoSpanIDToModelSpanID(spanId []byte, zid *zipkinmodel.ID) (blank bool, err error) {
 	if len(spanId) == 0 {
-		return nil, true, nil
+		return true, nil
 	}
 	if len(spanId) != 8 {
-		return nil, true, fmt.Errorf("has length %d yet wanted length 8", len(spanId))
+		return true, fmt.Errorf("has length %d yet wanted length 8", len(spanId))
 	}
 
-	u64 := binary.BigEndian.Uint64(spanId)
-	zid_ := zipkinmodel.ID(u64)
-	return &zid_, false, nil
+	*zid = zipkinmodel.ID(binary.BigEndian.Uint64(spanId))
+	return false, nil
 }
 
 func protoAnnotationsToModelAnnotations(zpa []*zipkin_proto3.Annotation) (zma []zipkinmodel.Annotation) {
+	if len(zpa) > 0 {
+		zma = make([]zipkinmodel.Annotation, 0, len(zpa))
+	}
 	for _, za := range zpa {
 		if za != nil {
 			zma = append(zma, zipkinmodel.Annotation{
@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func newProtoSpan(id uint64, name string) *zipkin_proto3.Span {
+	return &zipkin_proto3.Span{
+		TraceId:   UInt64ToTraceID(0, 1),
+		Id:        UInt64ToSpanID(id),
+		Name:      name,
+		Timestamp: 1659409534000000,
+		Duration:  1000,
+	}
+}
+
+func TestProtobufConvertorFraming(t *testing.T) {
+	list, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: []*zipkin_proto3.Span{newProtoSpan(1, "get"), newProtoSpan(2, "query")}})
+	single, _ := proto.Marshal(newProtoSpan(3, "single"))
+
+	spans, err := (&ProtobufConvertor{}).ParseSpans(list, false)
+	if err != nil || len(spans) != 2 || spans[1].Name != "query" {
+		t.Errorf("ListOfSpans: Expected 2 spans, Actual: %v %v", spans, err)
+	}
+	spans, err = (&ProtobufConvertor{}).ParseSpans(single, false)
+	if err != nil || len(spans) != 1 || spans[0].Name != "single" || spans[0].ID != 3 {
+		t.Errorf("Span: Expected the single span, Actual: %v %v", spans, err)
+	}
+}
+
+func TestProtobufConvertorPartialFailure(t *testing.T) {
+	valid, _ := proto.Marshal(newProtoSpan(1, "get"))
+	invalidID := newProtoSpan(2, "invalid")
+	invalidID.Id = []byte{1, 2, 3}
+	invalid, _ := proto.Marshal(invalidID)
+
+	var blob []byte
+	for _, span := range [][]byte{valid, invalid, {0xff}, valid} {
+		blob = protowire.AppendTag(blob, 1, protowire.BytesType)
+		blob = protowire.AppendBytes(blob, span)
+	}
+
+	spans, err := (&ProtobufConvertor{}).ParseSpans(blob, false)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected a partial error, Actual: %v", err)
+	}
+	if len(spans) != 2 || len(partial.Spans) != 2 || partial.Spans[0].Index != 1 || partial.Spans[1].Index != 2 {
+		t.Errorf("Expected 2 spans and the spans 1 and 2 invalid, Actual: %d spans, %v", len(spans), partial.Spans)
+	}
+
+	// a truncated message keeps the spans before the truncation
+	spans, err = (&ProtobufConvertor{}).ParseSpans(blob[:len(blob)-3], false)
+	if !errors.As(err, &partial) || len(spans) != 1 {
+		t.Errorf("Expected the first span of the truncated message, Actual: %d spans, %v", len(spans), err)
+	}
+}
+
+func TestParseMessageKeepsValidSpans(t *testing.T) {
+	payload := "[" + jsonSpan1 + `,{"traceId":"xyz"}]`
+	spans, err := NewConverter("json").(*TimestampConverter).ParseMessage([]byte(payload), false, time.Time{})
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(spans) != 1 {
+		t.Errorf("Expected 1 span with a partial error, Actual: %d spans, %v", len(spans), err)
+	}
+}
+
+func TestProtoTraceIDToModelTraceID(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		traceID := make([]byte, random.Intn(21))
+		random.Read(traceID)
+		for j := 0; j < len(traceID) && random.Intn(2) == 0; j++ {
+			traceID[j] = 0
+		}
+		expected, expectedErr := zipkinmodel.TraceIDFromHex(fmt.Sprintf("%x", traceID))
+		actual, err := protoTraceIDToModelTraceID(traceID)
+		if (err != nil) != (expectedErr != nil) || err == nil && actual != expected {
+			t.Errorf("%x: Expected %v %v, Actual: %v %v", traceID, expected, expectedErr, actual, err)
+		}
+	}
+}
+
+func TestProtobufConvertorConvertsEveryField(t *testing.T) {
+	protoSpans := benchmarkProtoSpans()
+	payload, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: protoSpans})
+	spans, err := (&ProtobufConvertor{}).ParseSpans(payload, true)
+	if err != nil || len(spans) != len(protoSpans) {
+		t.Fatalf("Expected %d spans, Actual: %d %v", len(protoSpans), len(spans), err)
+	}
+	for i, span := range spans {
+		s := protoSpans[i]
+		var parentID *zipkinmodel.ID
+		if len(s.ParentId) > 0 {
+			id := zipkinmodel.ID(bigEndianUint64(s.ParentId))
+			parentID = &id
+		}
+		expected := &zipkinmodel.SpanModel{
+			SpanContext: zipkinmodel.SpanContext{
+				TraceID:  zipkinmodel.TraceID{High: bigEndianUint64(s.TraceId[:8]), Low: bigEndianUint64(s.TraceId[8:])},
+				ID:       zipkinmodel.ID(bigEndianUint64(s.Id)),
+				ParentID: parentID,
+				Debug:    true,
+			},
+			Name:      s.Name,
+			Kind:      zipkinmodel.Kind(s.Kind.String()),
+			Timestamp: microsToTime(s.Timestamp),
+			Duration:  microsToDuration(s.Duration),
+			LocalEndpoint: &zipkinmodel.Endpoint{
+				ServiceName: s.LocalEndpoint.ServiceName,
+				IPv4:        net.IP(s.LocalEndpoint.Ipv4),
+				Port:        uint16(s.LocalEndpoint.Port),
+			},
+			RemoteEndpoint: &zipkinmodel.Endpoint{
+				ServiceName: s.RemoteEndpoint.ServiceName,
+				IPv4:        net.IP(s.RemoteEndpoint.Ipv4),
+				Port:        uint16(s.RemoteEndpoint.Port),
+			},
+			Annotations: []zipkinmodel.Annotation{
+				{Timestamp: microsToTime(s.Annotations[0].Timestamp), Value: s.Annotations[0].Value},
+				{Timestamp: microsToTime(s.Annotations[1].Timestamp), Value: s.Annotations[1].Value},
+			},
+			Tags: s.Tags,
+		}
+		if !reflect.DeepEqual(span, expected) {
+			t.Errorf("Span %d: Expected %+v, Actual: %+v", i, expected, span)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package converter
+
+import (
+	"unicode/utf8"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoDecoder decodes a zipkin_proto3.Span from the wire format straight into
+// the model, without the intermediate message of proto.Unmarshal. The strings
+// of the span are sliced from a single copy of its bytes.
+//
+// It only handles the spans proto.Unmarshal and protoSpanToModelSpan would
+// convert, and gives up on the rest: the invalid spans, the fields with an
+// unexpected wire type, the endpoints repeated and merged, etc. The caller
+// then falls back to the reference path, which decides the result.
+type protoDecoder struct {
+	data []byte
+	text string
+}
+
+// protoField is called with the number, the wire type and the value of a field.
+// The value of a bytes field is its content starting at offset in the data, the
+// value of the other fields is their raw encoding.
+type protoField func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool
+
+// decodeProtoSpan returns the span encoded in data, ok is false if the reference
+// path has to decode it.
+func decodeProtoSpan(data []byte, debugWasSet bool) (zms *zipkinmodel.SpanModel, ok bool) {
+	d := &protoDecoder{data: data}
+	annotations, tags, ok := d.count()
+	if !ok {
+		return nil, false
+	}
+	d.text = string(data)
+
+	m := &modelSpan{}
+	zms = &m.span
+	if annotations > 0 {
+		zms.Annotations = make([]zipkinmodel.Annotation, 0, annotations)
+	}
+	if tags > 0 {
+		zms.Tags = make(map[string]string, tags)
+	}
+	var traceID, parentID, spanID []byte
+	var kind, timestamp uint64
+	ok = d.fields(0, len(data), func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool {
+		switch num {
+		case 1:
+			traceID = value
+			return typ == protowire.BytesType
+		case 2:
+			parentID = value
+			return typ == protowire.BytesType
+		case 3:
+			spanID = value
+			return typ == protowire.BytesType
+		case 4:
+			// the unknown kinds are left to the reference path
+			kind, ok = varint(typ, value)
+			return ok && kind <= uint64(zipkin_proto3.Span_CONSUMER)
+		case 5:
+			zms.Name, ok = d.string(typ, value, offset)
+			return ok
+		case 6:
+			timestamp, ok = fixed64(typ, value)
+			return ok
+		case 7:
+			duration, ok := varint(typ, value)
+			zms.Duration = microsToDuration(duration)
+			return ok
+		case 8:
+			if zms.LocalEndpoint != nil {
+				return false
+			}
+			zms.LocalEndpoint = &m.localEndpoint
+			return d.endpoint(typ, value, offset, zms.LocalEndpoint)
+		case 9:
+			if zms.RemoteEndpoint != nil {
+				return false
+			}
+			zms.RemoteEndpoint = &m.remoteEndpoint
+			return d.endpoint(typ, value, offset, zms.RemoteEndpoint)
+		case 10:
+			return d.annotation(typ, value, offset, zms)
+		case 11:
+			return d.tag(typ, value, offset, zms.Tags)
+		case 12:
+			_, ok := varint(typ, value)
+			return ok
+		case 13:
+			shared, ok := varint(typ, value)
+			zms.Shared = protowire.DecodeBool(shared)
+			return ok
+		}
+		return true
+	})
+	if !ok {
+		return nil, false
+	}
+
+	var err error
+	if zms.TraceID, err = protoTraceIDToModelTraceID(traceID); err != nil {
+		return nil, false
+	}
+	if len(spanID) != 8 || len(parentID) != 0 && len(parentID) != 8 {
+		return nil, false
+	}
+	zms.ID = zipkinmodel.ID(bigEndianUint64(spanID))
+	if len(parentID) == 8 {
+		m.parentID = zipkinmodel.ID(bigEndianUint64(parentID))
+		zms.ParentID = &m.parentID
+	}
+	zms.Kind = zipkinmodel.Kind(zipkin_proto3.Span_Kind(kind).String())
+	zms.Timestamp = microsToTime(timestamp)
+	zms.Debug = debugWasSet
+	if len(zms.Annotations) == 0 {
+		zms.Annotations = nil
+	}
+	return zms, true
+}
+
+// count returns the number of annotations and tags of the span to size them.
+func (d *protoDecoder) count() (annotations, tags int, ok bool) {
+	ok = d.fields(0, len(d.data), func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool {
+		switch num {
+		case 10:
+			annotations++
+		case 11:
+			tags++
+		}
+		return true
+	})
+	return annotations, tags, ok
+}
+
+// fields walks the fields of data[start:end] and stops at the first field for
+// which field returns false.
+func (d *protoDecoder) fields(start, end int, field protoField) bool {
+	for pos := start; pos < end; {
+		num, typ, n := protowire.ConsumeTag(d.data[pos:end])
+		if n < 0 {
+			return false
+		}
+		pos += n
+		var value []byte
+		var m int
+		switch typ {
+		case protowire.VarintType:
+			_, m = protowire.ConsumeVarint(d.data[pos:end])
+		case protowire.Fixed32Type:
+			_, m = protowire.ConsumeFixed32(d.data[pos:end])
+		case protowire.Fixed64Type:
+			_, m = protowire.ConsumeFixed64(d.data[pos:end])
+		case protowire.BytesType:
+			value, m = protowire.ConsumeBytes(d.data[pos:end])
+		default:
+			// the groups are left to proto.Unmarshal
+			return false
+		}
+		if m < 0 {
+			return false
+		}
+		offset := pos + m - len(value)
+		if typ != protowire.BytesType {
+			value = d.data[pos : pos+m]
+		}
+		pos += m
+		if !field(num, typ, value, offset) {
+			return false
+		}
+	}
+	return true
+}
+
+// endpoint decodes the zipkin_proto3.Endpoint message into dest.
+func (d *protoDecoder) endpoint(typ protowire.Type, value []byte, offset int, dest *zipkinmodel.Endpoint) bool {
+	if typ != protowire.BytesType {
+		return false
+	}
+	return d.fields(offset, offset+len(value), func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool {
+		var ok bool
+		switch num {
+		case 1:
+			dest.ServiceName, ok = d.string(typ, value, offset)
+			return ok
+		case 2:
+			dest.IPv4, ok = bytesValue(typ, value)
+			return ok
+		case 3:
+			dest.IPv6, ok = bytesValue(typ, value)
+			return ok
+		case 4:
+			port, ok := varint(typ, value)
+			dest.Port = uint16(int32(port))
+			return ok
+		}
+		return true
+	})
+}
+
+// annotation decodes the zipkin_proto3.Annotation message and appends it.
+func (d *protoDecoder) annotation(typ protowire.Type, value []byte, offset int, zms *zipkinmodel.SpanModel) bool {
+	if typ != protowire.BytesType {
+		return false
+	}
+	var timestamp uint64
+	var annotation string
+	ok := d.fields(offset, offset+len(value), func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool {
+		var ok bool
+		switch num {
+		case 1:
+			timestamp, ok = fixed64(typ, value)
+			return ok
+		case 2:
+			annotation, ok = d.string(typ, value, offset)
+			return ok
+		}
+		return true
+	})
+	if ok {
+		zms.Annotations = append(zms.Annotations, zipkinmodel.Annotation{
+			Timestamp: microsToTime(timestamp),
+			Value:     annotation,
+		})
+	}
+	return ok
+}
+
+// tag decodes an entry of the tags map, the missing key or value is empty.
+func (d *protoDecoder) tag(typ protowire.Type, value []byte, offset int, tags map[string]string) bool {
+	if typ != protowire.BytesType {
+		return false
+	}
+	var key, tag string
+	ok := d.fields(offset, offset+len(value), func(num protowire.Number, typ protowire.Type, value []byte, offset int) bool {
+		var ok bool
+		switch num {
+		case 1:
+			key, ok = d.string(typ, value, offset)
+			return ok
+		case 2:
+			tag, ok = d.string(typ, value, offset)
+			return ok
+		}
+		return true
+	})
+	if ok {
+		tags[key] = tag
+	}
+	return ok
+}
+
+// string slices the string field from the copy of the data, proto3 requires it
+// to be valid UTF-8.
+func (d *protoDecoder) string(typ protowire.Type, value []byte, offset int) (string, bool) {
+	if typ != protowire.BytesType || !utf8.Valid(value) {
+		return "", false
+	}
+	return d.text[offset : offset+len(value)], true
+}
+
+// bytesValue copies the bytes field, empty is nil as with proto.Unmarshal.
+func bytesValue(typ protowire.Type, value []byte) ([]byte, bool) {
+	if typ != protowire.BytesType {
+		return nil, false
+	}
+	return append([]byte(nil), value...), true
+}
+
+func varint(typ protowire.Type, value []byte) (uint64, bool) {
+	if typ != protowire.VarintType {
+		return 0, false
+	}
+	v, _ := protowire.ConsumeVarint(value)
+	return v, true
+}
+
+func fixed64(typ protowire.Type, value []byte) (uint64, bool) {
+	if typ != protowire.Fixed64Type {
+		return 0, false
+	}
+	v, _ := protowire.ConsumeFixed64(value)
+	return v, true
+}
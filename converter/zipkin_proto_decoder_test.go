@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// assertSameProtoResult checks that the decoder and the reference path agree on the payload.
+func assertSameProtoResult(t *testing.T, name string, payload []byte) {
+	t.Helper()
+	spans, err := (&ProtobufConvertor{}).ParseSpans(payload, false)
+	expectedSpans, expectedErr := parseProtoSpans(payload, false, parseSpan)
+	if fmt.Sprint(err) != fmt.Sprint(expectedErr) {
+		t.Errorf("%s: Expected error %v, Actual: %v, payload %x", name, expectedErr, err, payload)
+	}
+	if !reflect.DeepEqual(spans, expectedSpans) {
+		t.Errorf("%s: Expected %+v, Actual: %+v, payload %x", name, expectedSpans, spans, payload)
+	}
+}
+
+func TestProtoDecoderMatchesTheReference(t *testing.T) {
+	marshal := func(message proto.Message) []byte {
+		data, _ := proto.Marshal(message)
+		return data
+	}
+	field := func(num protowire.Number, typ protowire.Type, value ...byte) []byte {
+		return append(protowire.AppendTag(nil, num, typ), value...)
+	}
+	bytesField := func(num protowire.Number, value []byte) []byte {
+		return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), value)
+	}
+	join := func(parts ...[]byte) (data []byte) {
+		for _, part := range parts {
+			data = append(data, part...)
+		}
+		return data
+	}
+	span := marshal(benchmarkProtoSpans()[1])
+	minimal := marshal(newProtoSpan(1, "get"))
+	endpoint := marshal(benchmarkProtoSpans()[1].LocalEndpoint)
+	list, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: benchmarkProtoSpans()})
+
+	cases := map[string][]byte{
+		"list":                list,
+		"span":                span,
+		"minimal":             minimal,
+		"empty":               {},
+		"no kind":             marshal(&zipkin_proto3.Span{TraceId: UInt64ToTraceID(0, 1), Id: UInt64ToSpanID(1)}),
+		"unknown kind":        join(minimal, field(4, protowire.VarintType, 7)),
+		"negative kind":       join(minimal, field(4, protowire.VarintType, protowire.AppendVarint(nil, ^uint64(0))...)),
+		"empty endpoint":      join(minimal, bytesField(8, nil)),
+		"repeated endpoint":   join(minimal, bytesField(8, endpoint), bytesField(8, bytesField(1, []byte("other")))),
+		"endpoint empty ip":   join(minimal, bytesField(9, bytesField(2, nil))),
+		"endpoint odd ip":     join(minimal, bytesField(9, bytesField(3, []byte{1, 2, 3}))),
+		"negative port":       join(minimal, bytesField(9, field(4, protowire.VarintType, protowire.AppendVarint(nil, ^uint64(0))...))),
+		"large port":          join(minimal, bytesField(9, field(4, protowire.VarintType, protowire.AppendVarint(nil, 1<<40|8080)...))),
+		"endpoint unknown":    join(minimal, bytesField(8, join(endpoint, field(7, protowire.Fixed32Type, 1, 2, 3, 4)))),
+		"endpoint bad name":   join(minimal, bytesField(8, bytesField(1, []byte{0xff}))),
+		"endpoint wrong type": join(minimal, bytesField(8, field(1, protowire.VarintType, 1))),
+		"invalid utf8 name":   join(minimal, bytesField(5, []byte("get \xff"))),
+		"repeated name":       join(minimal, bytesField(5, []byte("other"))),
+		"empty name":          join(minimal, bytesField(5, nil)),
+		"name wrong type":     join(minimal, field(5, protowire.VarintType, 1)),
+		"empty annotation":    join(minimal, bytesField(10, nil)),
+		"annotation no value": join(minimal, bytesField(10, field(1, protowire.Fixed64Type, 1, 0, 0, 0, 0, 0, 0, 0))),
+		"annotation bad time": join(minimal, bytesField(10, field(1, protowire.VarintType, 1))),
+		"tag no value":        join(minimal, bytesField(11, bytesField(1, []byte("key")))),
+		"tag no key":          join(minimal, bytesField(11, bytesField(2, []byte("value")))),
+		"empty tag":           join(minimal, bytesField(11, nil)),
+		"duplicate tag":       join(minimal, bytesField(11, bytesField(1, []byte("a"))), bytesField(11, join(bytesField(1, []byte("a")), bytesField(2, []byte("b"))))),
+		"tag repeated key":    join(minimal, bytesField(11, join(bytesField(1, []byte("a")), bytesField(1, []byte("b"))))),
+		"tag invalid utf8":    join(minimal, bytesField(11, bytesField(2, []byte{0xc3}))),
+		"tag unknown field":   join(minimal, bytesField(11, join(bytesField(1, []byte("a")), field(3, protowire.VarintType, 1)))),
+		"tags wrong type":     join(minimal, field(11, protowire.VarintType, 1)),
+		"unknown fields":      join(field(20, protowire.VarintType, 1), minimal, field(21, protowire.Fixed64Type, 1, 2, 3, 4, 5, 6, 7, 8), bytesField(22, []byte("x"))),
+		"group":               join(minimal, field(20, protowire.StartGroupType), field(20, protowire.EndGroupType)),
+		"field zero":          join(minimal, field(0, protowire.VarintType, 1)),
+		"truncated":           span[:len(span)-1],
+		"truncated varint":    join(minimal, protowire.AppendTag(nil, 7, protowire.VarintType), []byte{0x80}),
+		"long trace id":       join(minimal, bytesField(1, append(make([]byte, 4), UInt64ToTraceID(1, 2)...))),
+		"overflow trace id":   join(minimal, bytesField(1, append([]byte{1}, UInt64ToTraceID(1, 2)...))),
+		"short trace id":      join(minimal, bytesField(1, []byte{1, 2, 3})),
+		"empty trace id":      join(minimal, bytesField(1, nil)),
+		"missing id":          marshal(&zipkin_proto3.Span{TraceId: UInt64ToTraceID(0, 1), Name: "get"}),
+		"short id":            join(minimal, bytesField(3, []byte{1, 2, 3})),
+		"empty parent id":     join(minimal, bytesField(2, nil)),
+		"short parent id":     join(minimal, bytesField(2, []byte{1, 2, 3, 4})),
+		"ids wrong type":      join(minimal, field(3, protowire.Fixed64Type, 1, 2, 3, 4, 5, 6, 7, 8)),
+		"timestamp as varint": join(minimal, field(6, protowire.VarintType, 1)),
+		"duration as fixed":   join(minimal, field(7, protowire.Fixed64Type, 1, 2, 3, 4, 5, 6, 7, 8)),
+		"shared two":          join(minimal, field(13, protowire.VarintType, 2)),
+		"debug":               join(minimal, field(12, protowire.VarintType, 1)),
+		"debug wrong type":    join(minimal, field(12, protowire.Fixed32Type, 1, 0, 0, 0)),
+		"list of invalid":     join(bytesField(1, minimal), bytesField(1, []byte{0xff}), bytesField(1, span)),
+	}
+	for name, payload := range cases {
+		assertSameProtoResult(t, name, payload)
+	}
+}
+
+func TestProtoDecoderMatchesTheReferenceOnMutations(t *testing.T) {
+	spans := benchmarkProtoSpans()[:3]
+	spans[0].ParentId = nil
+	spans[2].Annotations = nil
+	payload, _ := proto.Marshal(&zipkin_proto3.ListOfSpans{Spans: spans})
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		mutated := append([]byte(nil), payload...)
+		for n := random.Intn(3) + 1; n > 0; n-- {
+			at := random.Intn(len(mutated))
+			switch random.Intn(4) {
+			case 0:
+				mutated[at] = byte(random.Intn(256))
+			case 1:
+				mutated[at] ^= 1 << uint(random.Intn(8))
+			case 2:
+				mutated = append(mutated[:at], mutated[at+1:]...)
+			default:
+				mutated = append(mutated[:at], append([]byte{byte(random.Intn(256))}, mutated[at:]...)...)
+			}
+		}
+		assertSameProtoResult(t, fmt.Sprintf("mutation %d", i), mutated)
+	}
+}
+
+func TestProtoDecoderDecodesWithoutTheReference(t *testing.T) {
+	for i, span := range benchmarkProtoSpans() {
+		data, _ := proto.Marshal(span)
+		if _, ok := decodeProtoSpan(data, false); !ok {
+			t.Errorf("Span %d: Expected the span to be decoded without the reference path", i)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package credentials
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderStatic     = "static"
+	ProviderStsFile    = "sts_file"
+	ProviderEcsRamRole = "ecs_ram_role"
+)
+
+// Credentials is an access key pair, with a security token and an expiration if it is temporary.
+type Credentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      time.Time
+}
+
+// String hides the secrets of the credentials so they can't leak into the logs.
+func (c *Credentials) String() string {
+	return fmt.Sprintf("Credentials{AccessKeyID: %s, Expiration: %v}", Redact(c.AccessKeyID), c.Expiration)
+}
+
+type Provider interface {
+	// Credentials returns the current credentials, refreshing them if needed.
+	Credentials() (*Credentials, error)
+}
+
+// Config selects and configures a credential provider.
+type Config struct {
+	Provider         string
+	AccessKey        string
+	AccessSecret     string
+	StsTokenFile     string
+	RamRole          string
+	MetadataEndpoint string
+}
+
+func NewProvider(config Config) (Provider, error) {
+	switch strings.ToLower(config.Provider) {
+	case "", ProviderStatic:
+		return NewStaticProvider(config.AccessKey, config.AccessSecret), nil
+	case ProviderStsFile:
+		if config.StsTokenFile == "" {
+			return nil, fmt.Errorf("the sts token file is empty")
+		}
+		return NewFileProvider(config.StsTokenFile), nil
+	case ProviderEcsRamRole:
+		return NewEcsRamRoleProvider(config.MetadataEndpoint, config.RamRole), nil
+	default:
+		return nil, fmt.Errorf("unknown credential provider %q", config.Provider)
+	}
+}
+
+// Redact keeps the first characters of a secret for troubleshooting.
+func Redact(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
+
+// IsStatic tells whether the credentials of the provider never change.
+func IsStatic(provider Provider) bool {
+	_, ok := provider.(*StaticProvider)
+	return ok
+}
+
+// StaticProvider returns a fixed access key pair.
+type StaticProvider struct {
+	credentials Credentials
+}
+
+func NewStaticProvider(accessKey, accessSecret string) *StaticProvider {
+	return &StaticProvider{credentials: Credentials{AccessKeyID: accessKey, AccessKeySecret: accessSecret}}
+}
+
+func (s *StaticProvider) Credentials() (*Credentials, error) {
+	c := s.credentials
+	return &c, nil
+}
@@ -0,0 +1,109 @@
+package credentials
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileProviderReadsChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+
+	writeToken(t, path, `{"AccessKeyId":"ak1","AccessKeySecret":"sk1","SecurityToken":"token1","Expiration":"2030-01-01T00:00:00Z"}`)
+	provider := NewFileProvider(path)
+	c, err := provider.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ak1" || c.SecurityToken != "token1" || c.Expiration.Year() != 2030 {
+		t.Errorf("unexpected credentials %+v", *c)
+	}
+
+	writeToken(t, path, `{"AccessKeyId":"ak2","AccessKeySecret":"sk2","SecurityToken":"token-2"}`)
+	// make sure the change is visible even on file systems with a coarse mtime
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if c, err = provider.Credentials(); err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ak2" || c.AccessKeySecret != "sk2" || c.SecurityToken != "token-2" {
+		t.Errorf("the file change is not picked up: %+v", *c)
+	}
+
+	writeToken(t, path, `{"Code":"Failed"}`)
+	os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute))
+	if _, err = provider.Credentials(); err == nil {
+		t.Error("expected an error for a failed token")
+	}
+}
+
+func TestEcsRamRoleProvider(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ramRolePath:
+			fmt.Fprint(w, "ingester-role")
+		case ramRolePath + "ingester-role":
+			n := atomic.AddInt32(&fetches, 1)
+			fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"STS.ak%d","AccessKeySecret":"sk","SecurityToken":"token","Expiration":"%s"}`,
+				n, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewEcsRamRoleProvider(server.URL, "")
+	now := time.Date(2029, 12, 31, 23, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+
+	c, err := provider.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "STS.ak1" {
+		t.Errorf("unexpected access key %s", c.AccessKeyID)
+	}
+	if c, _ = provider.Credentials(); c.AccessKeyID != "STS.ak1" {
+		t.Errorf("the cached credentials should be used, got %s", c.AccessKeyID)
+	}
+
+	now = now.Add(56 * time.Minute)
+	if c, _ = provider.Credentials(); c.AccessKeyID != "STS.ak2" {
+		t.Errorf("the credentials should be refreshed before they expire, got %s", c.AccessKeyID)
+	}
+}
+
+func TestEcsRamRoleProviderError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := NewEcsRamRoleProvider(server.URL, "missing").Credentials(); err == nil {
+		t.Error("expected an error for a missing role")
+	}
+}
+
+func TestCredentialsStringRedactsSecrets(t *testing.T) {
+	c := &Credentials{AccessKeyID: "LTAI5tExample", AccessKeySecret: "secret-value", SecurityToken: "token-value"}
+	s := fmt.Sprint(c)
+	if strings.Contains(s, "secret-value") || strings.Contains(s, "token-value") || strings.Contains(s, "LTAI5tExample") {
+		t.Errorf("the secrets leak: %s", s)
+	}
+}
+
+func writeToken(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMetadataEndpoint = "http://100.100.100.200"
+
+const ramRolePath = "/latest/meta-data/ram/security-credentials/"
+
+// EcsRamRoleProvider fetches the STS token of the RAM role attached to the ECS
+// instance from the metadata service, and fetches it again before it expires.
+type EcsRamRoleProvider struct {
+	endpoint string
+	role     string
+	client   *http.Client
+	now      func() time.Time
+
+	lock        sync.Mutex
+	credentials *Credentials
+}
+
+// NewEcsRamRoleProvider creates the provider, the role is discovered from the metadata service if empty.
+func NewEcsRamRoleProvider(endpoint, role string) *EcsRamRoleProvider {
+	if endpoint == "" {
+		endpoint = DefaultMetadataEndpoint
+	}
+	return &EcsRamRoleProvider{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		role:     role,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		now:      time.Now,
+	}
+}
+
+func (e *EcsRamRoleProvider) Credentials() (*Credentials, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// Refresh 5 minutes ahead so the exporters never see expired credentials.
+	if e.credentials != nil && e.now().Add(5*time.Minute).Before(e.credentials.Expiration) {
+		c := *e.credentials
+		return &c, nil
+	}
+
+	if e.role == "" {
+		role, err := e.get(ramRolePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover the ram role: %w", err)
+		}
+		e.role = strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0])
+	}
+
+	data, err := e.get(ramRolePath + e.role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the credentials of the ram role %s: %w", e.role, err)
+	}
+	var token stsToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid credentials of the ram role %s: %w", e.role, err)
+	}
+	credentials, err := token.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	e.credentials = credentials
+	c := *credentials
+	return &c, nil
+}
+
+func (e *EcsRamRoleProvider) get(path string) ([]byte, error) {
+	resp, err := e.client.Get(e.endpoint + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the metadata service responded %s", resp.Status)
+	}
+	return data, nil
+}
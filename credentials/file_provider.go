@@ -0,0 +1,88 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// stsToken is the document of the STS token files and of the ECS metadata service.
+type stsToken struct {
+	Code            string `json:"Code"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	AccessKeySecret string `json:"AccessKeySecret"`
+	SecurityToken   string `json:"SecurityToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+func (t *stsToken) credentials() (*Credentials, error) {
+	if t.Code != "" && t.Code != "Success" {
+		return nil, fmt.Errorf("the sts token is not valid, code: %s", t.Code)
+	}
+	if t.AccessKeyID == "" || t.AccessKeySecret == "" {
+		return nil, fmt.Errorf("the sts token has no access key")
+	}
+
+	c := &Credentials{
+		AccessKeyID:     t.AccessKeyID,
+		AccessKeySecret: t.AccessKeySecret,
+		SecurityToken:   t.SecurityToken,
+	}
+	if t.Expiration != "" {
+		expiration, err := time.Parse(time.RFC3339, t.Expiration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration of the sts token: %w", err)
+		}
+		c.Expiration = expiration
+	}
+	return c, nil
+}
+
+// FileProvider reads a STS token from a JSON file, e.g. written by a sidecar,
+// and reads it again whenever the file changes.
+type FileProvider struct {
+	path string
+
+	lock        sync.Mutex
+	modTime     time.Time
+	size        int64
+	credentials *Credentials
+}
+
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+func (f *FileProvider) Credentials() (*Credentials, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.credentials != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		c := *f.credentials
+		return &c, nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var token stsToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid sts token file %s: %w", f.path, err)
+	}
+	credentials, err := token.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	f.credentials, f.modTime, f.size = credentials, info.ModTime(), info.Size()
+	c := *credentials
+	return &c, nil
+}
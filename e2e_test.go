@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"github.com/aliyun-sls/zipkin-ingester/slstest"
+	"github.com/aliyun-sls/zipkin-ingester/telemetry"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"go.uber.org/zap"
+)
+
+// fakeIngester returns its messages one per poll, like a consumer of a topic holding them.
+type fakeIngester struct {
+	mu       sync.Mutex
+	messages []*receiver.Message
+	closed   bool
+}
+
+func (f *fakeIngester) IngestTrace(sugar *zap.SugaredLogger) ([]byte, error) {
+	msg, err := f.IngestMessage(sugar)
+	if msg == nil {
+		return nil, err
+	}
+	return msg.Value, err
+}
+
+func (f *fakeIngester) IngestMessage(*zap.SugaredLogger) (*receiver.Message, error) {
+	f.mu.Lock()
+	if len(f.messages) == 0 {
+		f.mu.Unlock()
+		// the poll timed out
+		time.Sleep(time.Millisecond)
+		return nil, nil
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	f.mu.Unlock()
+	return msg, nil
+}
+
+func (f *fakeIngester) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+// ingest consumes the messages of every source of the configuration with the
+// consuming loop of main, then shuts it down.
+func ingest(t *testing.T, config *configure.Configuration, messages map[string][]*receiver.Message) {
+	sugar := zap.NewNop().Sugar()
+	current, err := newPipeline(config, nil, sugar)
+	if err != nil {
+		t.Fatalf("Failed to create the pipeline. %v", err)
+	}
+	tracer, _ := telemetry.New(config)
+	consumers := &sources{
+		messages: make(chan receiver.SourceMessage),
+		drains:   make(chan chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	var ingesters []*fakeIngester
+	total := 0
+	for _, definition := range config.EffectiveSources() {
+		ingester := &fakeIngester{messages: messages[definition.Name]}
+		ingesters = append(ingesters, ingester)
+		total += len(ingester.messages)
+		consumers.sources = append(consumers.sources, receiver.NewSourceWithIngester(definition, ingester, sugar))
+	}
+	for _, source := range consumers.sources {
+		source.Start(consumers.messages)
+	}
+
+	consuming := newLoop(current, consumers, &reloader{sugar: sugar}, tracer, sugar)
+	for i := 0; i < total; i++ {
+		select {
+		case msg := <-consumers.messages:
+			consuming.handle(msg)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Expected %d messages, Actual: %d", total, i)
+		}
+	}
+	consuming.stop()
+	consuming.close(time.Now().Add(config.ShutdownTimeout))
+	for _, ingester := range ingesters {
+		if !ingester.closed {
+			t.Error("Expected the ingesters to be closed")
+		}
+	}
+}
+
+// sortedContents returns the contents of the logs sorted by span id.
+func sortedContents(logs []*slsSdk.Log) []map[string]string {
+	contents := make([]map[string]string, 0, len(logs))
+	for _, log := range logs {
+		contents = append(contents, slstest.Contents(log))
+	}
+	sort.Slice(contents, func(i, j int) bool { return contents[i]["spanID"] < contents[j]["spanID"] })
+	return contents
+}
+
+func gzipped(t *testing.T, payload string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEndToEnd(t *testing.T) {
+	server := slstest.NewServer(slstest.Options{AccessKeys: map[string]string{
+		"test-id": "test-secret",
+		"prod-id": "prod-secret",
+	}})
+	defer server.Close()
+	config := &configure.Configuration{
+		Project:         "project",
+		Instance:        "instance",
+		Endpoint:        server.Endpoint,
+		AccessKey:       "test-id",
+		AccessSecret:    "test-secret",
+		TimestampPolicy: "kafka",
+		LogLevel:        "error",
+		ShutdownTimeout: 10 * time.Second,
+		Sources: []configure.Source{
+			{Name: "legacy", Topics: []string{"zipkin"}, Protocol: "json"},
+			{Name: "cloud", Topics: []string{"traces"}, Protocol: "protobuf", Route: "prod"},
+		},
+		Routes: []configure.Route{
+			{Name: "prod", Environments: []string{"prod"}, Project: "prod-project", Instance: "prod", AccessKey: "prod-id", AccessSecret: "prod-secret"},
+		},
+	}
+
+	client := `{"traceId":"5af7183fb1d4cf5f","id":"352bff9a74ca9ad2","kind":"CLIENT","name":"get /api",
+		"timestamp":1659409534000000,"duration":207000,
+		"localEndpoint":{"serviceName":"frontend","ipv4":"192.168.99.1","port":8080},
+		"remoteEndpoint":{"serviceName":"backend","ipv4":"172.19.0.2","port":9000},
+		"annotations":[{"timestamp":1659409534100000,"value":"ws"}],
+		"tags":{"http.method":"GET","http.path":"/api","http.status_code":"500","error":"boom"}}`
+	backend := `{"traceId":"5af7183fb1d4cf5f","parentId":"352bff9a74ca9ad2","id":"6b221d5bc9e6496c","kind":"SERVER","name":"get /api",
+		"timestamp":1659409534010000,"duration":150000,"shared":true,
+		"localEndpoint":{"serviceName":"backend","ipv4":"172.19.0.2","port":9000},
+		"tags":{"http.status_code":"200","db.instance":"orders"}}`
+	parentID := zipkinmodel.ID(0x6b221d5bc9e6496c)
+	proto, err := zipkin_proto3.SpanSerializer{}.Serialize([]*zipkinmodel.SpanModel{{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID:  zipkinmodel.TraceID{High: 0x463ac35c9f6413ad, Low: 0x48485a3953bb6124},
+			ID:       0x0cadf1e6e5a0bcf7,
+			ParentID: &parentID,
+		},
+		Name:          "select",
+		Kind:          zipkinmodel.Client,
+		Duration:      3 * time.Millisecond,
+		LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "orders", IPv4: net.ParseIP("10.0.0.5")},
+		Tags:          map[string]string{"sql.query": "select 1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ingest(t, config, map[string][]*receiver.Message{
+		"legacy": {
+			{Topic: "zipkin", Offset: 1, Value: []byte("[" + client + "]")},
+			{Topic: "zipkin", Offset: 2, Value: []byte("not spans")},
+			{Topic: "zipkin", Offset: 3, Value: gzipped(t, "["+backend+"]"), Headers: map[string]string{"Content-Encoding": "gzip"}},
+		},
+		"cloud": {
+			{Topic: "traces", Offset: 7, Value: proto, Timestamp: time.Unix(1659409535, 0)},
+		},
+	})
+
+	expected := []map[string]string{
+		{
+			"attribute":     `{"http.request.method":"GET","http.response.status_code":"500","net.host.ip":"192.168.99.1","net.host.port":8080,"net.peer.ip":"172.19.0.2","net.peer.port":9000,"peer.service":"backend","url.path":"/api"}`,
+			"duration":      "207000",
+			"end":           "1659409534207000",
+			"kind":          "client",
+			"links":         "[]",
+			"logs":          `[{"attribute":{"Name":"ws"},"time":1659409534100000000}]`,
+			"name":          "get /api",
+			"parentSpanID":  "",
+			"resource":      `{"service.name":"frontend"}`,
+			"service":       "frontend",
+			"spanID":        "352bff9a74ca9ad2",
+			"start":         "1659409534000000",
+			"statusCode":    "ERROR",
+			"statusMessage": "boom",
+			"traceID":       "5af7183fb1d4cf5f",
+		},
+		{
+			"attribute":     `{"db.instance":"orders","http.response.status_code":"200","net.host.ip":"172.19.0.2","net.host.port":9000}`,
+			"duration":      "150000",
+			"end":           "1659409534160000",
+			"kind":          "server",
+			"links":         "[]",
+			"logs":          "[]",
+			"name":          "get /api",
+			"parentSpanID":  "352bff9a74ca9ad2",
+			"resource":      `{"service.name":"backend"}`,
+			"service":       "backend",
+			"spanID":        "6b221d5bc9e6496c",
+			"start":         "1659409534010000",
+			"statusCode":    "UNSET",
+			"statusMessage": "",
+			"traceID":       "5af7183fb1d4cf5f",
+		},
+	}
+	if actual := sortedContents(server.Logs("project", "instance-traces")); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, actual)
+	}
+	// the span without timestamp starts at the timestamp of its kafka message
+	routed := []map[string]string{{
+		"attribute":     `{"db.query.text":"select 1","net.host.ip":"10.0.0.5"}`,
+		"duration":      "3000",
+		"end":           "1659409535003000",
+		"kind":          "client",
+		"links":         "[]",
+		"logs":          "[]",
+		"name":          "select",
+		"parentSpanID":  "6b221d5bc9e6496c",
+		"resource":      `{"service.name":"orders"}`,
+		"service":       "orders",
+		"spanID":        "0cadf1e6e5a0bcf7",
+		"start":         "1659409535000000",
+		"statusCode":    "UNSET",
+		"statusMessage": "",
+		"traceID":       "463ac35c9f6413ad48485a3953bb6124",
+	}}
+	if actual := sortedContents(server.Logs("prod-project", "prod-traces")); !reflect.DeepEqual(actual, routed) {
+		t.Errorf("Expected %v, Actual: %v", routed, actual)
+	}
+	if rejected := server.Rejected(); len(rejected) != 0 {
+		t.Errorf("Expected no rejected request, Actual: %v", rejected)
+	}
+}
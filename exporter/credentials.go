@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/credentials"
+	"github.com/aliyun-sls/zipkin-ingester/logging"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+)
+
+// stsRefreshInterval bounds how long the producers keep credentials, so that the
+// changes of a STS token file are picked up even if the token has no expiration.
+const stsRefreshInterval = time.Minute
+
+// newProducerConfig creates a producer config whose credentials are refreshed from the provider.
+func newProducerConfig(configure *configure.Configuration) (*producer.ProducerConfig, error) {
+	provider := configure.CredentialsProvider()
+	c, err := provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	producerConfig := producer.GetDefaultProducerConfig()
+	producerConfig.Endpoint = configure.Endpoint
+	setProducerLogging(producerConfig, configure)
+	if credentials.IsStatic(provider) {
+		producerConfig.AccessKeyID = c.AccessKeyID
+		producerConfig.AccessKeySecret = c.AccessKeySecret
+	} else {
+		producerConfig.UpdateStsToken = stsTokenFunc(provider)
+		producerConfig.StsTokenShutDown = make(chan struct{})
+	}
+	return producerConfig, nil
+}
+
+// setProducerLogging applies the logging configuration to the own logger of the
+// producer. It can't share the log file of the ingester, which it would rotate
+// on its own, so it writes next to it, e.g. ingester-producer.log for ingester.log.
+// Its entries are not sampled and its rotated files are not removed by age.
+func setProducerLogging(producerConfig *producer.ProducerConfig, configure *configure.Configuration) {
+	producerConfig.AllowLogLevel = configure.LogLevel
+	json := !strings.EqualFold(configure.LogFormat, logging.FormatConsole)
+	producerConfig.IsJsonType = json
+	if configure.LogFile == "" {
+		return
+	}
+	ext := filepath.Ext(configure.LogFile)
+	producerConfig.LogFileName = strings.TrimSuffix(configure.LogFile, ext) + "-producer" + ext
+	producerConfig.LogMaxSize = configure.LogMaxSize
+	producerConfig.LogMaxBackups = configure.LogMaxBackups
+	// the producer writes logfmt to its file if IsJsonType is set, and json otherwise
+	producerConfig.IsJsonType = !json
+}
+
+func stsTokenFunc(provider credentials.Provider) func() (string, string, string, time.Time, error) {
+	return func() (string, string, string, time.Time, error) {
+		c, err := provider.Credentials()
+		if err != nil {
+			return "", "", "", time.Time{}, err
+		}
+		expiration := time.Now().Add(stsRefreshInterval)
+		if !c.Expiration.IsZero() && c.Expiration.Before(expiration) {
+			expiration = c.Expiration
+		}
+		return c.AccessKeyID, c.AccessKeySecret, c.SecurityToken, expiration, nil
+	}
+}
+
+func newSlsClient(configure *configure.Configuration) (*slsSdk.Client, error) {
+	client := &slsSdk.Client{Endpoint: configure.Endpoint}
+	if err := refreshSlsClient(client, configure.CredentialsProvider()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func refreshSlsClient(client *slsSdk.Client, provider credentials.Provider) error {
+	c, err := provider.Credentials()
+	if err != nil {
+		return err
+	}
+	client.ResetAccessKeyToken(c.AccessKeyID, c.AccessKeySecret, c.SecurityToken)
+	return nil
+}
+
+// otelCredentials attaches the current credentials to every gRPC request.
+type otelCredentials struct {
+	provider credentials.Provider
+}
+
+func (o *otelCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	c, err := o.provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{
+		"x-sls-otel-ak-id":     c.AccessKeyID,
+		"x-sls-otel-ak-secret": c.AccessKeySecret,
+	}
+	if c.SecurityToken != "" {
+		metadata["x-acs-security-token"] = c.SecurityToken
+	}
+	return metadata, nil
+}
+
+func (o *otelCredentials) RequireTransportSecurity() bool {
+	return true
+}
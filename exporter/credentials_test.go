@@ -0,0 +1,29 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+)
+
+func TestSetProducerLogging(t *testing.T) {
+	producerConfig := producer.GetDefaultProducerConfig()
+	setProducerLogging(producerConfig, &configure.Configuration{LogLevel: "warn", LogFormat: "json"})
+	if producerConfig.LogFileName != "" || !producerConfig.IsJsonType || producerConfig.AllowLogLevel != "warn" {
+		t.Errorf("Expected json logs to stdout, Actual: %+v", producerConfig)
+	}
+
+	producerConfig = producer.GetDefaultProducerConfig()
+	setProducerLogging(producerConfig, &configure.Configuration{LogFormat: "json", LogFile: "/var/log/ingester.log", LogMaxSize: 100, LogMaxBackups: 5})
+	if producerConfig.LogFileName != "/var/log/ingester-producer.log" {
+		t.Errorf("Expected the producer to log next to the ingester, Actual: %s", producerConfig.LogFileName)
+	}
+	if producerConfig.LogMaxSize != 100 || producerConfig.LogMaxBackups != 5 {
+		t.Errorf("Expected the rotation of the ingester, Actual: %d MB, %d backups", producerConfig.LogMaxSize, producerConfig.LogMaxBackups)
+	}
+	// the producer inverts the format of its log file
+	if producerConfig.IsJsonType {
+		t.Error("Expected IsJsonType to be unset for json logs to a file")
+	}
+}
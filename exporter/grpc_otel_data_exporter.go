@@ -2,20 +2,27 @@ package exporter
 
 import (
 	"context"
+	"time"
+
 	"github.com/aliyun-sls/zipkin-ingester/configure"
 	"github.com/aliyun-sls/zipkin-ingester/converter"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
 type grpcOtelDataExporter struct {
-	client otlptrace.Client
+	client          otlptrace.Client
+	shutdownTimeout time.Duration
 }
 
 func (g *grpcOtelDataExporter) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout)
+	defer cancel()
+	_ = g.client.Stop(ctx)
 }
 
 func (g *grpcOtelDataExporter) SendZipkinData(converter converter.Converter, data []byte) error {
@@ -26,25 +33,29 @@ func (g *grpcOtelDataExporter) SendZipkinData(converter converter.Converter, dat
 	}
 }
 
-func NewGrpcOtelDataExporter(configure *configure.Configuration) (ZipkinDataExporter, error) {
+// NewSlsTraceClient creates an unstarted OTLP client writing to the trace instance of the configuration.
+func NewSlsTraceClient(configure *configure.Configuration) otlptrace.Client {
 	headers := make(map[string]string)
 	headers["x-sls-otel-project"] = configure.Project
 	headers["x-sls-otel-instance-id"] = configure.Instance
-	headers["x-sls-otel-ak-id"] = configure.AccessKey
-	headers["x-sls-otel-ak-secret"] = configure.AccessSecret
 
-	client := otlptracegrpc.NewClient(
+	return otlptracegrpc.NewClient(
 		otlptracegrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")),
 		otlptracegrpc.WithEndpoint(configure.Endpoint),
 		otlptracegrpc.WithHeaders(headers),
+		otlptracegrpc.WithDialOption(grpc.WithPerRPCCredentials(&otelCredentials{provider: configure.CredentialsProvider()})),
 	)
+}
 
+func NewGrpcOtelDataExporter(configure *configure.Configuration) (ZipkinDataExporter, error) {
+	client := NewSlsTraceClient(configure)
 	if err := client.Start(context.Background()); err != nil {
 		return nil, err
 	}
 
 	return &grpcOtelDataExporter{
-		client: client,
+		client:          client,
+		shutdownTimeout: shutdownTimeout(configure),
 	}, nil
 }
 
@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"go.uber.org/zap"
+)
+
+const indexConfigNotExist = "IndexConfigNotExist"
+
+// ProvisionTraceLogstore creates the trace logstore of the configuration and its
+// index if they do not exist yet. The project must exist.
+func ProvisionTraceLogstore(configure *configure.Configuration, sugar *zap.SugaredLogger) error {
+	return provisionLogstore(configure, configure.TraceLogstore(), TraceIndex(), sugar)
+}
+
+// ProvisionTraceSummaryLogstore creates the trace summary logstore and its index if they do not exist yet.
+func ProvisionTraceSummaryLogstore(configure *configure.Configuration, sugar *zap.SugaredLogger) error {
+	return provisionLogstore(configure, configure.TraceSummaryLogstore(), TraceSummaryIndex(), sugar)
+}
+
+func provisionLogstore(configure *configure.Configuration, logstore string, index slsSdk.Index, sugar *zap.SugaredLogger) error {
+	client, err := newSlsClient(configure)
+	if err != nil {
+		return fmt.Errorf("failed to get the credentials: %w", err)
+	}
+	defer client.Close()
+
+	project := configure.Project
+	if exist, err := client.CheckProjectExist(project); err != nil {
+		return provisionError("check project", project, logstore, err)
+	} else if !exist {
+		return fmt.Errorf("the project %s does not exist", project)
+	}
+
+	if exist, err := client.CheckLogstoreExist(project, logstore); err != nil {
+		return provisionError("check logstore", project, logstore, err)
+	} else if !exist {
+		ttl, shards := configure.LogstoreTTL, configure.LogstoreShards
+		if ttl <= 0 {
+			ttl = 30
+		}
+		if shards <= 0 {
+			shards = 2
+		}
+		if err := client.CreateLogStore(project, logstore, ttl, shards, true, 64); err != nil && !isSlsError(err, slsSdk.LOGSTORE_ALREADY_EXIST) {
+			return provisionError("create logstore", project, logstore, err)
+		}
+		sugar.Infow("Created the logstore", "project", project, "logstore", logstore, "ttl", ttl, "shards", shards)
+	}
+
+	if _, err := client.GetIndex(project, logstore); err == nil {
+		return nil
+	} else if !isSlsError(err, indexConfigNotExist) {
+		return provisionError("get index", project, logstore, err)
+	}
+	if err := client.CreateIndex(project, logstore, index); err != nil {
+		return provisionError("create index", project, logstore, err)
+	}
+	sugar.Infow("Created the index", "project", project, "logstore", logstore)
+	return nil
+}
+
+// TraceIndex is the field index of the keys written by converter.ToSLSSpan.
+func TraceIndex() slsSdk.Index {
+	text := func() slsSdk.IndexKey {
+		return slsSdk.IndexKey{Token: indexTokens, Type: "text", DocValue: true}
+	}
+	long := func() slsSdk.IndexKey {
+		return slsSdk.IndexKey{Type: "long", DocValue: true}
+	}
+	json := func() slsSdk.IndexKey {
+		return slsSdk.IndexKey{Token: indexTokens, Type: "json", DocValue: true}
+	}
+
+	return slsSdk.Index{
+		Keys: map[string]slsSdk.IndexKey{
+			converter.TraceID:       text(),
+			converter.SpanID:        text(),
+			converter.ParentSpanID:  text(),
+			converter.ServiceName:   text(),
+			converter.OperationName: text(),
+			converter.SpanKind:      text(),
+			converter.StatusCode:    text(),
+			converter.StatusMessage: text(),
+			converter.StartTime:     long(),
+			converter.EndTime:       long(),
+			converter.Duration:      long(),
+			converter.Attribute:     json(),
+			converter.Resource:      json(),
+			converter.Logs:          text(),
+			converter.Links:         text(),
+		},
+	}
+}
+
+// TraceSummaryIndex is the field index of the keys written by SummaryToLog.
+func TraceSummaryIndex() slsSdk.Index {
+	text := func() slsSdk.IndexKey {
+		return slsSdk.IndexKey{Token: indexTokens, Type: "text", DocValue: true}
+	}
+	long := func() slsSdk.IndexKey {
+		return slsSdk.IndexKey{Type: "long", DocValue: true}
+	}
+
+	return slsSdk.Index{
+		Keys: map[string]slsSdk.IndexKey{
+			SummaryTraceID:       text(),
+			SummaryRootService:   text(),
+			SummaryRootOperation: text(),
+			SummaryServices:      text(),
+			SummaryError:         text(),
+			SummaryStart:         long(),
+			SummaryEnd:           long(),
+			SummaryDuration:      long(),
+			SummarySpanCount:     long(),
+			SummaryServiceCount:  long(),
+			SummaryOrphanCount:   long(),
+		},
+	}
+}
+
+var indexTokens = []string{" ", "\n", "\t", "\r", ",", ";", "[", "]", "{", "}", "(", ")", "&", "^", "*", "#", "@", "~", "=", "<", ">", "/", "\\", "?", ":", "'", "\""}
+
+func isSlsError(err error, code string) bool {
+	var slsErr *slsSdk.Error
+	return errors.As(err, &slsErr) && slsErr.Code == code
+}
+
+func provisionError(action, project, logstore string, err error) error {
+	var slsErr *slsSdk.Error
+	if errors.As(err, &slsErr) && (slsErr.HTTPCode == http.StatusUnauthorized || slsErr.HTTPCode == http.StatusForbidden) {
+		return fmt.Errorf("insufficient permissions to %s %s/%s, the access key needs log:GetProject, log:GetLogStore, "+
+			"log:CreateLogStore, log:GetIndex and log:CreateIndex: %s %s", action, project, logstore, slsErr.Code, slsErr.Message)
+	}
+	return fmt.Errorf("failed to %s %s/%s: %w", action, project, logstore, err)
+}
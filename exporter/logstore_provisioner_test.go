@@ -0,0 +1,186 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"go.uber.org/zap"
+)
+
+// fakeLogstoreAPI serves the project, logstore and index requests of the provisioner.
+type fakeLogstoreAPI struct {
+	server *httptest.Server
+	// forbidden answers every request with a 403, as for an access key without permissions.
+	forbidden bool
+
+	mu        sync.Mutex
+	projects  map[string]bool
+	logstores map[string]map[string]interface{}
+	indexes   map[string]map[string]interface{}
+	requests  []string
+}
+
+func newFakeLogstoreAPI(projects ...string) *fakeLogstoreAPI {
+	f := &fakeLogstoreAPI{
+		projects:  make(map[string]bool),
+		logstores: make(map[string]map[string]interface{}),
+		indexes:   make(map[string]map[string]interface{}),
+	}
+	for _, project := range projects {
+		f.projects[project] = true
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeLogstoreAPI) endpoint() string {
+	return strings.TrimPrefix(f.server.URL, "http://")
+}
+
+func (f *fakeLogstoreAPI) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"errorCode": code, "errorMessage": code})
+	}
+	if f.forbidden {
+		fail(http.StatusForbidden, "Unauthorized")
+		return
+	}
+	project := strings.TrimSuffix(r.Host, "."+f.endpoint())
+	if !f.projects[project] {
+		fail(http.StatusNotFound, "ProjectNotExist")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	var content map[string]interface{}
+	_ = json.Unmarshal(body, &content)
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"projectName":"` + project + `"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/logstores":
+		f.logstores[content["logstoreName"].(string)] = content
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		if _, ok := f.logstores[parts[1]]; !ok {
+			fail(http.StatusNotFound, "LogStoreNotExist")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.logstores[parts[1]])
+	case len(parts) == 3 && parts[2] == "index" && r.Method == http.MethodGet:
+		if _, ok := f.indexes[parts[1]]; !ok {
+			fail(http.StatusNotFound, indexConfigNotExist)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.indexes[parts[1]])
+	case len(parts) == 3 && parts[2] == "index" && r.Method == http.MethodPost:
+		f.indexes[parts[1]] = content
+	default:
+		fail(http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeLogstoreAPI) config() *configure.Configuration {
+	return &configure.Configuration{
+		Project:      "project",
+		Instance:     "instance",
+		Endpoint:     f.endpoint(),
+		AccessKey:    "test-id",
+		AccessSecret: "test-secret",
+	}
+}
+
+func TestProvisionCreatesTheMissingLogstoreAndIndex(t *testing.T) {
+	api := newFakeLogstoreAPI("project")
+	defer api.server.Close()
+
+	if err := ProvisionTraceLogstore(api.config(), zap.NewNop().Sugar()); err != nil {
+		t.Fatalf("Failed to provision the logstore. %v", err)
+	}
+	expected := []string{
+		"GET /",
+		"GET /logstores/instance-traces",
+		"POST /logstores",
+		"GET /logstores/instance-traces/index",
+		"POST /logstores/instance-traces/index",
+	}
+	if !reflect.DeepEqual(api.requests, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, api.requests)
+	}
+	logstore := api.logstores["instance-traces"]
+	if logstore["ttl"] != 30.0 || logstore["shardCount"] != 2.0 {
+		t.Errorf("Expected the default ttl and shards, Actual: %v", logstore)
+	}
+	keys, _ := api.indexes["instance-traces"]["keys"].(map[string]interface{})
+	if _, ok := keys[converter.TraceID]; !ok || len(keys) != len(TraceIndex().Keys) {
+		t.Errorf("Expected the trace index, Actual: %v", api.indexes["instance-traces"])
+	}
+}
+
+func TestProvisionCreatesTheMissingIndex(t *testing.T) {
+	api := newFakeLogstoreAPI("project")
+	defer api.server.Close()
+	api.logstores["instance-trace-summaries"] = map[string]interface{}{"logstoreName": "instance-trace-summaries"}
+
+	if err := ProvisionTraceSummaryLogstore(api.config(), zap.NewNop().Sugar()); err != nil {
+		t.Fatalf("Failed to provision the logstore. %v", err)
+	}
+	expected := []string{
+		"GET /",
+		"GET /logstores/instance-trace-summaries",
+		"GET /logstores/instance-trace-summaries/index",
+		"POST /logstores/instance-trace-summaries/index",
+	}
+	if !reflect.DeepEqual(api.requests, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, api.requests)
+	}
+	if _, ok := api.indexes["instance-trace-summaries"]; !ok {
+		t.Error("Expected the summary index to be created")
+	}
+}
+
+func TestProvisionKeepsTheExistingIndex(t *testing.T) {
+	api := newFakeLogstoreAPI("project")
+	defer api.server.Close()
+	api.logstores["instance-traces"] = map[string]interface{}{"logstoreName": "instance-traces"}
+	custom := map[string]interface{}{"keys": map[string]interface{}{"custom": map[string]interface{}{"type": "text"}}}
+	api.indexes["instance-traces"] = custom
+
+	if err := ProvisionTraceLogstore(api.config(), zap.NewNop().Sugar()); err != nil {
+		t.Fatalf("Failed to provision the logstore. %v", err)
+	}
+	for _, request := range api.requests {
+		if strings.HasPrefix(request, http.MethodPost) {
+			t.Errorf("Expected nothing to be created, Actual: %s", request)
+		}
+	}
+	if !reflect.DeepEqual(api.indexes["instance-traces"], custom) {
+		t.Errorf("Expected the index to be left alone, Actual: %v", api.indexes["instance-traces"])
+	}
+}
+
+func TestProvisionErrors(t *testing.T) {
+	api := newFakeLogstoreAPI()
+	defer api.server.Close()
+	if err := ProvisionTraceLogstore(api.config(), zap.NewNop().Sugar()); err == nil || !strings.Contains(err.Error(), "the project project does not exist") {
+		t.Errorf("Expected the missing project to fail, Actual: %v", err)
+	}
+
+	api.forbidden = true
+	err := ProvisionTraceLogstore(api.config(), zap.NewNop().Sugar())
+	if err == nil || !strings.Contains(err.Error(), "insufficient permissions to check project project/instance-traces") ||
+		!strings.Contains(err.Error(), "log:CreateIndex") {
+		t.Errorf("Expected the missing permissions to be explained, Actual: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+	"github.com/gogo/protobuf/proto"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+)
+
+const (
+	metricNameKey   = "__name__"
+	metricLabelsKey = "__labels__"
+	metricTimeKey   = "__time_nano__"
+	metricValueKey  = "__value__"
+)
+
+// MetricStoreExporter writes metric points to a SLS MetricStore using the SLS
+// metric log format.
+type MetricStoreExporter struct {
+	project          string
+	metricStore      string
+	producerInstance *producer.Producer
+	callback         producer.CallBack
+	shutdownTimeout  time.Duration
+}
+
+func NewMetricStoreExporter(configure *configure.Configuration, sugar *zap.SugaredLogger) (metrics.Exporter, error) {
+	producerConfig, err := newProducerConfig(configure)
+	if err != nil {
+		return nil, err
+	}
+	producerInstance := producer.InitProducer(producerConfig)
+	producerInstance.Start()
+
+	metricStore := configure.SpanMetricsStore
+	if metricStore == "" {
+		metricStore = fmt.Sprintf("%s-metrics", configure.Instance)
+	}
+	return &MetricStoreExporter{
+		producerInstance: producerInstance,
+		project:          configure.Project,
+		metricStore:      metricStore,
+		callback:         &CallbackImpl{sugar: sugar},
+		shutdownTimeout:  shutdownTimeout(configure),
+	}, nil
+}
+
+func (m *MetricStoreExporter) ExportMetrics(points []*metrics.Point) error {
+	logs := make([]*slsSdk.Log, 0, len(points))
+	for _, point := range points {
+		logs = append(logs, PointToLog(point))
+	}
+	return m.producerInstance.SendLogListWithCallBack(m.project, m.metricStore, "", "", logs, m.callback)
+}
+
+func (m *MetricStoreExporter) Close() {
+	m.producerInstance.Close(m.shutdownTimeout.Milliseconds())
+}
+
+// PointToLog encodes a metric point as a log in the SLS metric log format.
+func PointToLog(point *metrics.Point) *slsSdk.Log {
+	labels := make([]string, 0, len(point.Labels))
+	for _, name := range point.SortedLabelNames() {
+		labels = append(labels, name+"#$#"+point.Labels[name])
+	}
+
+	return &slsSdk.Log{
+		Time: proto.Uint32(uint32(point.Time.Unix())),
+		Contents: []*slsSdk.LogContent{
+			{Key: proto.String(metricNameKey), Value: proto.String(point.Name)},
+			{Key: proto.String(metricLabelsKey), Value: proto.String(strings.Join(labels, "|"))},
+			{Key: proto.String(metricTimeKey), Value: proto.String(cast.ToString(point.Time.UnixNano()))},
+			{Key: proto.String(metricValueKey), Value: proto.String(cast.ToString(point.Value))},
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	ExporterSLS    = "sls"
+	ExporterZipkin = "zipkin"
+)
+
+// multiExporter sends the same spans to every exporter.
+type multiExporter []ZipkinDataExporter
+
+// NewExporters creates the exporters listed in the configuration, sending to all of them.
+func NewExporters(configure *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+	var exporters multiExporter
+	for _, name := range configure.Exporters {
+		var exporter ZipkinDataExporter
+		var err error
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case ExporterSLS:
+			exporter, err = NewSdkProducerExporter(configure, sugar)
+		case ExporterZipkin:
+			exporter, err = NewZipkinHTTPExporter(configure)
+		default:
+			err = fmt.Errorf("unknown exporter %q", name)
+		}
+		if err != nil {
+			exporters.Close()
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	switch len(exporters) {
+	case 0:
+		return NewSdkProducerExporter(configure, sugar)
+	case 1:
+		return exporters[0], nil
+	default:
+		return exporters, nil
+	}
+}
+
+func (m multiExporter) SendData(data []*zipkinmodel.SpanModel) error {
+	var err error
+	for _, exporter := range m {
+		err = multierr.Append(err, exporter.SendData(data))
+	}
+	return err
+}
+
+func (m multiExporter) SendOtelData(data []*tracepb.ResourceSpans) error {
+	var err error
+	for _, exporter := range m {
+		err = multierr.Append(err, exporter.SendOtelData(data))
+	}
+	return err
+}
+
+func (m multiExporter) SendZipkinData(converter converter.Converter, data []byte) error {
+	if spans, err := converter.ParseSpans(data, false); err == nil {
+		return m.SendData(spans)
+	} else {
+		return err
+	}
+}
+
+// Close closes the exporters concurrently so they flush within the same deadline.
+func (m multiExporter) Close() {
+	closeAll(m)
+}
+
+// Flush flushes the exporters concurrently within the timeout.
+func (m multiExporter) Flush(timeout time.Duration) error {
+	return flushAll(m, timeout)
+}
+
+// Checkpoint tells whether every exporter delivered the spans sent before it.
+func (m multiExporter) Checkpoint() func() bool {
+	return checkpointAll(m)
+}
+
+func flushAll(exporters []ZipkinDataExporter, timeout time.Duration) error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var err error
+	wg.Add(len(exporters))
+	for _, exporter := range exporters {
+		go func(exporter ZipkinDataExporter) {
+			defer wg.Done()
+			if e := Flush(exporter, timeout); e != nil {
+				lock.Lock()
+				err = multierr.Append(err, e)
+				lock.Unlock()
+			}
+		}(exporter)
+	}
+	wg.Wait()
+	return err
+}
+
+func closeAll(exporters []ZipkinDataExporter) {
+	var wg sync.WaitGroup
+	wg.Add(len(exporters))
+	for _, exporter := range exporters {
+		go func(exporter ZipkinDataExporter) {
+			defer wg.Done()
+			exporter.Close()
+		}(exporter)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,215 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRouteName = "default"
+
+	MetricRouteSpans  = "zipkin_ingester_route_spans_total"
+	MetricRouteErrors = "zipkin_ingester_route_errors_total"
+)
+
+// ExporterFactory creates the exporter of a route from its effective configuration.
+type ExporterFactory func(configure *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error)
+
+type route struct {
+	name         string
+	topics       map[string]struct{}
+	environments map[string]struct{}
+	services     map[string]struct{}
+	exporter     ZipkinDataExporter
+	spans        *metrics.Counter
+	errors       *metrics.Counter
+}
+
+// RoutingExporter dispatches spans to the first route whose conditions match the
+// kafka topic, the deployment.environment tag and the local service name of the
+// span, or to the default route built from the top level configuration.
+type RoutingExporter struct {
+	routes       []*route
+	defaultRoute *route
+}
+
+func NewRoutingExporter(config *configure.Configuration, factory ExporterFactory, sugar *zap.SugaredLogger) (*RoutingExporter, error) {
+	r := &RoutingExporter{}
+	for _, rc := range config.Routes {
+		exporter, err := factory(config.ForRoute(rc), sugar)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.routes = append(r.routes, newRoute(rc, exporter))
+	}
+
+	exporter, err := factory(config, sugar)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.defaultRoute = newRoute(configure.Route{Name: DefaultRouteName}, exporter)
+	return r, nil
+}
+
+func newRoute(rc configure.Route, exporter ZipkinDataExporter) *route {
+	name := rc.Name
+	if name == "" {
+		name = rc.Project + "/" + rc.Instance + "/" + rc.Logstore
+	}
+	labels := map[string]string{"route": name}
+	return &route{
+		name:         name,
+		topics:       toSet(rc.Topics),
+		environments: toSet(rc.Environments),
+		services:     toSet(rc.Services),
+		exporter:     exporter,
+		spans:        metrics.NewCounter(MetricRouteSpans, labels),
+		errors:       metrics.NewCounter(MetricRouteErrors, labels),
+	}
+}
+
+func (r *route) match(topic string, span *zipkinmodel.SpanModel) bool {
+	if !matchSet(r.topics, topic) {
+		return false
+	}
+	if !matchSet(r.environments, span.Tags[converter.AttributeDeploymentEnvironment]) {
+		return false
+	}
+	serviceName := ""
+	if span.LocalEndpoint != nil {
+		serviceName = span.LocalEndpoint.ServiceName
+	}
+	return matchSet(r.services, serviceName)
+}
+
+// Close closes the exporters of all routes concurrently so they flush within the same deadline.
+func (r *RoutingExporter) Close() {
+	closeAll(r.exporters())
+}
+
+// Flush waits until the exporters of all routes delivered the spans sent to them.
+func (r *RoutingExporter) Flush(timeout time.Duration) error {
+	return flushAll(r.exporters(), timeout)
+}
+
+// Checkpoint tells whether the exporters of all routes delivered the spans sent before it.
+func (r *RoutingExporter) Checkpoint() func() bool {
+	return checkpointAll(r.exporters())
+}
+
+func (r *RoutingExporter) exporters() []ZipkinDataExporter {
+	exporters := make([]ZipkinDataExporter, 0, len(r.routes)+1)
+	for _, rt := range r.routes {
+		exporters = append(exporters, rt.exporter)
+	}
+	if r.defaultRoute != nil {
+		exporters = append(exporters, r.defaultRoute.exporter)
+	}
+	return exporters
+}
+
+// SendTopicData sends the spans consumed from the topic to their routes.
+func (r *RoutingExporter) SendTopicData(topic string, data []*zipkinmodel.SpanModel) error {
+	if len(r.routes) == 0 {
+		return r.defaultRoute.send(data)
+	}
+
+	batches := make(map[*route][]*zipkinmodel.SpanModel)
+	for _, span := range data {
+		rt := r.selectRoute(topic, span)
+		batches[rt] = append(batches[rt], span)
+	}
+
+	var err error
+	for rt, spans := range batches {
+		err = multierr.Append(err, rt.send(spans))
+	}
+	return err
+}
+
+// SendRouteData sends the spans to the named route, or to the routes selected
+// by their conditions like SendTopicData if the name is empty.
+func (r *RoutingExporter) SendRouteData(name string, topic string, data []*zipkinmodel.SpanModel) error {
+	if name == "" {
+		return r.SendTopicData(topic, data)
+	}
+	if rt := r.route(name); rt != nil {
+		return rt.send(data)
+	}
+	return fmt.Errorf("unknown route %q", name)
+}
+
+func (r *RoutingExporter) route(name string) *route {
+	if name == DefaultRouteName {
+		return r.defaultRoute
+	}
+	for _, rt := range r.routes {
+		if rt.name == name {
+			return rt
+		}
+	}
+	return nil
+}
+
+func (r *RoutingExporter) selectRoute(topic string, span *zipkinmodel.SpanModel) *route {
+	for _, rt := range r.routes {
+		if rt.match(topic, span) {
+			return rt
+		}
+	}
+	return r.defaultRoute
+}
+
+func (rt *route) send(data []*zipkinmodel.SpanModel) error {
+	rt.spans.Add(uint64(len(data)))
+	if err := rt.exporter.SendData(data); err != nil {
+		rt.errors.Add(uint64(len(data)))
+		return err
+	}
+	return nil
+}
+
+func (r *RoutingExporter) SendData(data []*zipkinmodel.SpanModel) error {
+	return r.SendTopicData("", data)
+}
+
+func (r *RoutingExporter) SendOtelData(data []*tracepb.ResourceSpans) error {
+	return r.defaultRoute.exporter.SendOtelData(data)
+}
+
+func (r *RoutingExporter) SendZipkinData(converter converter.Converter, data []byte) error {
+	if spans, err := converter.ParseSpans(data, false); err == nil {
+		return r.SendData(spans)
+	} else {
+		return err
+	}
+}
+
+func toSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		set[value] = struct{}{}
+	}
+	return set
+}
+
+func matchSet(set map[string]struct{}, value string) bool {
+	if set == nil {
+		return true
+	}
+	_, ok := set[value]
+	return ok
+}
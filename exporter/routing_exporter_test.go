@@ -0,0 +1,212 @@
+package exporter
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+type fakeExporter struct {
+	config  *configure.Configuration
+	spans   []*zipkinmodel.SpanModel
+	onClose func()
+}
+
+func (f *fakeExporter) SendData(data []*zipkinmodel.SpanModel) error {
+	f.spans = append(f.spans, data...)
+	return nil
+}
+
+func (f *fakeExporter) SendOtelData(data []*tracepb.ResourceSpans) error {
+	return nil
+}
+
+func (f *fakeExporter) SendZipkinData(converter converter.Converter, data []byte) error {
+	return nil
+}
+
+func (f *fakeExporter) Close() {
+	if f.onClose != nil {
+		f.onClose()
+	}
+}
+
+func TestRoutingExporter(t *testing.T) {
+	exporters := make(map[string]*fakeExporter)
+	factory := func(config *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+		f := &fakeExporter{config: config}
+		exporters[config.Project+"/"+config.TraceLogstore()] = f
+		return f, nil
+	}
+
+	config := &configure.Configuration{
+		Project:  "default-project",
+		Instance: "default",
+		Routes: []configure.Route{
+			{Name: "prod", Environments: []string{"prod"}, Project: "prod-project"},
+			{Name: "orders", Topics: []string{"orders"}, Services: []string{"order"}, Logstore: "orders"},
+		},
+	}
+	r, err := NewRoutingExporter(config, factory, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Failed to create routing exporter. %v", err)
+	}
+
+	order := &zipkinmodel.Endpoint{ServiceName: "order"}
+	spans := []*zipkinmodel.SpanModel{
+		{Name: "prod", LocalEndpoint: order, Tags: map[string]string{"deployment.environment": "prod"}},
+		{Name: "orders", LocalEndpoint: order},
+		{Name: "other", LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "user"}},
+	}
+	if err := r.SendTopicData("orders", spans); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	if err := r.SendTopicData("users", spans[1:2]); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+
+	expected := map[string][]string{
+		"prod-project/default-traces":    {"prod"},
+		"default-project/orders":         {"orders"},
+		"default-project/default-traces": {"other", "orders"},
+	}
+	for key, names := range expected {
+		f, ok := exporters[key]
+		if !ok {
+			t.Fatalf("Route %s: not created", key)
+		}
+		if len(f.spans) != len(names) {
+			t.Fatalf("Route %s: Expected %v spans, Actual: %d", key, names, len(f.spans))
+		}
+		for i, name := range names {
+			if f.spans[i].Name != name {
+				t.Errorf("Route %s: Expected %s, Actual: %s", key, name, f.spans[i].Name)
+			}
+		}
+	}
+}
+
+func TestRoutingExporterSendRouteData(t *testing.T) {
+	exporters := make(map[string]*fakeExporter)
+	factory := func(config *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+		f := &fakeExporter{config: config}
+		exporters[config.TraceLogstore()] = f
+		return f, nil
+	}
+
+	config := &configure.Configuration{
+		Instance: "default",
+		Routes:   []configure.Route{{Name: "orders", Topics: []string{"orders"}, Logstore: "orders"}},
+	}
+	r, err := NewRoutingExporter(config, factory, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Failed to create routing exporter. %v", err)
+	}
+
+	span := []*zipkinmodel.SpanModel{{Name: "span"}}
+	if err := r.SendRouteData("orders", "users", span); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	if err := r.SendRouteData(DefaultRouteName, "orders", span); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	if err := r.SendRouteData("", "orders", span); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	if err := r.SendRouteData("unknown", "orders", span); err == nil {
+		t.Error("Expected an error for an unknown route")
+	}
+
+	if n := len(exporters["orders"].spans); n != 2 {
+		t.Errorf("Route orders: Expected 2 spans, Actual: %d", n)
+	}
+	if n := len(exporters["default-traces"].spans); n != 1 {
+		t.Errorf("Route default: Expected 1 span, Actual: %d", n)
+	}
+}
+
+func TestRoutingExporterClosesConcurrently(t *testing.T) {
+	// every exporter waits until all of them are closing, which only completes if they close concurrently
+	var closing sync.WaitGroup
+	closing.Add(3)
+	factory := func(config *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+		return &fakeExporter{config: config, onClose: func() {
+			closing.Done()
+			closing.Wait()
+		}}, nil
+	}
+
+	config := &configure.Configuration{
+		Project: "default-project",
+		Routes:  []configure.Route{{Name: "a", Topics: []string{"a"}}, {Name: "b", Topics: []string{"b"}}},
+	}
+	r, err := NewRoutingExporter(config, factory, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Failed to create routing exporter. %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The exporters are not closed concurrently")
+	}
+}
+
+type flushingExporter struct {
+	fakeExporter
+	flushed bool
+	err     error
+}
+
+func (f *flushingExporter) Flush(timeout time.Duration) error {
+	f.flushed = true
+	return f.err
+}
+
+func TestRoutingExporterFlush(t *testing.T) {
+	var flushers []*flushingExporter
+	factory := func(config *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+		if config.Project == "sync-project" {
+			return &fakeExporter{config: config}, nil
+		}
+		f := &flushingExporter{fakeExporter: fakeExporter{config: config}}
+		if config.Project == "failing-project" {
+			f.err = errors.New("still queued")
+		}
+		flushers = append(flushers, f)
+		return f, nil
+	}
+
+	config := &configure.Configuration{
+		Project: "default-project",
+		Routes: []configure.Route{
+			{Name: "sync", Topics: []string{"a"}, Project: "sync-project"},
+			{Name: "failing", Topics: []string{"b"}, Project: "failing-project"},
+		},
+	}
+	r, err := NewRoutingExporter(config, factory, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Failed to create routing exporter. %v", err)
+	}
+
+	if err := r.Flush(time.Second); err == nil {
+		t.Error("expected the error of the failing route")
+	}
+	for _, f := range flushers {
+		if !f.flushed {
+			t.Errorf("the exporter of %s is not flushed", f.config.Project)
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package exporter
 
 import (
-	"fmt"
 	"github.com/aliyun-sls/zipkin-ingester/configure"
 	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/credentials"
 	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
@@ -11,6 +11,7 @@ import (
 
 type SdkDataExporter struct {
 	client   *slsSdk.Client
+	provider credentials.Provider
 	project  string
 	traceLog string
 }
@@ -20,20 +21,23 @@ func (s *SdkDataExporter) Close() {
 }
 
 func NewSdkDataExporter(configure *configure.Configuration) (ZipkinDataExporter, error) {
+	client, err := newSlsClient(configure)
+	if err != nil {
+		return nil, err
+	}
 	return &SdkDataExporter{
-		client: &slsSdk.Client{
-			Endpoint:        configure.Endpoint,
-			AccessKeyID:     configure.AccessKey,
-			AccessKeySecret: configure.AccessSecret,
-		},
+		client:   client,
+		provider: configure.CredentialsProvider(),
 		project:  configure.Project,
-		traceLog: fmt.Sprintf("%s-traces", configure.Instance),
+		traceLog: configure.TraceLogstore(),
 	}, nil
 }
 
 func (s SdkDataExporter) SendData(data []*zipkinmodel.SpanModel) error {
 	if lg, err := converter.ToSLSSpans(data); err != nil {
 		return err
+	} else if err := refreshSlsClient(s.client, s.provider); err != nil {
+		return err
 	} else {
 		return s.client.PutLogs(s.project, s.traceLog, lg)
 	}
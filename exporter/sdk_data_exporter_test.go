@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/slstest"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"go.uber.org/zap"
+)
+
+const testZipkinSpan = `[{"traceId":"5af7183fb1d4cf5f","parentId":"6b221d5bc9e6496c","id":"352bff9a74ca9ad2","kind":"CLIENT",
+	"name":"get /api","timestamp":1659409534000000,"duration":207000,
+	"localEndpoint":{"serviceName":"frontend","ipv4":"192.168.99.1","port":8080},
+	"remoteEndpoint":{"serviceName":"backend","ipv4":"172.19.0.2","port":9000},
+	"annotations":[{"timestamp":1659409534100000,"value":"ws"}],
+	"tags":{"http.method":"GET","http.path":"/api","http.status_code":"500","error":"boom"}}]`
+
+var testSpanLog = map[string]string{
+	"attribute":     `{"http.request.method":"GET","http.response.status_code":"500","net.host.ip":"192.168.99.1","net.host.port":8080,"net.peer.ip":"172.19.0.2","net.peer.port":9000,"peer.service":"backend","url.path":"/api"}`,
+	"duration":      "207000",
+	"end":           "1659409534207000",
+	"kind":          "client",
+	"links":         "[]",
+	"logs":          `[{"attribute":{"Name":"ws"},"time":1659409534100000000}]`,
+	"name":          "get /api",
+	"parentSpanID":  "6b221d5bc9e6496c",
+	"resource":      `{"service.name":"frontend"}`,
+	"service":       "frontend",
+	"spanID":        "352bff9a74ca9ad2",
+	"start":         "1659409534000000",
+	"statusCode":    "ERROR",
+	"statusMessage": "boom",
+	"traceID":       "5af7183fb1d4cf5f",
+}
+
+func newTestServer() (*slstest.Server, *configure.Configuration) {
+	server := slstest.NewServer(slstest.Options{AccessKeys: map[string]string{"test-id": "test-secret"}})
+	return server, &configure.Configuration{
+		Project:      "project",
+		Instance:     "instance",
+		Endpoint:     server.Endpoint,
+		AccessKey:    "test-id",
+		AccessSecret: "test-secret",
+		LogLevel:     "error",
+	}
+}
+
+func assertSpanLogs(t *testing.T, logs []*slsSdk.Log) {
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, Actual: %d", len(logs))
+	}
+	if logs[0].GetTime() != 1659409534 {
+		t.Errorf("Expected the time of the span, Actual: %d", logs[0].GetTime())
+	}
+	if contents := slstest.Contents(logs[0]); !reflect.DeepEqual(contents, testSpanLog) {
+		t.Errorf("Expected %v, Actual: %v", testSpanLog, contents)
+	}
+}
+
+func TestSdkDataExporter(t *testing.T) {
+	server, config := newTestServer()
+	defer server.Close()
+	exporter, err := NewSdkDataExporter(config)
+	if err != nil {
+		t.Fatalf("Failed to create exporter. %v", err)
+	}
+	defer exporter.Close()
+
+	if err := exporter.SendZipkinData(converter.NewConverter("json"), []byte(testZipkinSpan)); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	assertSpanLogs(t, server.Logs("project", "instance-traces"))
+
+	config.AccessSecret = "wrong-secret"
+	rejected, _ := NewSdkDataExporter(config)
+	defer rejected.Close()
+	err = rejected.SendZipkinData(converter.NewConverter("json"), []byte(testZipkinSpan))
+	if slsErr, ok := err.(*slsSdk.Error); !ok || slsErr.HTTPCode != http.StatusUnauthorized {
+		t.Errorf("Expected an unauthorized error, Actual: %v", err)
+	}
+}
+
+func TestSdkProducerExporter(t *testing.T) {
+	server, config := newTestServer()
+	defer server.Close()
+	exporter, err := NewSdkProducerExporter(config, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("Failed to create exporter. %v", err)
+	}
+
+	flushed := SpanDeliveryStats().Flushed
+	if err := exporter.SendZipkinData(converter.NewConverter("json"), []byte(testZipkinSpan)); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	delivered := Checkpoint(exporter)
+	if err := Flush(exporter, 10*time.Second); err != nil {
+		t.Fatalf("Failed to flush the producer. %v", err)
+	}
+	if !delivered() {
+		t.Error("Expected the span to be delivered once flushed")
+	}
+	exporter.Close()
+	assertSpanLogs(t, server.Logs("project", "instance-traces"))
+	if stats := SpanDeliveryStats(); stats.Flushed != flushed+1 {
+		t.Errorf("Expected 1 more flushed span, Actual: %d", stats.Flushed-flushed)
+	}
+}
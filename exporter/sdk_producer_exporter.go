@@ -2,51 +2,215 @@ package exporter
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/aliyun-sls/zipkin-ingester/configure"
 	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
 	"github.com/aliyun/aliyun-log-go-sdk/producer"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+const (
+	MetricSpansFlushedTotal   = "zipkin_ingester_spans_flushed_total"
+	MetricSpansFailedTotal    = "zipkin_ingester_spans_failed_total"
+	MetricSpansAbandonedTotal = "zipkin_ingester_spans_abandoned_total"
+
+	// DefaultShutdownTimeout is used when the configuration has no shutdown timeout.
+	DefaultShutdownTimeout = 30 * time.Second
+
+	flushPollInterval = 10 * time.Millisecond
+)
+
+var (
+	spansFlushed   = metrics.NewCounter(MetricSpansFlushedTotal, map[string]string{"exporter": ExporterSLS})
+	spansFailed    = metrics.NewCounter(MetricSpansFailedTotal, map[string]string{"exporter": ExporterSLS})
+	spansAbandoned = metrics.NewCounter(MetricSpansAbandonedTotal, map[string]string{"exporter": ExporterSLS})
 )
 
+// DeliveryStats is the outcome of the spans handed to the SLS producers.
+type DeliveryStats struct {
+	Flushed   uint64
+	Failed    uint64
+	Abandoned uint64
+}
+
+// SpanDeliveryStats returns the delivery outcome of all SLS producers so far.
+func SpanDeliveryStats() DeliveryStats {
+	return DeliveryStats{
+		Flushed:   spansFlushed.Value(),
+		Failed:    spansFailed.Value(),
+		Abandoned: spansAbandoned.Value(),
+	}
+}
+
 type SdkProducerExporter struct {
 	project          string
 	traceLog         string
 	producerInstance *producer.Producer
-	callback         producer.CallBack
+	callback         *spanCallback
+	shutdownTimeout  time.Duration
+	sugar            *zap.SugaredLogger
 }
 
 type CallbackImpl struct {
+	sugar *zap.SugaredLogger
 }
 
 func (c CallbackImpl) Success(result *producer.Result) {
 }
 
 func (c CallbackImpl) Fail(result *producer.Result) {
-	fmt.Printf("SendTraceFailed : %s, %s, %s, %v", result.GetErrorCode(), result.GetRequestId(), result.GetErrorMessage(), result.GetTimeStampMs())
+	c.sugar.Warnw("Failed to send logs to sls",
+		"errorCode", result.GetErrorCode(),
+		"requestId", result.GetRequestId(),
+		"errorMessage", result.GetErrorMessage(),
+		"timestampMs", result.GetTimeStampMs(),
+	)
+}
+
+// spanCallback keeps track of the spans queued in the producer, the producer
+// calls it back once per span.
+type spanCallback struct {
+	CallbackImpl
+	pending int64
+
+	// lock guards the spans still queued by batch, batches are numbered in the order they are sent.
+	lock   sync.Mutex
+	sent   uint64
+	queued map[uint64]int
+}
+
+func (s *spanCallback) Success(result *producer.Result) {
+	atomic.AddInt64(&s.pending, -1)
+	spansFlushed.Inc()
+}
+
+func (s *spanCallback) Fail(result *producer.Result) {
+	atomic.AddInt64(&s.pending, -1)
+	spansFailed.Inc()
+	s.CallbackImpl.Fail(result)
+}
+
+// send numbers a batch of spans about to be queued.
+func (s *spanCallback) send(spans int) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sent++
+	s.queued[s.sent] = spans
+	return s.sent
+}
+
+// done counts spans of the batch which were called back or not queued at all.
+func (s *spanCallback) done(batch uint64, spans int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.queued[batch] -= spans; s.queued[batch] <= 0 {
+		delete(s.queued, batch)
+	}
+}
+
+// delivered tells whether all the batches up to the last one are called back.
+func (s *spanCallback) delivered(last uint64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for batch := range s.queued {
+		if batch <= last {
+			return false
+		}
+	}
+	return true
+}
+
+// abandon forgets the spans still queued once the producer is closed.
+func (s *spanCallback) abandon() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.queued = make(map[uint64]int)
+}
+
+// batchCallback counts the spans of a batch down as the producer calls them back.
+type batchCallback struct {
+	*spanCallback
+	batch uint64
+}
+
+func (b *batchCallback) Success(result *producer.Result) {
+	b.spanCallback.Success(result)
+	b.done(b.batch, 1)
+}
+
+func (b *batchCallback) Fail(result *producer.Result) {
+	b.spanCallback.Fail(result)
+	b.done(b.batch, 1)
 }
 
+// Close flushes the queued spans until the shutdown timeout, the spans still
+// queued afterwards are counted as abandoned.
 func (s *SdkProducerExporter) Close() {
-	s.producerInstance.Close(60 * 1000)
+	if err := s.producerInstance.Close(s.shutdownTimeout.Milliseconds()); err != nil {
+		if pending := atomic.LoadInt64(&s.callback.pending); pending > 0 {
+			spansAbandoned.Add(uint64(pending))
+		}
+	}
+	s.callback.abandon()
+}
+
+// Flush waits until the producer called back for every queued span. The producer
+// keeps no per partition state, so all the queued spans are waited for.
+func (s *SdkProducerExporter) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := atomic.LoadInt64(&s.callback.pending)
+		if pending <= 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d spans are still queued after %s", pending, timeout)
+		}
+		time.Sleep(flushPollInterval)
+	}
 }
 
-func NewSdkProducerExporter(configure *configure.Configuration) (ZipkinDataExporter, error) {
-	producerConfig := producer.GetDefaultProducerConfig()
-	producerConfig.Endpoint = configure.Endpoint
-	producerConfig.AccessKeyID = configure.AccessKey
-	producerConfig.AccessKeySecret = configure.AccessSecret
+// Checkpoint tells, without waiting, whether the spans sent before it were called back.
+func (s *SdkProducerExporter) Checkpoint() func() bool {
+	s.callback.lock.Lock()
+	last := s.callback.sent
+	s.callback.lock.Unlock()
+	return func() bool {
+		return s.callback.delivered(last)
+	}
+}
+
+func NewSdkProducerExporter(configure *configure.Configuration, sugar *zap.SugaredLogger) (ZipkinDataExporter, error) {
+	producerConfig, err := newProducerConfig(configure)
+	if err != nil {
+		return nil, err
+	}
 	producerInstance := producer.InitProducer(producerConfig)
 	producerInstance.Start()
 	return &SdkProducerExporter{
 		producerInstance: producerInstance,
 		project:          configure.Project,
-		traceLog:         fmt.Sprintf("%s-traces", configure.Instance),
-		callback:         &CallbackImpl{},
+		traceLog:         configure.TraceLogstore(),
+		callback:         &spanCallback{CallbackImpl: CallbackImpl{sugar: sugar}, queued: make(map[uint64]int)},
+		sugar:            sugar,
+		shutdownTimeout:  shutdownTimeout(configure),
 	}, nil
 }
 
 func (s SdkProducerExporter) SendData(data []*zipkinmodel.SpanModel) error {
-	return converter.SendToSls(data, s.producerInstance, s.callback, s.project, s.traceLog)
+	atomic.AddInt64(&s.callback.pending, int64(len(data)))
+	batch := s.callback.send(len(data))
+	queued, err := converter.SendToSls(data, s.producerInstance, &batchCallback{spanCallback: s.callback, batch: batch}, s.project, s.traceLog, s.sugar)
+	atomic.AddInt64(&s.callback.pending, int64(queued-len(data)))
+	s.callback.done(batch, len(data)-queued)
+	spansFailed.Add(uint64(len(data) - queued))
+	return err
 }
 
 func (s SdkProducerExporter) SendOtelData(data []*tracepb.ResourceSpans) error {
@@ -60,3 +224,10 @@ func (s SdkProducerExporter) SendZipkinData(converter converter.Converter, data
 		return err
 	}
 }
+
+func shutdownTimeout(configure *configure.Configuration) time.Duration {
+	if configure.ShutdownTimeout > 0 {
+		return configure.ShutdownTimeout
+	}
+	return DefaultShutdownTimeout
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// The keys of the trace summary logs, the times are in microseconds like the span logs.
+const (
+	SummaryTraceID       = "traceID"
+	SummaryRootService   = "rootService"
+	SummaryRootOperation = "rootOperation"
+	SummaryStart         = "start"
+	SummaryEnd           = "end"
+	SummaryDuration      = "duration"
+	SummarySpanCount     = "spanCount"
+	SummaryServices      = "services"
+	SummaryServiceCount  = "serviceCount"
+	SummaryError         = "hasError"
+	SummaryOrphanCount   = "orphanCount"
+)
+
+// TraceSummaryExporter writes the trace summaries to their own logstore.
+type TraceSummaryExporter struct {
+	project          string
+	logstore         string
+	producerInstance *producer.Producer
+	callback         producer.CallBack
+	shutdownTimeout  time.Duration
+}
+
+func NewTraceSummaryExporter(configure *configure.Configuration, sugar *zap.SugaredLogger) (processor.TraceSummaryExporter, error) {
+	producerConfig, err := newProducerConfig(configure)
+	if err != nil {
+		return nil, err
+	}
+	producerInstance := producer.InitProducer(producerConfig)
+	producerInstance.Start()
+
+	return &TraceSummaryExporter{
+		producerInstance: producerInstance,
+		project:          configure.Project,
+		logstore:         configure.TraceSummaryLogstore(),
+		callback:         &CallbackImpl{sugar: sugar},
+		shutdownTimeout:  shutdownTimeout(configure),
+	}, nil
+}
+
+func (t *TraceSummaryExporter) ExportTraceSummaries(summaries []*processor.TraceSummary) error {
+	logs := make([]*slsSdk.Log, 0, len(summaries))
+	for _, summary := range summaries {
+		logs = append(logs, SummaryToLog(summary))
+	}
+	return t.producerInstance.SendLogListWithCallBack(t.project, t.logstore, "0.0.0.0", "", logs, t.callback)
+}
+
+func (t *TraceSummaryExporter) Close() {
+	t.producerInstance.Close(t.shutdownTimeout.Milliseconds())
+}
+
+// SummaryToLog encodes a trace summary as a log.
+func SummaryToLog(summary *processor.TraceSummary) *slsSdk.Log {
+	content := func(key, value string) *slsSdk.LogContent {
+		return &slsSdk.LogContent{Key: proto.String(key), Value: proto.String(value)}
+	}
+	micros := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/1000, 10)
+	}
+
+	return &slsSdk.Log{
+		Time: proto.Uint32(uint32(summary.Start.Unix())),
+		Contents: []*slsSdk.LogContent{
+			content(SummaryTraceID, summary.TraceID.String()),
+			content(SummaryRootService, summary.RootService),
+			content(SummaryRootOperation, summary.RootOperation),
+			content(SummaryStart, micros(summary.Start)),
+			content(SummaryEnd, micros(summary.End)),
+			content(SummaryDuration, strconv.FormatInt(summary.Duration().Microseconds(), 10)),
+			content(SummarySpanCount, strconv.Itoa(summary.SpanCount)),
+			content(SummaryServices, strings.Join(summary.Services, ",")),
+			content(SummaryServiceCount, strconv.Itoa(len(summary.Services))),
+			content(SummaryError, strconv.FormatBool(summary.Error)),
+			content(SummaryOrphanCount, strconv.Itoa(summary.OrphanCount)),
+		},
+	}
+}
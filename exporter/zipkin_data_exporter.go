@@ -1,6 +1,8 @@
 package exporter
 
 import (
+	"time"
+
 	"github.com/aliyun-sls/zipkin-ingester/converter"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
@@ -15,3 +17,48 @@ type ZipkinDataExporter interface {
 
 	Close()
 }
+
+// Flusher is implemented by the exporters sending asynchronously, Flush waits
+// until the spans already sent to the exporter are delivered or failed.
+type Flusher interface {
+	Flush(timeout time.Duration) error
+}
+
+// Flush flushes the exporter if it sends asynchronously.
+func Flush(exporter ZipkinDataExporter, timeout time.Duration) error {
+	if flusher, ok := exporter.(Flusher); ok {
+		return flusher.Flush(timeout)
+	}
+	return nil
+}
+
+// Checkpointer is implemented by the exporters sending asynchronously, the
+// returned function tells without waiting whether the spans sent to the exporter
+// before the checkpoint are delivered or failed.
+type Checkpointer interface {
+	Checkpoint() func() bool
+}
+
+// Checkpoint returns whether the spans sent so far to the exporter are delivered,
+// they are as soon as they are sent if the exporter sends synchronously.
+func Checkpoint(exporter ZipkinDataExporter) func() bool {
+	if checkpointer, ok := exporter.(Checkpointer); ok {
+		return checkpointer.Checkpoint()
+	}
+	return func() bool { return true }
+}
+
+func checkpointAll(exporters []ZipkinDataExporter) func() bool {
+	checkpoints := make([]func() bool, 0, len(exporters))
+	for _, exporter := range exporters {
+		checkpoints = append(checkpoints, Checkpoint(exporter))
+	}
+	return func() bool {
+		for _, delivered := range checkpoints {
+			if !delivered() {
+				return false
+			}
+		}
+		return true
+	}
+}
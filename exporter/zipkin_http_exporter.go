@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"github.com/openzipkin/zipkin-go/reporter"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// ZipkinHTTPExporter posts the spans to a Zipkin compatible /api/v2/spans endpoint.
+type ZipkinHTTPExporter struct {
+	url        string
+	client     *http.Client
+	serializer reporter.SpanSerializer
+}
+
+func NewZipkinHTTPExporter(configure *configure.Configuration) (ZipkinDataExporter, error) {
+	if configure.ZipkinURL == "" {
+		return nil, fmt.Errorf("the zipkin url is empty")
+	}
+
+	var serializer reporter.SpanSerializer
+	switch strings.ToLower(configure.ZipkinEncoding) {
+	case "", "json":
+		serializer = reporter.JSONSerializer{}
+	case "proto3", "protobuf":
+		serializer = zipkin_proto3.SpanSerializer{}
+	default:
+		return nil, fmt.Errorf("unknown zipkin encoding %q", configure.ZipkinEncoding)
+	}
+
+	timeout := configure.ZipkinTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &ZipkinHTTPExporter{
+		url:        configure.ZipkinURL,
+		client:     &http.Client{Timeout: timeout},
+		serializer: serializer,
+	}, nil
+}
+
+func (z *ZipkinHTTPExporter) SendData(data []*zipkinmodel.SpanModel) error {
+	if len(data) == 0 {
+		return nil
+	}
+	body, err := z.serializer.Serialize(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, z.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", z.serializer.ContentType())
+
+	resp, err := z.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("zipkin responded %s: %s", resp.Status, msg)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (z *ZipkinHTTPExporter) SendOtelData(data []*tracepb.ResourceSpans) error {
+	if spans, err := converter.ConvertOtel2ZipkinSpans(data); err == nil {
+		return z.SendData(spans)
+	} else {
+		return err
+	}
+}
+
+func (z *ZipkinHTTPExporter) SendZipkinData(converter converter.Converter, data []byte) error {
+	if spans, err := converter.ParseSpans(data, false); err == nil {
+		return z.SendData(spans)
+	} else {
+		return err
+	}
+}
+
+func (z *ZipkinHTTPExporter) Close() {
+	z.client.CloseIdleConnections()
+}
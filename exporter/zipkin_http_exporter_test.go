@@ -0,0 +1,54 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func TestZipkinHTTPExporter(t *testing.T) {
+	var received []*zipkinmodel.SpanModel
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/spans" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	exporter, err := NewZipkinHTTPExporter(&configure.Configuration{ZipkinURL: server.URL + "/api/v2/spans"})
+	if err != nil {
+		t.Fatalf("Failed to create exporter. %v", err)
+	}
+	defer exporter.Close()
+
+	span := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: 2},
+		Name:        "get",
+		Timestamp:   time.Unix(1659409534, 0),
+		Duration:    time.Millisecond,
+	}
+	if err := exporter.SendData([]*zipkinmodel.SpanModel{span}); err != nil {
+		t.Fatalf("Failed to send spans. %v", err)
+	}
+	if len(received) != 1 || received[0].ID != span.ID || received[0].Name != span.Name {
+		t.Errorf("Expected %v, Actual: %v", span, received)
+	}
+
+	failing, _ := NewZipkinHTTPExporter(&configure.Configuration{ZipkinURL: server.URL + "/unknown"})
+	if err := failing.SendData([]*zipkinmodel.SpanModel{span}); err == nil {
+		t.Errorf("Expected an error for a rejected request")
+	}
+}
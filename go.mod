@@ -7,16 +7,24 @@ replace github.com/aliyun-sls/zipkin-ingester => ./
 require (
 	github.com/aliyun/aliyun-log-go-sdk v0.1.21
 	github.com/confluentinc/confluent-kafka-go v1.7.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gogo/protobuf v1.3.2
+	github.com/golang/snappy v0.0.4
+	github.com/klauspost/compress v1.13.6
 	github.com/openzipkin/zipkin-go v0.2.5
+	github.com/pierrec/lz4 v2.6.0+incompatible
 	github.com/spf13/cast v1.3.1
 	github.com/spf13/viper v1.8.1
+	go.opentelemetry.io/otel v1.0.0-RC2
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.0.0-RC2
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.0.0-RC2
+	go.opentelemetry.io/otel/sdk v1.0.0-RC2
+	go.opentelemetry.io/otel/trace v1.0.0-RC2
 	go.opentelemetry.io/proto/otlp v0.9.0
 	go.uber.org/atomic v1.9.0 // indirect
-	go.uber.org/multierr v1.7.0 // indirect
+	go.uber.org/multierr v1.7.0
 	go.uber.org/zap v1.19.0
 	google.golang.org/grpc v1.39.0
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
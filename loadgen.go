@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/loadgen"
+)
+
+// runLoadgen runs the loadgen subcommand, it sends synthetic traces to a kafka
+// topic, a Zipkin HTTP endpoint or files and reports the achieved throughput.
+func runLoadgen(args []string) int {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	var config loadgen.Config
+	var kafkaConfig loadgen.KafkaConfig
+	var target, url, dir string
+	var timeout time.Duration
+	flags.StringVar(&target, "target", "", "Where the traces are sent: kafka, http or file")
+	flags.StringVar(&config.Encoding, "encoding", loadgen.EncodingJSON, "The encoding of the messages: json, proto3 or thrift")
+	flags.StringVar(&kafkaConfig.BootstrapServers, "kafka_bootstrap_services", os.Getenv("BOOTSTRAP_SERVICE"), "The bootstrap services of the kafka target")
+	flags.StringVar(&kafkaConfig.Topic, "kafka_topic", os.Getenv("TOPIC"), "The topic of the kafka target")
+	flags.StringVar(&kafkaConfig.SecurityProtocol, "kafka_security_protocol", os.Getenv("KAFKA_SECURITY_PROTOCOL"), "The security protocol of the kafka producer, e.g. SASL_SSL")
+	flags.StringVar(&kafkaConfig.SaslMechanism, "kafka_sasl_mechanism", os.Getenv("KAFKA_SASL_MECHANISM"), "The SASL mechanism, e.g. PLAIN or SCRAM-SHA-256")
+	flags.StringVar(&kafkaConfig.SaslUsername, "kafka_sasl_username", os.Getenv("KAFKA_SASL_USERNAME"), "The SASL username")
+	flags.StringVar(&kafkaConfig.SaslPassword, "kafka_sasl_password", os.Getenv("KAFKA_SASL_PASSWORD"), "The SASL password")
+	flags.StringVar(&kafkaConfig.Compression, "kafka_compression", "", "The compression of the kafka producer: gzip, snappy, lz4 or zstd")
+	flags.StringVar(&url, "url", "", "The endpoint of the http target, e.g. http://localhost:9411/api/v2/spans")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "The timeout of the requests of the http target")
+	flags.StringVar(&dir, "dir", "", "The directory of the file target, every message is written to its own file")
+	flags.IntVar(&config.Services, "services", 10, "The number of distinct services")
+	flags.IntVar(&config.Depth, "depth", 3, "The levels of the call tree of a trace, 1 is a lone server span")
+	flags.IntVar(&config.FanOut, "fan_out", 2, "The downstream calls of every server span above the last level")
+	flags.Float64Var(&config.ErrorRatio, "error_ratio", 0.01, "The ratio of the failing server spans")
+	flags.IntVar(&config.TagCardinality, "tag_cardinality", 1000, "The distinct values of the user.id tag, 0 omits it")
+	flags.IntVar(&config.PayloadSize, "payload_size", 0, "The bytes of the loadgen.payload tag added to every span, 0 omits it")
+	flags.Int64Var(&config.Seed, "seed", time.Now().UnixNano(), "The seed of the generated traces")
+	flags.IntVar(&config.TracesPerMessage, "traces_per_message", 10, "The traces encoded in every message")
+	flags.Float64Var(&config.Rate, "rate", 1000, "The target spans per second, 0 sends as fast as possible")
+	flags.DurationVar(&config.Duration, "duration", time.Minute, "How long the traces are sent, 0 sends until -messages or an interrupt")
+	flags.Int64Var(&config.Messages, "messages", 0, "The messages sent before stopping, 0 is unlimited")
+	flags.IntVar(&config.Workers, "workers", 1, "The goroutines generating and sending the messages")
+	flags.DurationVar(&config.ReportInterval, "report_interval", 5*time.Second, "How often the throughput is reported, 0 only reports at the end")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	encoder, err := loadgen.NewEncoder(config.Encoding)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The loadgen configuration is invalid: %v\n", err)
+		return 2
+	}
+	var sink loadgen.Sink
+	switch strings.ToLower(target) {
+	case loadgen.TargetKafka:
+		sink, err = loadgen.NewKafkaSink(kafkaConfig)
+	case loadgen.TargetHTTP:
+		sink, err = loadgen.NewHTTPSink(url, encoder.ContentType(), timeout)
+	case loadgen.TargetFile:
+		sink, err = loadgen.NewFileSink(dir, encoder.Extension())
+	default:
+		err = fmt.Errorf("unknown target %q, expected kafka, http or file", target)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to init the loadgen target: %v\n", err)
+		return 2
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		<-sigchan
+		close(stop)
+	}()
+
+	fmt.Printf("Sending %d spans per trace to %s as %s, target %.0f spans/s\n", config.SpansPerTrace(), target, config.Encoding, config.Rate)
+	stats, err := loadgen.Run(config, sink, stop, func(stats loadgen.Stats) {
+		fmt.Println(stats)
+	})
+	if err != nil {
+		_ = sink.Close()
+		fmt.Fprintf(os.Stderr, "The loadgen configuration is invalid: %v\n", err)
+		return 2
+	}
+	closeErr := sink.Close()
+	fmt.Printf("Done: %s\n", stats)
+	if closeErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to deliver all the messages: %v\n", closeErr)
+		return 1
+	}
+	if stats.Errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+)
+
+const (
+	EncodingJSON   = "json"
+	EncodingProto3 = "proto3"
+	EncodingThrift = "thrift"
+)
+
+// Encoder serializes the spans of a message.
+type Encoder interface {
+	Encode(spans []*zipkinmodel.SpanModel) ([]byte, error)
+	// ContentType is the Content-Type header of the HTTP requests.
+	ContentType() string
+	// Extension is the extension of the files.
+	Extension() string
+}
+
+// NewEncoder returns the encoder of the Zipkin v2 JSON, the proto3 ListOfSpans
+// or the Zipkin v1 thrift list of spans.
+func NewEncoder(encoding string) (Encoder, error) {
+	switch strings.ToLower(encoding) {
+	case "", EncodingJSON:
+		return jsonEncoder{}, nil
+	case EncodingProto3, "protobuf":
+		return protoEncoder{}, nil
+	case EncodingThrift:
+		return thriftEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q, expected json, proto3 or thrift", encoding)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(spans []*zipkinmodel.SpanModel) ([]byte, error) {
+	return json.Marshal(spans)
+}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (jsonEncoder) Extension() string {
+	return "json"
+}
+
+type protoEncoder struct{}
+
+func (protoEncoder) Encode(spans []*zipkinmodel.SpanModel) ([]byte, error) {
+	return zipkin_proto3.SpanSerializer{}.Serialize(spans)
+}
+
+func (protoEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protoEncoder) Extension() string {
+	return "pb"
+}
+
+type thriftEncoder struct{}
+
+func (thriftEncoder) Encode(spans []*zipkinmodel.SpanModel) ([]byte, error) {
+	return encodeThriftSpans(spans), nil
+}
+
+func (thriftEncoder) ContentType() string {
+	return "application/x-thrift"
+}
+
+func (thriftEncoder) Extension() string {
+	return "thrift"
+}
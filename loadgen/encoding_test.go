@@ -0,0 +1,175 @@
+package loadgen
+
+import (
+	"encoding/binary"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func testSpans(t *testing.T) []*zipkinmodel.SpanModel {
+	g, err := NewGenerator(testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.Trace(time.Unix(1659409534, 0))
+}
+
+func sortedIDs(spans []*zipkinmodel.SpanModel) []zipkinmodel.ID {
+	ids := make([]zipkinmodel.ID, 0, len(spans))
+	for _, span := range spans {
+		ids = append(ids, span.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestEncodersAreParsedByTheIngester(t *testing.T) {
+	spans := testSpans(t)
+	for _, test := range []struct {
+		encoding string
+		protocol string
+	}{
+		{EncodingJSON, "json"},
+		{EncodingProto3, "protobuf"},
+	} {
+		encoder, err := NewEncoder(test.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, err := encoder.Encode(spans)
+		if err != nil {
+			t.Fatalf("%s: %v", test.encoding, err)
+		}
+		parsed, err := converter.NewConverter(test.protocol).ParseSpans(payload, false)
+		if err != nil {
+			t.Fatalf("%s: Failed to parse the spans: %v", test.encoding, err)
+		}
+		if len(parsed) != len(spans) {
+			t.Fatalf("%s: Expected %d spans, Actual: %d", test.encoding, len(spans), len(parsed))
+		}
+		expected, actual := sortedIDs(spans), sortedIDs(parsed)
+		for i := range expected {
+			if expected[i] != actual[i] {
+				t.Errorf("%s: Expected the span %s, Actual: %s", test.encoding, expected[i], actual[i])
+			}
+		}
+		for _, span := range parsed {
+			if span.Tags[TagPayload] == "" || span.LocalEndpoint == nil || span.Timestamp.IsZero() {
+				t.Errorf("%s: Unexpected parsed span %+v", test.encoding, span)
+			}
+		}
+	}
+}
+
+// thriftReader reads back the fields written by the thriftWriter.
+type thriftReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *thriftReader) next(n int) []byte {
+	if len(r.buf) < n {
+		r.t.Fatalf("Expected %d more bytes, Actual: %d", n, len(r.buf))
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *thriftReader) i32() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+// skip skips a value of the type, it returns the strings of the fields of the structs.
+func (r *thriftReader) skip(typ byte, strings map[string]int) {
+	switch typ {
+	case thriftBool:
+		r.next(1)
+	case thriftI16:
+		r.next(2)
+	case thriftI32:
+		r.next(4)
+	case thriftI64:
+		r.next(8)
+	case thriftString:
+		strings[string(r.next(int(r.i32())))]++
+	case thriftStruct:
+		r.fields(func(typ byte, id int16) { r.skip(typ, strings) })
+	case thriftList:
+		elemType := r.next(1)[0]
+		for n := r.i32(); n > 0; n-- {
+			r.skip(elemType, strings)
+		}
+	default:
+		r.t.Fatalf("Unexpected thrift type %d", typ)
+	}
+}
+
+func (r *thriftReader) fields(field func(typ byte, id int16)) {
+	for {
+		typ := r.next(1)[0]
+		if typ == thriftStop {
+			return
+		}
+		field(typ, int16(binary.BigEndian.Uint16(r.next(2))))
+	}
+}
+
+func TestThriftEncoder(t *testing.T) {
+	spans := testSpans(t)
+	encoder, _ := NewEncoder(EncodingThrift)
+	payload, err := encoder.Encode(spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &thriftReader{t: t, buf: payload}
+	if elemType := r.next(1)[0]; elemType != thriftStruct {
+		t.Fatalf("Expected a list of structs, Actual: %d", elemType)
+	}
+	if n := r.i32(); int(n) != len(spans) {
+		t.Fatalf("Expected %d spans, Actual: %d", len(spans), n)
+	}
+	for i, span := range spans {
+		var ids []int64
+		strings := make(map[string]int)
+		r.fields(func(typ byte, id int16) {
+			if id == 4 || id == 5 {
+				ids = append(ids, int64(binary.BigEndian.Uint64(r.next(8))))
+				return
+			}
+			r.skip(typ, strings)
+		})
+		if len(ids) == 0 || ids[0] != int64(span.ID) || (span.ParentID != nil) != (len(ids) == 2) {
+			t.Errorf("Span %d: Expected the id %s and the parent %v, Actual: %v", i, span.ID, span.ParentID, ids)
+		}
+		begin, end := kindAnnotations(span.Kind)
+		if strings[span.Name] == 0 || strings[begin] == 0 || strings[end] == 0 || strings[remoteAddress(span.Kind)] != len(ids)-1 {
+			t.Errorf("Span %d: Expected the name and the core annotations, Actual: %v", i, strings)
+		}
+		for key, value := range span.Tags {
+			if strings[key] == 0 || strings[value] == 0 {
+				t.Errorf("Span %d: Expected the tag %s=%s", i, key, value)
+			}
+		}
+	}
+	if len(r.buf) != 0 {
+		t.Errorf("Expected the whole payload to be read, Actual: %d bytes left", len(r.buf))
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	for encoding, extension := range map[string]string{"": "json", "JSON": "json", "protobuf": "pb", "proto3": "pb", "thrift": "thrift"} {
+		encoder, err := NewEncoder(encoding)
+		if err != nil || encoder.Extension() != extension {
+			t.Errorf("Expected the %s encoder for %q, Actual: %v %v", extension, encoding, encoder, err)
+		}
+	}
+	if _, err := NewEncoder("avro"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
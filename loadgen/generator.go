@@ -0,0 +1,211 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+const (
+	TagPayload  = "loadgen.payload"
+	TagUserID   = "user.id"
+	paddingPool = 4
+)
+
+var (
+	methods    = []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"}
+	operations = []string{"list", "get", "create", "update", "search", "check", "query", "notify"}
+	alphabet   = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+)
+
+// Options shape the synthetic traces.
+type Options struct {
+	// Services is the number of distinct services the spans are spread over.
+	Services int
+	// Depth is the number of levels of the call tree, 1 is a lone server span.
+	Depth int
+	// FanOut is the number of downstream calls of the server spans above the last level.
+	FanOut int
+	// ErrorRatio is the ratio of the server spans failing, their callers fail too.
+	ErrorRatio float64
+	// TagCardinality is the number of distinct values of the user.id tag, 0 omits it.
+	TagCardinality int
+	// PayloadSize is the size in bytes of the loadgen.payload tag added to every span, 0 omits it.
+	PayloadSize int
+	// Seed makes the traces reproducible.
+	Seed int64
+}
+
+// SpansPerTrace is the number of spans of every generated trace: a server span
+// per call and a client span per downstream call.
+func (o Options) SpansPerTrace() int {
+	spans, calls := 1, 1
+	for level := 1; level < o.Depth; level++ {
+		calls *= o.FanOut
+		spans += 2 * calls
+	}
+	return spans
+}
+
+func (o Options) validate() error {
+	if o.Services < 1 {
+		return fmt.Errorf("the service count %d is less than 1", o.Services)
+	}
+	if o.Depth < 1 {
+		return fmt.Errorf("the depth %d is less than 1", o.Depth)
+	}
+	if o.FanOut < 0 {
+		return fmt.Errorf("the fan-out %d is negative", o.FanOut)
+	}
+	if o.ErrorRatio < 0 || o.ErrorRatio > 1 {
+		return fmt.Errorf("the error ratio %v is not between 0 and 1", o.ErrorRatio)
+	}
+	if o.TagCardinality < 0 || o.PayloadSize < 0 {
+		return fmt.Errorf("the tag cardinality and the payload size must not be negative")
+	}
+	return nil
+}
+
+// service is a synthetic service, its endpoint and its operations.
+type service struct {
+	endpoint   zipkinmodel.Endpoint
+	operations []string
+}
+
+// Generator builds synthetic traces looking like the ones of HTTP services
+// calling each other. It is not safe for concurrent use.
+type Generator struct {
+	options  Options
+	random   *rand.Rand
+	services []service
+	padding  []byte
+}
+
+func NewGenerator(options Options) (*Generator, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	random := rand.New(rand.NewSource(options.Seed))
+	services := make([]service, options.Services)
+	for i := range services {
+		name := fmt.Sprintf("service-%d", i)
+		ops := make([]string, 2+random.Intn(4))
+		for j := range ops {
+			ops[j] = fmt.Sprintf("/api/%s/%s", name, operations[random.Intn(len(operations))])
+		}
+		services[i] = service{
+			endpoint: zipkinmodel.Endpoint{
+				ServiceName: name,
+				IPv4:        net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)).To4(),
+				Port:        uint16(8000 + i%1000),
+			},
+			operations: ops,
+		}
+	}
+
+	// the payloads are windows of a random pool rather than generated per span
+	padding := make([]byte, options.PayloadSize*paddingPool)
+	for i := range padding {
+		padding[i] = alphabet[random.Intn(len(alphabet))]
+	}
+	return &Generator{options: options, random: random, services: services, padding: padding}, nil
+}
+
+// Trace returns the spans of a new trace starting at start.
+func (g *Generator) Trace(start time.Time) []*zipkinmodel.SpanModel {
+	traceID := zipkinmodel.TraceID{High: g.random.Uint64(), Low: g.random.Uint64()}
+	spans := make([]*zipkinmodel.SpanModel, 0, g.options.SpansPerTrace())
+	duration := time.Duration(10+g.random.Intn(190)) * time.Millisecond
+	root := &g.services[g.random.Intn(len(g.services))]
+	spans, _ = g.server(spans, traceID, nil, root, nil, start.Truncate(time.Microsecond), duration, 1)
+	return spans
+}
+
+// server appends the server span of a call and the calls it makes, it reports
+// whether the call failed.
+func (g *Generator) server(spans []*zipkinmodel.SpanModel, traceID zipkinmodel.TraceID, parentID *zipkinmodel.ID,
+	callee *service, caller *service, start time.Time, duration time.Duration, level int) ([]*zipkinmodel.SpanModel, bool) {
+	span := g.span(traceID, parentID, zipkinmodel.Server, callee, start, duration)
+	span.Name = callee.operations[g.random.Intn(len(callee.operations))]
+	span.Tags["http.path"] = span.Name
+	if caller != nil {
+		span.RemoteEndpoint = &zipkinmodel.Endpoint{ServiceName: caller.endpoint.ServiceName, IPv4: caller.endpoint.IPv4}
+	}
+	spans = append(spans, span)
+
+	failed := g.options.ErrorRatio > 0 && g.random.Float64() < g.options.ErrorRatio
+	if level < g.options.Depth && g.options.FanOut > 0 {
+		// the calls are made one after the other within the server span
+		slot := duration / time.Duration(g.options.FanOut+1)
+		for i := 0; i < g.options.FanOut; i++ {
+			downstream := &g.services[g.random.Intn(len(g.services))]
+			callStart := start.Add(slot/2 + time.Duration(i)*slot).Truncate(time.Microsecond)
+			callDuration := (slot * 9 / 10).Truncate(time.Microsecond)
+			client := g.span(traceID, &span.ID, zipkinmodel.Client, callee, callStart, callDuration)
+			client.Name = downstream.operations[g.random.Intn(len(downstream.operations))]
+			client.Tags["http.path"] = client.Name
+			client.RemoteEndpoint = &zipkinmodel.Endpoint{ServiceName: downstream.endpoint.ServiceName, IPv4: downstream.endpoint.IPv4, Port: downstream.endpoint.Port}
+			spans = append(spans, client)
+
+			var callFailed bool
+			serverStart := callStart.Add(callDuration / 20).Truncate(time.Microsecond)
+			spans, callFailed = g.server(spans, traceID, &client.ID, downstream, callee, serverStart, (callDuration * 9 / 10).Truncate(time.Microsecond), level+1)
+			if callFailed {
+				setFailed(client)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		setFailed(span)
+	}
+	return spans, failed
+}
+
+func (g *Generator) span(traceID zipkinmodel.TraceID, parentID *zipkinmodel.ID, kind zipkinmodel.Kind, local *service,
+	start time.Time, duration time.Duration) *zipkinmodel.SpanModel {
+	endpoint := local.endpoint
+	if kind == zipkinmodel.Client {
+		endpoint.Port = 0
+	}
+	tags := map[string]string{
+		"http.method":      methods[g.random.Intn(len(methods))],
+		"http.status_code": "200",
+	}
+	if g.options.TagCardinality > 0 {
+		tags[TagUserID] = strconv.Itoa(g.random.Intn(g.options.TagCardinality))
+	}
+	if size := g.options.PayloadSize; size > 0 {
+		offset := g.random.Intn(len(g.padding) - size + 1)
+		tags[TagPayload] = string(g.padding[offset : offset+size])
+	}
+	return &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID:  traceID,
+			ID:       g.spanID(),
+			ParentID: parentID,
+		},
+		Kind:          kind,
+		Timestamp:     start,
+		Duration:      duration,
+		LocalEndpoint: &endpoint,
+		Tags:          tags,
+	}
+}
+
+func (g *Generator) spanID() zipkinmodel.ID {
+	for {
+		if id := zipkinmodel.ID(g.random.Uint64()); id != 0 {
+			return id
+		}
+	}
+}
+
+func setFailed(span *zipkinmodel.SpanModel) {
+	span.Tags["http.status_code"] = "500"
+	span.Tags["error"] = "true"
+}
@@ -0,0 +1,120 @@
+package loadgen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+var testOptions = Options{Services: 5, Depth: 3, FanOut: 2, ErrorRatio: 0.2, TagCardinality: 3, PayloadSize: 16, Seed: 1}
+
+func TestGeneratorTraceShape(t *testing.T) {
+	g, err := NewGenerator(testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1659409534, 0)
+	spans := g.Trace(start)
+	if len(spans) != testOptions.SpansPerTrace() || len(spans) != 13 {
+		t.Fatalf("Expected 13 spans, Actual: %d", len(spans))
+	}
+
+	byID := make(map[zipkinmodel.ID]*zipkinmodel.SpanModel)
+	for _, span := range spans {
+		byID[span.ID] = span
+	}
+	depth := func(span *zipkinmodel.SpanModel) (level int) {
+		for ; span.ParentID != nil; span = byID[*span.ParentID] {
+			level++
+		}
+		return level
+	}
+	for i, span := range spans {
+		if span.TraceID != spans[0].TraceID || span.LocalEndpoint == nil || span.Name == "" {
+			t.Errorf("Span %d: Unexpected span %+v", i, span)
+		}
+		if span.Tags[TagUserID] == "" || len(span.Tags[TagPayload]) != 16 {
+			t.Errorf("Span %d: Expected the user.id and payload tags, Actual: %v", i, span.Tags)
+		}
+		if span.ParentID == nil {
+			if i != 0 || span.Kind != zipkinmodel.Server || span.RemoteEndpoint != nil {
+				t.Errorf("Span %d: Expected only the first span to be the root", i)
+			}
+			continue
+		}
+		parent := byID[*span.ParentID]
+		if parent == nil || span.Timestamp.Before(parent.Timestamp) || span.Timestamp.Add(span.Duration).After(parent.Timestamp.Add(parent.Duration)) {
+			t.Errorf("Span %d: Expected the span within its parent %+v", i, parent)
+		}
+		if (span.Kind == zipkinmodel.Client) != (parent.Kind == zipkinmodel.Server) || span.RemoteEndpoint == nil {
+			t.Errorf("Span %d: Expected the client and server spans to alternate", i)
+		}
+		if span.Kind == zipkinmodel.Server && span.RemoteEndpoint.ServiceName != parent.LocalEndpoint.ServiceName {
+			t.Errorf("Span %d: Expected the caller %s, Actual: %s", i, parent.LocalEndpoint.ServiceName, span.RemoteEndpoint.ServiceName)
+		}
+		if depth(span) > 2*(testOptions.Depth-1) {
+			t.Errorf("Span %d: Expected at most %d levels, Actual: %d", i, testOptions.Depth, depth(span))
+		}
+		if span.Kind == zipkinmodel.Server && span.Tags["error"] == "true" && parent.Tags["error"] != "true" {
+			t.Errorf("Span %d: Expected the caller of a failed call to fail", i)
+		}
+	}
+}
+
+func TestGeneratorDistributions(t *testing.T) {
+	g, _ := NewGenerator(testOptions)
+	services := make(map[string]bool)
+	users := make(map[string]bool)
+	spans, failed := 0, 0
+	for i := 0; i < 500; i++ {
+		for _, span := range g.Trace(time.Now()) {
+			services[span.LocalEndpoint.ServiceName] = true
+			users[span.Tags[TagUserID]] = true
+			spans++
+			if span.Tags["error"] == "true" {
+				failed++
+			}
+		}
+	}
+	if len(services) != testOptions.Services {
+		t.Errorf("Expected %d services, Actual: %d", testOptions.Services, len(services))
+	}
+	if len(users) != testOptions.TagCardinality {
+		t.Errorf("Expected %d user ids, Actual: %d", testOptions.TagCardinality, len(users))
+	}
+	if failed == 0 || failed == spans {
+		t.Errorf("Expected some failed spans, Actual: %d of %d", failed, spans)
+	}
+}
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	start := time.Unix(1659409534, 0)
+	first, _ := NewGenerator(testOptions)
+	second, _ := NewGenerator(testOptions)
+	if !reflect.DeepEqual(first.Trace(start), second.Trace(start)) {
+		t.Error("Expected the same traces for the same seed")
+	}
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	if spans := (Options{Depth: 1, FanOut: 5}).SpansPerTrace(); spans != 1 {
+		t.Errorf("Expected a lone span, Actual: %d", spans)
+	}
+	if spans := (Options{Depth: 4, FanOut: 3}).SpansPerTrace(); spans != 1+2*(3+9+27) {
+		t.Errorf("Expected 79 spans, Actual: %d", spans)
+	}
+	invalid := []Options{
+		{Services: 0, Depth: 1},
+		{Services: 1, Depth: 0},
+		{Services: 1, Depth: 1, FanOut: -1},
+		{Services: 1, Depth: 1, ErrorRatio: 1.5},
+		{Services: 1, Depth: 1, PayloadSize: -1},
+	}
+	for _, options := range invalid {
+		if _, err := NewGenerator(options); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package loadgen
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the load of a run.
+type Config struct {
+	Options
+	Encoding string
+	// TracesPerMessage is the number of traces encoded in every message.
+	TracesPerMessage int
+	// Rate is the target of spans sent per second, 0 sends as fast as possible.
+	Rate float64
+	// Duration stops the run after it, 0 runs until Messages are sent or it is stopped.
+	Duration time.Duration
+	// Messages stops the run once sent, 0 runs until Duration or it is stopped.
+	Messages int64
+	// Workers is the number of goroutines generating and sending the messages.
+	Workers int
+	// ReportInterval is how often the throughput is reported, 0 only reports at the end.
+	ReportInterval time.Duration
+}
+
+// Stats are the messages sent since the start of a run.
+type Stats struct {
+	Messages int64
+	Spans    int64
+	Bytes    int64
+	Errors   int64
+	Elapsed  time.Duration
+}
+
+// SpansPerSecond is the achieved throughput.
+func (s Stats) SpansPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Spans) / s.Elapsed.Seconds()
+}
+
+func (s Stats) String() string {
+	seconds := s.Elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	return fmt.Sprintf("elapsed=%s messages=%d spans=%d bytes=%d errors=%d spans/s=%.0f messages/s=%.1f MB/s=%.2f",
+		s.Elapsed.Truncate(time.Millisecond), s.Messages, s.Spans, s.Bytes, s.Errors,
+		s.SpansPerSecond(), float64(s.Messages)/seconds, float64(s.Bytes)/seconds/1e6)
+}
+
+// pacer spaces the messages of the workers out to the target rate.
+type pacer struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+// wait blocks until n more spans can be sent, or stop is closed.
+func (p *pacer) wait(n int, stop <-chan struct{}) bool {
+	if p.rate <= 0 {
+		return true
+	}
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	at := p.next
+	p.next = p.next.Add(time.Duration(float64(n) / p.rate * float64(time.Second)))
+	p.mu.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
+// Run generates messages and sends them to the sink until the duration elapsed,
+// the messages were sent or stop is closed. report is called with the stats
+// every report interval, the final stats are returned.
+func Run(config Config, sink Sink, stop <-chan struct{}, report func(Stats)) (Stats, error) {
+	encoder, err := NewEncoder(config.Encoding)
+	if err != nil {
+		return Stats{}, err
+	}
+	if config.TracesPerMessage < 1 {
+		config.TracesPerMessage = 1
+	}
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	generators := make([]*Generator, config.Workers)
+	for i := range generators {
+		options := config.Options
+		options.Seed += int64(i)
+		if generators[i], err = NewGenerator(options); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	finish := func() { once.Do(func() { close(done) }) }
+	go func() {
+		select {
+		case <-stop:
+			finish()
+		case <-done:
+		}
+	}()
+	if config.Duration > 0 {
+		timer := time.AfterFunc(config.Duration, finish)
+		defer timer.Stop()
+	}
+
+	var stats Stats
+	var reserved int64
+	start := time.Now()
+	snapshot := func() Stats {
+		return Stats{
+			Messages: atomic.LoadInt64(&stats.Messages),
+			Spans:    atomic.LoadInt64(&stats.Spans),
+			Bytes:    atomic.LoadInt64(&stats.Bytes),
+			Errors:   atomic.LoadInt64(&stats.Errors),
+			Elapsed:  time.Since(start),
+		}
+	}
+	reporting := make(chan struct{})
+	if config.ReportInterval > 0 && report != nil {
+		ticker := time.NewTicker(config.ReportInterval)
+		defer ticker.Stop()
+		go func() {
+			defer close(reporting)
+			for {
+				select {
+				case <-ticker.C:
+					report(snapshot())
+				case <-done:
+					return
+				}
+			}
+		}()
+	} else {
+		close(reporting)
+	}
+
+	pace := &pacer{rate: config.Rate}
+	var wg sync.WaitGroup
+	for _, generator := range generators {
+		wg.Add(1)
+		go func(generator *Generator) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if config.Messages > 0 && atomic.AddInt64(&reserved, 1) > config.Messages {
+					finish()
+					return
+				}
+
+				now := time.Now()
+				spans := generator.Trace(now)
+				for i := 1; i < config.TracesPerMessage; i++ {
+					spans = append(spans, generator.Trace(now)...)
+				}
+				payload, err := encoder.Encode(spans)
+				if err != nil {
+					atomic.AddInt64(&stats.Errors, 1)
+					continue
+				}
+				if !pace.wait(len(spans), done) {
+					return
+				}
+				if err := sink.Send(payload); err != nil {
+					atomic.AddInt64(&stats.Errors, 1)
+					continue
+				}
+				atomic.AddInt64(&stats.Messages, 1)
+				atomic.AddInt64(&stats.Spans, int64(len(spans)))
+				atomic.AddInt64(&stats.Bytes, int64(len(payload)))
+			}
+		}(generator)
+	}
+	wg.Wait()
+	finish()
+	// the last report is never concurrent with the final stats
+	<-reporting
+	return snapshot(), nil
+}
@@ -0,0 +1,133 @@
+package loadgen
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+)
+
+func testConfig() Config {
+	return Config{Options: testOptions, Encoding: EncodingJSON, TracesPerMessage: 2, Workers: 3}
+}
+
+func TestRunSendsTheMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sink, err := NewFileSink(dir, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig()
+	config.Messages = 25
+	stats, err := Run(config, sink, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Messages != 25 || stats.Spans != 25*2*13 || stats.Errors != 0 || stats.Bytes == 0 {
+		t.Errorf("Expected 25 messages of 26 spans, Actual: %s", stats)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 25 {
+		t.Fatalf("Expected 25 files, Actual: %d", len(files))
+	}
+	payload, _ := ioutil.ReadFile(filepath.Join(dir, "000000025.json"))
+	if spans, err := converter.NewConverter("json").ParseSpans(payload, false); err != nil || len(spans) != 26 {
+		t.Errorf("Expected 26 spans in the last file, Actual: %d %v", len(spans), err)
+	}
+}
+
+func TestRunPacesTheSpans(t *testing.T) {
+	var mu sync.Mutex
+	var contentType string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		contentType = r.Header.Get("Content-Type")
+		requests++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	encoder, _ := NewEncoder(EncodingProto3)
+	sink, _ := NewHTTPSink(server.URL, encoder.ContentType(), time.Second)
+
+	config := testConfig()
+	config.Encoding = EncodingProto3
+	config.Rate = 26 * 20
+	config.Duration = 500 * time.Millisecond
+	config.ReportInterval = 100 * time.Millisecond
+	reports := 0
+	stats, err := Run(config, sink, nil, func(Stats) { reports++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20 messages per second, the first one is sent at once
+	if stats.Messages < 8 || stats.Messages > 12 || stats.Errors != 0 {
+		t.Errorf("Expected about 10 messages, Actual: %s", stats)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != int(stats.Messages) || contentType != "application/x-protobuf" {
+		t.Errorf("Expected %d protobuf requests, Actual: %d %s", stats.Messages, requests, contentType)
+	}
+	if reports < 3 {
+		t.Errorf("Expected the stats to be reported, Actual: %d reports", reports)
+	}
+}
+
+func TestRunCountsTheErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	sink, _ := NewHTTPSink(server.URL, "application/json", time.Second)
+	if err := sink.Send([]byte("[]")); err == nil {
+		t.Error("Expected an error for a 503 response")
+	}
+
+	stop := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(stop) })
+	stats, err := Run(testConfig(), sink, stop, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Messages != 0 || stats.Errors == 0 {
+		t.Errorf("Expected only errors, Actual: %s", stats)
+	}
+}
+
+func TestRunRejectsInvalidConfigs(t *testing.T) {
+	config := testConfig()
+	config.Encoding = "avro"
+	if _, err := Run(config, nil, nil, nil); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+	config = testConfig()
+	config.Services = 0
+	if _, err := Run(config, nil, nil, nil); err == nil {
+		t.Error("Expected an error for invalid options")
+	}
+}
+
+func TestNewSinksRequireTheirTarget(t *testing.T) {
+	if _, err := NewKafkaSink(KafkaConfig{BootstrapServers: "localhost:9092"}); err == nil {
+		t.Error("Expected an error without a topic")
+	}
+	if _, err := NewHTTPSink("", "application/json", time.Second); err == nil {
+		t.Error("Expected an error without an url")
+	}
+	if _, err := NewFileSink("", "json"); err == nil {
+		t.Error("Expected an error without a directory")
+	}
+}
@@ -0,0 +1,180 @@
+package loadgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	TargetKafka = "kafka"
+	TargetHTTP  = "http"
+	TargetFile  = "file"
+
+	// flushTimeout bounds how long the kafka sink waits for the pending deliveries at close.
+	flushTimeout = 30 * time.Second
+)
+
+// Sink delivers the encoded messages. Send may be called concurrently.
+type Sink interface {
+	Send(payload []byte) error
+	// Close flushes the pending messages, it reports the messages which failed
+	// asynchronously.
+	Close() error
+}
+
+// KafkaConfig is the producer configuration of the kafka sink.
+type KafkaConfig struct {
+	BootstrapServers string
+	Topic            string
+	SecurityProtocol string
+	SaslMechanism    string
+	SaslUsername     string
+	SaslPassword     string
+	Compression      string
+}
+
+type kafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+	failed   int64
+	done     chan struct{}
+}
+
+// NewKafkaSink produces the messages to the topic, their delivery is asynchronous.
+func NewKafkaSink(config KafkaConfig) (Sink, error) {
+	if config.BootstrapServers == "" || config.Topic == "" {
+		return nil, fmt.Errorf("the bootstrap servers and the topic of the kafka sink are required")
+	}
+	producerConfig := &kafka.ConfigMap{
+		"bootstrap.servers": config.BootstrapServers,
+		"linger.ms":         5,
+	}
+	optional := map[string]string{
+		"security.protocol": config.SecurityProtocol,
+		"sasl.mechanisms":   config.SaslMechanism,
+		"sasl.username":     config.SaslUsername,
+		"sasl.password":     config.SaslPassword,
+		"compression.type":  config.Compression,
+	}
+	for key, value := range optional {
+		if value != "" {
+			_ = producerConfig.SetKey(key, value)
+		}
+	}
+	producer, err := kafka.NewProducer(producerConfig)
+	if err != nil {
+		return nil, err
+	}
+	s := &kafkaSink{producer: producer, topic: config.Topic, done: make(chan struct{})}
+	go s.deliveries()
+	return s, nil
+}
+
+func (s *kafkaSink) deliveries() {
+	defer close(s.done)
+	for event := range s.producer.Events() {
+		if msg, ok := event.(*kafka.Message); ok && msg.TopicPartition.Error != nil {
+			atomic.AddInt64(&s.failed, 1)
+		}
+	}
+}
+
+// Send queues the message, it waits for room when the producer queue is full.
+func (s *kafkaSink) Send(payload []byte) error {
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &s.topic, Partition: kafka.PartitionAny},
+		Value:          payload,
+	}
+	for {
+		err := s.producer.Produce(msg, nil)
+		if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrQueueFull {
+			s.producer.Flush(100)
+			continue
+		}
+		return err
+	}
+}
+
+func (s *kafkaSink) Close() error {
+	pending := s.producer.Flush(int(flushTimeout / time.Millisecond))
+	s.producer.Close()
+	<-s.done
+	if failed := atomic.LoadInt64(&s.failed); failed > 0 || pending > 0 {
+		return fmt.Errorf("%d messages failed and %d were still pending", failed, pending)
+	}
+	return nil
+}
+
+type httpSink struct {
+	url         string
+	contentType string
+	client      *http.Client
+}
+
+// NewHTTPSink posts the messages to a Zipkin /api/v2/spans endpoint.
+func NewHTTPSink(url string, contentType string, timeout time.Duration) (Sink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("the url of the http sink is required")
+	}
+	return &httpSink{url: url, contentType: contentType, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (s *httpSink) Send(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("the endpoint responded %s: %s", resp.Status, msg)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+type fileSink struct {
+	dir       string
+	extension string
+	count     int64
+}
+
+// NewFileSink writes every message to its own file of the directory, named after
+// its sequence number, e.g. 000000042.json.
+func NewFileSink(dir string, extension string) (Sink, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("the directory of the file sink is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileSink{dir: dir, extension: extension}, nil
+}
+
+func (s *fileSink) Send(payload []byte) error {
+	n := atomic.AddInt64(&s.count, 1)
+	return ioutil.WriteFile(filepath.Join(s.dir, fmt.Sprintf("%09d.%s", n, s.extension)), payload, 0644)
+}
+
+func (s *fileSink) Close() error {
+	return nil
+}
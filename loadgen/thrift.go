@@ -0,0 +1,226 @@
+package loadgen
+
+import (
+	"encoding/binary"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+// The TBinaryProtocol types of the fields of zipkinCore.thrift.
+const (
+	thriftStop   = 0
+	thriftBool   = 2
+	thriftI16    = 6
+	thriftI32    = 8
+	thriftI64    = 10
+	thriftString = 11
+	thriftStruct = 12
+	thriftList   = 15
+)
+
+// The annotation types of the binary annotations.
+const (
+	annotationBool   = 0
+	annotationString = 6
+)
+
+// thriftWriter writes the TBinaryProtocol encoding, without the message
+// envelope, as the Zipkin v1 thrift transports do.
+type thriftWriter struct {
+	buf []byte
+}
+
+// encodeThriftSpans converts the spans to the Zipkin v1 model and encodes them
+// as a thrift list of spans.
+func encodeThriftSpans(spans []*zipkinmodel.SpanModel) []byte {
+	w := &thriftWriter{}
+	w.listBegin(thriftStruct, len(spans))
+	for _, span := range spans {
+		w.span(span)
+	}
+	return w.buf
+}
+
+func (w *thriftWriter) span(span *zipkinmodel.SpanModel) {
+	w.fieldBegin(thriftI64, 1)
+	w.i64(int64(span.TraceID.Low))
+	w.fieldBegin(thriftString, 3)
+	w.string(span.Name)
+	w.fieldBegin(thriftI64, 4)
+	w.i64(int64(span.ID))
+	if span.ParentID != nil {
+		w.fieldBegin(thriftI64, 5)
+		w.i64(int64(*span.ParentID))
+	}
+
+	// the kind and the v2 annotations are v1 annotations
+	begin, end := kindAnnotations(span.Kind)
+	annotations := len(span.Annotations)
+	if begin != "" {
+		annotations++
+	}
+	if end != "" && span.Duration > 0 {
+		annotations++
+	}
+	w.fieldBegin(thriftList, 6)
+	w.listBegin(thriftStruct, annotations)
+	if begin != "" {
+		w.annotation(span.Timestamp, begin, span.LocalEndpoint)
+	}
+	if end != "" && span.Duration > 0 {
+		w.annotation(span.Timestamp.Add(span.Duration), end, span.LocalEndpoint)
+	}
+	for _, annotation := range span.Annotations {
+		w.annotation(annotation.Timestamp, annotation.Value, span.LocalEndpoint)
+	}
+
+	// the tags and the remote endpoint are v1 binary annotations
+	address := remoteAddress(span.Kind)
+	binaryAnnotations := len(span.Tags)
+	if address != "" && span.RemoteEndpoint != nil {
+		binaryAnnotations++
+	}
+	w.fieldBegin(thriftList, 8)
+	w.listBegin(thriftStruct, binaryAnnotations)
+	for key, value := range span.Tags {
+		w.binaryAnnotation(key, []byte(value), annotationString, span.LocalEndpoint)
+	}
+	if address != "" && span.RemoteEndpoint != nil {
+		w.binaryAnnotation(address, []byte{1}, annotationBool, span.RemoteEndpoint)
+	}
+
+	if span.Debug {
+		w.fieldBegin(thriftBool, 9)
+		w.bool(true)
+	}
+	if !span.Shared && !span.Timestamp.IsZero() {
+		w.fieldBegin(thriftI64, 10)
+		w.i64(span.Timestamp.UnixNano() / 1e3)
+		if span.Duration > 0 {
+			w.fieldBegin(thriftI64, 11)
+			w.i64(int64(span.Duration / time.Microsecond))
+		}
+	}
+	if span.TraceID.High != 0 {
+		w.fieldBegin(thriftI64, 12)
+		w.i64(int64(span.TraceID.High))
+	}
+	w.fieldStop()
+}
+
+// kindAnnotations are the core annotations of the v1 spans of the kind.
+func kindAnnotations(kind zipkinmodel.Kind) (begin, end string) {
+	switch kind {
+	case zipkinmodel.Client:
+		return "cs", "cr"
+	case zipkinmodel.Server:
+		return "sr", "ss"
+	case zipkinmodel.Producer:
+		return "ms", ""
+	case zipkinmodel.Consumer:
+		return "mr", ""
+	}
+	return "", ""
+}
+
+// remoteAddress is the key of the binary annotation of the remote endpoint.
+func remoteAddress(kind zipkinmodel.Kind) string {
+	switch kind {
+	case zipkinmodel.Client, zipkinmodel.Producer:
+		return "sa"
+	case zipkinmodel.Server, zipkinmodel.Consumer:
+		return "ca"
+	}
+	return ""
+}
+
+func (w *thriftWriter) annotation(timestamp time.Time, value string, host *zipkinmodel.Endpoint) {
+	w.fieldBegin(thriftI64, 1)
+	w.i64(timestamp.UnixNano() / 1e3)
+	w.fieldBegin(thriftString, 2)
+	w.string(value)
+	if host != nil {
+		w.fieldBegin(thriftStruct, 3)
+		w.endpoint(host)
+	}
+	w.fieldStop()
+}
+
+func (w *thriftWriter) binaryAnnotation(key string, value []byte, annotationType int32, host *zipkinmodel.Endpoint) {
+	w.fieldBegin(thriftString, 1)
+	w.string(key)
+	w.fieldBegin(thriftString, 2)
+	w.bytes(value)
+	w.fieldBegin(thriftI32, 3)
+	w.i32(annotationType)
+	if host != nil {
+		w.fieldBegin(thriftStruct, 4)
+		w.endpoint(host)
+	}
+	w.fieldStop()
+}
+
+func (w *thriftWriter) endpoint(endpoint *zipkinmodel.Endpoint) {
+	var ipv4 int32
+	if ip := endpoint.IPv4.To4(); ip != nil {
+		ipv4 = int32(binary.BigEndian.Uint32(ip))
+	}
+	w.fieldBegin(thriftI32, 1)
+	w.i32(ipv4)
+	w.fieldBegin(thriftI16, 2)
+	w.i16(int16(endpoint.Port))
+	w.fieldBegin(thriftString, 3)
+	w.string(endpoint.ServiceName)
+	if ip := endpoint.IPv6.To16(); ip != nil && endpoint.IPv6.To4() == nil {
+		w.fieldBegin(thriftString, 4)
+		w.bytes(ip)
+	}
+	w.fieldStop()
+}
+
+func (w *thriftWriter) fieldBegin(typ byte, id int16) {
+	w.buf = append(w.buf, typ)
+	w.i16(id)
+}
+
+func (w *thriftWriter) fieldStop() {
+	w.buf = append(w.buf, thriftStop)
+}
+
+func (w *thriftWriter) listBegin(elemType byte, size int) {
+	w.buf = append(w.buf, elemType)
+	w.i32(int32(size))
+}
+
+func (w *thriftWriter) bool(value bool) {
+	if value {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *thriftWriter) i16(value int16) {
+	w.buf = append(w.buf, byte(uint16(value)>>8), byte(value))
+}
+
+func (w *thriftWriter) i32(value int32) {
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(value))
+}
+
+func (w *thriftWriter) i64(value int64) {
+	w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(w.buf[len(w.buf)-8:], uint64(value))
+}
+
+func (w *thriftWriter) string(value string) {
+	w.i32(int32(len(value)))
+	w.buf = append(w.buf, value...)
+}
+
+func (w *thriftWriter) bytes(value []byte) {
+	w.i32(int32(len(value)))
+	w.buf = append(w.buf, value...)
+}
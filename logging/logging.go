@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Config configures the logger shared by all the components of the ingester.
+type Config struct {
+	Format string
+	// File is the log file, the logs go to stdout if it is empty.
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	// Within every second, the first SampleInitial warnings or errors with the same
+	// message are logged, then only one in SampleThereafter, so a failing backend
+	// can't flood the logs. The entries below the warn level, such as the audit
+	// logs, are never sampled.
+	SampleInitial    int
+	SampleThereafter int
+}
+
+// New creates the logger, its level can be changed at runtime through the atomic level.
+func New(config Config, level zap.AtomicLevel) (*zap.Logger, error) {
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch strings.ToLower(config.Format) {
+	case "", FormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case FormatConsole:
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or console", config.Format)
+	}
+
+	var output zapcore.WriteSyncer = zapcore.Lock(os.Stdout)
+	if config.File != "" {
+		output = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   config.File,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAgeDays,
+		})
+	}
+
+	core := zapcore.NewCore(encoder, output, level)
+	if config.SampleInitial > 0 {
+		thereafter := config.SampleThereafter
+		if thereafter <= 0 {
+			thereafter = config.SampleInitial
+		}
+		below := zap.LevelEnablerFunc(func(l zapcore.Level) bool { return l < zapcore.WarnLevel && level.Enabled(l) })
+		above := zap.LevelEnablerFunc(func(l zapcore.Level) bool { return l >= zapcore.WarnLevel && level.Enabled(l) })
+		core = zapcore.NewTee(
+			zapcore.NewCore(encoder, output, below),
+			zapcore.NewSamplerWithOptions(zapcore.NewCore(encoder.Clone(), output, above), time.Second, config.SampleInitial, thereafter),
+		)
+	}
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
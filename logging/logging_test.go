@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileOutputIsSampled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ingester.log")
+
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	logger, err := New(Config{File: path, MaxSizeMB: 1, SampleInitial: 2, SampleThereafter: 10}, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		logger.Sugar().Warnw("Failed to send logs to sls", "attempt", i)
+	}
+	// the info entries, like the audit logs, are not sampled
+	for i := 0; i < 12; i++ {
+		logger.Sugar().Infow("Receive Span", "SpanID", i)
+	}
+	logger.Debug("not logged at info level")
+	level.SetLevel(zap.DebugLevel)
+	logger.Debug("logged at debug level")
+	logger.Sync()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	// the first 2 warnings, then the 12th one, then the 12 info entries and the debug entry
+	if len(lines) != 16 {
+		t.Fatalf("expected 16 entries, got %d:\n%s", len(lines), data)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "warn" || entry["attempt"] != float64(11) {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if !strings.Contains(lines[14], `"SpanID":11`) {
+		t.Errorf("the info entries are sampled: %s", lines[14])
+	}
+	if !strings.Contains(lines[15], "logged at debug level") {
+		t.Errorf("the level change is not applied: %s", lines[15])
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := New(Config{Format: "xml"}, zap.NewAtomicLevel()); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := New(Config{Format: "Console"}, zap.NewAtomicLevel()); err != nil {
+		t.Error(err)
+	}
+}
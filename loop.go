@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"github.com/aliyun-sls/zipkin-ingester/telemetry"
+	"go.uber.org/zap"
+)
+
+// loop is the state of the consuming loop. The messages, the drains and the
+// shutdown of the sources go through it, whether they are selected by main or
+// driven by the tests.
+type loop struct {
+	current   *pipeline
+	consumers *sources
+	reloader  *reloader
+	throttler *throttle
+	tracer    *telemetry.Tracer
+	sugar     *zap.SugaredLogger
+}
+
+func newLoop(current *pipeline, consumers *sources, reloader *reloader, tracer *telemetry.Tracer, sugar *zap.SugaredLogger) *loop {
+	return &loop{
+		current:   current,
+		consumers: consumers,
+		reloader:  reloader,
+		throttler: newThrottle(consumers.sources, sugar),
+		tracer:    tracer,
+		sugar:     sugar,
+	}
+}
+
+// handle hands the message to the current pipeline, its offset is committed
+// with a later checkpoint.
+func (l *loop) handle(msg receiver.SourceMessage) {
+	trace := l.tracer.StartMessage(msg.PollStart, msg.Message)
+	l.current.handle(msg, trace, l.sugar)
+	trace.End()
+	msg.Source.Handled(msg.Message)
+	l.tick()
+}
+
+// tick exports the batches which are due and pauses the throttled partitions.
+func (l *loop) tick() {
+	l.current.flush(false, l.sugar)
+	l.throttler.pause(l.current.processors.Throttled())
+}
+
+// drain flushes the current pipeline and waits for the replaced ones, before
+// the partitions of a source are revoked.
+func (l *loop) drain() {
+	l.current.drain(l.sugar)
+	l.reloader.retiring.Wait()
+}
+
+// reload swaps the current pipeline for one built from the new configuration.
+func (l *loop) reload() {
+	l.current = l.reloader.reload(l.current)
+}
+
+// stop stops polling the sources, the messages polled meanwhile are still handled.
+func (l *loop) stop() {
+	l.consumers.stop(l.handle, l.drain)
+}
+
+// close flushes the pipelines, then commits the offsets and leaves the consumer
+// groups. The steps still running at the deadline are abandoned, and so are the
+// offsets if the spans were not exported: they are consumed again after the restart.
+func (l *loop) close(deadline time.Time) {
+	if !within(deadline, func() {
+		l.current.close(l.sugar)
+		l.reloader.retiring.Wait()
+	}) {
+		l.sugar.Warnw("The exporters did not flush before the shutdown timeout, the offsets are not committed.", "deadline", deadline)
+		l.consumers.abandon()
+	}
+	if !within(deadline, l.consumers.close) {
+		l.sugar.Warnw("The consumers did not leave their groups before the shutdown timeout.", "deadline", deadline)
+	}
+}
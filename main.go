@@ -2,25 +2,43 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/aliyun-sls/zipkin-ingester/admin"
 	"github.com/aliyun-sls/zipkin-ingester/configure"
 	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/credentials"
 	"github.com/aliyun-sls/zipkin-ingester/exporter"
+	"github.com/aliyun-sls/zipkin-ingester/logging"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
 	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"github.com/aliyun-sls/zipkin-ingester/telemetry"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// commitInterval is how often the offsets of the messages whose spans are exported are committed.
+	commitInterval = 5 * time.Second
+	// maxLoggedPayload bounds the bytes of a message payload logged in hex.
+	maxLoggedPayload = 256
 )
 
 var (
 	bootstrapServers string
 	consumerGroup    string
 	topic            string
+	assignor         string
 	project          string
 	instance         string
 	accessKey        string
@@ -28,6 +46,69 @@ var (
 	endpoint         string
 	audit            bool
 	protocol         string
+
+	kafkaSecurityProtocol string
+	kafkaSaslMechanism    string
+	kafkaSaslUsername     string
+	kafkaSaslPassword     string
+	kafkaSslCaLocation    string
+	spanMetrics           bool
+	spanMetricsStore      string
+	spanMetricsInterval   time.Duration
+	spanMetricsDimensions string
+	spanMetricsMaxSeries  int
+	selfMetrics           bool
+	selfTracing           bool
+	selfTracingEndpoint   string
+	selfTracingInsecure   bool
+	selfTracingService    string
+	selfTracingSampling   float64
+	timestampPolicy       string
+	clockSkew             bool
+	clockSkewWindow       time.Duration
+	clockSkewMaxTraces    int
+	exporters             string
+	zipkinURL             string
+	zipkinEncoding        string
+	zipkinTimeout         time.Duration
+	provisionLogstore     bool
+	logstoreTTL           int
+	logstoreShards        int
+	configFile            string
+	shutdownTimeout       time.Duration
+	adminAddr             string
+	adminToken            string
+	credentialProvider    string
+	stsTokenFile          string
+	ramRole               string
+	metadataEndpoint      string
+	traceSummary          bool
+	traceSummaryStore     string
+	traceSummaryWindow    time.Duration
+	traceSummaryMaxTraces int
+	dedup                 bool
+	dedupWindow           time.Duration
+	dedupMaxEntries       int
+	spanMaxTagCount       int
+	spanMaxTagValueLength int
+	spanMaxAnnotations    int
+	spanMaxSize           int
+	rateLimitSpans        float64
+	rateLimitBytes        float64
+	rateLimitServiceSpans float64
+	rateLimitServiceBytes float64
+	rateLimitAction       string
+	logLevelName          string
+	logFormat             string
+	logFile               string
+	logMaxSize            int
+	logMaxBackups         int
+	logMaxAge             int
+	logSampleInitial      int
+	logSampleThereafter   int
+	reloadOnChange        bool
+
+	logLevel = zap.NewAtomicLevel()
 )
 
 func init() {
@@ -39,67 +120,299 @@ func init() {
 	flag.StringVar(&bootstrapServers, "kafka_bootstrap_services", os.Getenv("BOOTSTRAP_SERVICE"), "The bootstrap services")
 	flag.StringVar(&consumerGroup, "kafka_consumer_group", os.Getenv("CONSUMER_GROUP"), "The consumer group")
 	flag.StringVar(&topic, "kafka_topic", os.Getenv("TOPIC"), "The kafka topic")
+	flag.StringVar(&assignor, "kafka_assignor", os.Getenv("KAFKA_ASSIGNOR"), "The partition assignment strategy, e.g. range, roundrobin or cooperative-sticky")
+	flag.StringVar(&kafkaSecurityProtocol, "kafka_security_protocol", os.Getenv("KAFKA_SECURITY_PROTOCOL"), "The security protocol of the kafka consumer, e.g. SASL_SSL")
+	flag.StringVar(&kafkaSaslMechanism, "kafka_sasl_mechanism", os.Getenv("KAFKA_SASL_MECHANISM"), "The SASL mechanism, e.g. PLAIN or SCRAM-SHA-256")
+	flag.StringVar(&kafkaSaslUsername, "kafka_sasl_username", os.Getenv("KAFKA_SASL_USERNAME"), "The SASL username")
+	flag.StringVar(&kafkaSaslPassword, "kafka_sasl_password", os.Getenv("KAFKA_SASL_PASSWORD"), "The SASL password")
+	flag.StringVar(&kafkaSslCaLocation, "kafka_ssl_ca_location", os.Getenv("KAFKA_SSL_CA_LOCATION"), "The CA certificate file verifying the brokers")
 	flag.StringVar(&protocol, "protocol", os.Getenv("PROTOCOL"), "protocol")
-	flag.Parse()
+	flag.BoolVar(&spanMetrics, "span_metrics", getEnvBool("SPAN_METRICS"), "Generate RED metrics from the ingested spans")
+	flag.StringVar(&spanMetricsStore, "span_metrics_store", os.Getenv("SPAN_METRICS_STORE"), "The metric store of span metrics, default <instance>-metrics")
+	flag.DurationVar(&spanMetricsInterval, "span_metrics_interval", getEnvDuration("SPAN_METRICS_INTERVAL", 15*time.Second), "The flush interval of span metrics")
+	flag.StringVar(&spanMetricsDimensions, "span_metrics_dimensions", os.Getenv("SPAN_METRICS_DIMENSIONS"), "The span tags added as span metrics labels, separated by comma")
+	flag.IntVar(&spanMetricsMaxSeries, "span_metrics_max_series", getEnvInt("SPAN_METRICS_MAX_SERIES", 10000), "The max series count of span metrics")
+	flag.BoolVar(&selfMetrics, "self_metrics", getEnvBool("SELF_METRICS"), "Write the ingester metrics to the metric store")
+	flag.BoolVar(&selfTracing, "self_tracing", getEnvBool("SELF_TRACING"), "Trace the poll, decode, convert and export stages of the ingester itself")
+	flag.StringVar(&selfTracingEndpoint, "self_tracing_endpoint", os.Getenv("SELF_TRACING_ENDPOINT"), "The OTLP gRPC endpoint of the self tracing, the trace instance if empty")
+	flag.BoolVar(&selfTracingInsecure, "self_tracing_insecure", getEnvBool("SELF_TRACING_INSECURE"), "Connect to the self tracing endpoint without TLS")
+	flag.StringVar(&selfTracingService, "self_tracing_service", os.Getenv("SELF_TRACING_SERVICE"), "The service name of the self tracing, default "+telemetry.DefaultServiceName)
+	flag.Float64Var(&selfTracingSampling, "self_tracing_sampling", getEnvFloat("SELF_TRACING_SAMPLING", telemetry.DefaultSampleRatio), "The ratio of the messages traced by the self tracing")
+	flag.StringVar(&timestampPolicy, "timestamp_policy", os.Getenv("TIMESTAMP_POLICY"), "The policy of spans without timestamp: drop, kafka or annotation")
+	flag.BoolVar(&clockSkew, "clock_skew", getEnvBool("CLOCK_SKEW"), "Correct the clock skew between client and server spans")
+	flag.DurationVar(&clockSkewWindow, "clock_skew_window", getEnvDuration("CLOCK_SKEW_WINDOW", 10*time.Second), "How long the spans of a trace are buffered for the clock skew correction")
+	flag.IntVar(&clockSkewMaxTraces, "clock_skew_max_traces", getEnvInt("CLOCK_SKEW_MAX_TRACES", 100000), "The max count of traces buffered for the clock skew correction")
+	flag.StringVar(&exporters, "exporters", os.Getenv("EXPORTERS"), "The exporters the spans are sent to, separated by comma: sls, zipkin")
+	flag.StringVar(&zipkinURL, "zipkin_url", os.Getenv("ZIPKIN_URL"), "The zipkin endpoint, e.g. http://localhost:9411/api/v2/spans")
+	flag.StringVar(&zipkinEncoding, "zipkin_encoding", os.Getenv("ZIPKIN_ENCODING"), "The encoding of the spans sent to zipkin: json or proto3")
+	flag.DurationVar(&zipkinTimeout, "zipkin_timeout", getEnvDuration("ZIPKIN_TIMEOUT", 5*time.Second), "The timeout of the requests to zipkin")
+	flag.BoolVar(&provisionLogstore, "provision_logstore", getEnvBool("PROVISION_LOGSTORE"), "Create the trace logstore and its index if missing")
+	flag.IntVar(&logstoreTTL, "logstore_ttl", getEnvInt("LOGSTORE_TTL", 30), "The TTL in days of the created trace logstore")
+	flag.IntVar(&logstoreShards, "logstore_shards", getEnvInt("LOGSTORE_SHARDS", 2), "The shard count of the created trace logstore")
+	flag.StringVar(&credentialProvider, "credential_provider", os.Getenv("CREDENTIAL_PROVIDER"), "The credential provider: static, sts_file or ecs_ram_role")
+	flag.StringVar(&stsTokenFile, "sts_token_file", os.Getenv("STS_TOKEN_FILE"), "The STS token file read by the sts_file credential provider")
+	flag.StringVar(&ramRole, "ram_role", os.Getenv("RAM_ROLE"), "The RAM role of the ecs_ram_role credential provider, discovered if empty")
+	flag.StringVar(&metadataEndpoint, "metadata_endpoint", os.Getenv("METADATA_ENDPOINT"), "The metadata service endpoint of the ecs_ram_role credential provider")
+	flag.DurationVar(&shutdownTimeout, "shutdown_timeout", getEnvDuration("SHUTDOWN_TIMEOUT", exporter.DefaultShutdownTimeout), "How long the exporters flush the pending spans at shutdown")
+	flag.StringVar(&adminAddr, "admin_addr", os.Getenv("ADMIN_ADDR"), "The listen address of the admin API, e.g. 127.0.0.1:8088, disabled if empty")
+	flag.StringVar(&adminToken, "admin_token", os.Getenv("ADMIN_TOKEN"), "The bearer token required by the admin API")
+	flag.BoolVar(&traceSummary, "trace_summary", getEnvBool("TRACE_SUMMARY"), "Write a summary of every trace to the trace summary logstore")
+	flag.StringVar(&traceSummaryStore, "trace_summary_store", os.Getenv("TRACE_SUMMARY_STORE"), "The logstore of the trace summaries, default <instance>-trace-summaries")
+	flag.DurationVar(&traceSummaryWindow, "trace_summary_window", getEnvDuration("TRACE_SUMMARY_WINDOW", 30*time.Second), "A trace is summarized once no span of it arrived for the window")
+	flag.IntVar(&traceSummaryMaxTraces, "trace_summary_max_traces", getEnvInt("TRACE_SUMMARY_MAX_TRACES", 100000), "The max count of traces assembled at the same time")
+	flag.BoolVar(&dedup, "dedup", getEnvBool("DEDUP"), "Drop the spans already received within the dedup window")
+	flag.DurationVar(&dedupWindow, "dedup_window", getEnvDuration("DEDUP_WINDOW", 10*time.Minute), "How long the received spans are remembered for the de-duplication")
+	flag.IntVar(&dedupMaxEntries, "dedup_max_entries", getEnvInt("DEDUP_MAX_ENTRIES", 1000000), "The max count of spans remembered for the de-duplication")
+	flag.IntVar(&spanMaxTagCount, "span_max_tag_count", getEnvInt("SPAN_MAX_TAG_COUNT", 0), "The max tag count of a span, 0 means unlimited")
+	flag.IntVar(&spanMaxTagValueLength, "span_max_tag_value_length", getEnvInt("SPAN_MAX_TAG_VALUE_LENGTH", 0), "The max length of the tag and annotation values, 0 means unlimited")
+	flag.IntVar(&spanMaxAnnotations, "span_max_annotation_count", getEnvInt("SPAN_MAX_ANNOTATION_COUNT", 0), "The max annotation count of a span, 0 means unlimited")
+	flag.IntVar(&spanMaxSize, "span_max_size", getEnvInt("SPAN_MAX_SIZE", 0), "The max serialized size of a span in bytes, 0 means unlimited")
+	flag.Float64Var(&rateLimitSpans, "rate_limit_spans", getEnvFloat("RATE_LIMIT_SPANS", 0), "The max spans per second sent to the exporters, 0 means unlimited")
+	flag.Float64Var(&rateLimitBytes, "rate_limit_bytes", getEnvFloat("RATE_LIMIT_BYTES", 0), "The max bytes per second sent to the exporters, 0 means unlimited")
+	flag.Float64Var(&rateLimitServiceSpans, "rate_limit_service_spans", getEnvFloat("RATE_LIMIT_SERVICE_SPANS", 0), "The max spans per second of each service, 0 means unlimited")
+	flag.Float64Var(&rateLimitServiceBytes, "rate_limit_service_bytes", getEnvFloat("RATE_LIMIT_SERVICE_BYTES", 0), "The max bytes per second of each service, 0 means unlimited")
+	flag.StringVar(&rateLimitAction, "rate_limit_action", os.Getenv("RATE_LIMIT_ACTION"), "The action on rate limit: drop or block")
+	flag.StringVar(&logLevelName, "log_level", os.Getenv("LOG_LEVEL"), "The log level: debug, info, warn or error")
+	flag.StringVar(&logFormat, "log_format", os.Getenv("LOG_FORMAT"), "The log format: json or console")
+	flag.StringVar(&logFile, "log_file", os.Getenv("LOG_FILE"), "The log file, the logs go to stdout if empty")
+	flag.IntVar(&logMaxSize, "log_max_size", getEnvInt("LOG_MAX_SIZE", 100), "The size in megabytes at which the log file is rotated")
+	flag.IntVar(&logMaxBackups, "log_max_backups", getEnvInt("LOG_MAX_BACKUPS", 5), "The count of rotated log files kept, 0 keeps all of them")
+	flag.IntVar(&logMaxAge, "log_max_age", getEnvInt("LOG_MAX_AGE", 7), "The days the rotated log files are kept, 0 keeps them forever")
+	flag.IntVar(&logSampleInitial, "log_sample_initial", getEnvInt("LOG_SAMPLE_INITIAL", 100), "The entries with the same message logged every second before sampling, 0 disables the sampling")
+	flag.IntVar(&logSampleThereafter, "log_sample_thereafter", getEnvInt("LOG_SAMPLE_THEREAFTER", 100), "Past the initial entries, only one in this many is logged")
+	flag.BoolVar(&reloadOnChange, "reload_on_change", getEnvBool("RELOAD_ON_CHANGE"), "Reload the configuration file when it changes, it is always reloaded on SIGHUP")
+	flag.StringVar(&configFile, "config", os.Getenv("CONFIG_FILE"), "The configuration file, its values take precedence over the flags")
 }
 
 func main() {
-	logger, _ := zap.NewProduction()
+	flag.Parse()
+	if flag.Arg(0) == "loadgen" {
+		os.Exit(runLoadgen(flag.Args()[1:]))
+	}
+
+	config := readConfiguration()
+	setLogLevel(config.LogLevel)
+	logger, err := logging.New(config.LoggingConfig(), logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to init the logger: %v\n", err)
+		os.Exit(1)
+	}
 	defer logger.Sync()
 	sugar := logger.Sugar()
+	logConfiguration(config, sugar)
 	audit = getAuditMode()
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	reloads := make(chan struct{}, 1)
+	hupchan := make(chan os.Signal, 1)
+	signal.Notify(hupchan, syscall.SIGHUP)
 
-	var ingest receiver.Ingester
-	var zipkinClient exporter.ZipkinDataExporter
-	var err error
+	var current *pipeline
 	run := true
 
-	config := readConfiguration(sugar)
+	if config.ProvisionLogstore && config.ExportsTo(exporter.ExporterSLS) {
+		provisionLogstores(config, sugar)
+	}
 
-	if zipkinClient, err = exporter.NewSdkProducerExporter(config); err != nil {
+	if current, err = newPipeline(config, nil, sugar); err != nil {
 		sugar.Errorw("Failed to connection sls backend", "exception", err)
 		os.Exit(1)
 	}
 
-	if ingest, err = receiver.NewIngester(config, sugar); err != nil {
-		sugar.Error("Failed to init kafka.", "exception", err)
+	consumers, err := startSources(config, sugar)
+	if err != nil {
+		sugar.Errorw("Failed to init kafka.", "exception", err)
 		os.Exit(1)
 	}
 
-	defer ingest.Close()
-	defer zipkinClient.Close()
+	var reporter *metrics.Reporter
+	if config.SelfMetricsEnabled {
+		if metricExporter, err := exporter.NewMetricStoreExporter(config, sugar); err != nil {
+			sugar.Warnw("Failed to init self metrics exporter", "exception", err)
+		} else {
+			reporter = metrics.NewReporter(metricExporter, config.SpanMetricsInterval, sugar)
+		}
+	}
+
+	tracer, err := telemetry.New(config)
+	if err != nil {
+		sugar.Errorw("Failed to init the self tracing", "exception", err)
+		os.Exit(1)
+	}
+
+	var adminServer *admin.Server
+	if config.AdminAddr != "" {
+		adminServer = admin.NewServer(config, consumers.controller(), sugar)
+		if err := adminServer.Start(); err != nil {
+			sugar.Errorw("Failed to start the admin API", "exception", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.ReloadOnChange && configFile != "" {
+		watch(configFile, reloads)
+	}
 
-	converter := converter.NewConverter(config.Protocol)
+	reloader := &reloader{sugar: sugar, admin: adminServer}
+	consuming := newLoop(current, consumers, reloader, tracer, sugar)
+	commits := &committer{sources: consumers, retiring: reloader.flushing}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	commitTicker := time.NewTicker(commitInterval)
+	defer commitTicker.Stop()
 	for run {
 		select {
 		case sig := <-sigchan:
-			fmt.Printf("Caught signal %v: terminating\n", sig)
+			sugar.Infow("Caught signal, stop polling.", "signal", sig)
 			run = false
-		default:
-			data, e := ingest.IngestTrace(sugar)
-
-			if e == nil && audit {
-				if spans, e1 := converter.ParseSpans(data, false); e1 != nil {
-					sugar.Warnw("Failed to parse spans ", "Exception", e1, "originData", hex.EncodeToString(data))
-				} else {
-					for _, span := range spans {
-						sugar.Infow("Receive Span", "TraceID", span.TraceID, "SpanID", span.ID, "parentSpanID", span.ParentID, "name", span.Name, "originData", hex.EncodeToString(data))
-					}
-				}
-			}
+		case <-hupchan:
+			consuming.reload()
+		case <-reloads:
+			consuming.reload()
+		case msg := <-consumers.messages:
+			consuming.handle(msg)
+		case done := <-consumers.drains:
+			consuming.drain()
+			close(done)
+		case <-ticker.C:
+			consuming.tick()
+		case now := <-consuming.throttler.expired():
+			consuming.throttler.resume(now)
+		case <-commitTicker.C:
+			commits.tick(consuming.current)
+		}
+	}
+	consuming.stop()
 
-			if len(data) == 0 || e != nil {
-				continue
-			}
+	if adminServer != nil {
+		adminServer.Close()
+	}
+	shutdown(consuming, reporter, tracer, sigchan, sugar)
+}
 
-			if err := zipkinClient.SendZipkinData(converter, data); err != nil {
-				sugar.Warnw("Failed to send zipking data", "Exception", err, "data", hex.EncodeToString(data))
-			}
+// shutdown releases the buffered spans, flushes the exporters, and only then
+// commits the offsets and leaves the consumer group. A second signal aborts it.
+// All the steps share the shutdown timeout, a step still running at the
+// deadline is abandoned and the next ones only get what is left of it.
+func shutdown(consuming *loop, reporter *metrics.Reporter, tracer *telemetry.Tracer, sigchan chan os.Signal, sugar *zap.SugaredLogger) {
+	start := time.Now()
+	timeout := consuming.current.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = exporter.DefaultShutdownTimeout
+	}
+	deadline := start.Add(timeout)
+	go func() {
+		sig := <-sigchan
+		sugar.Warnw("Caught signal again, exit without flushing.", "signal", sig, "stats", exporter.SpanDeliveryStats())
+		os.Exit(1)
+	}()
+
+	sugar.Infow("Flushing the exporters.", "timeout", timeout)
+	consuming.close(deadline)
+
+	stats := exporter.SpanDeliveryStats()
+	sugar.Infow("Shutdown completed.",
+		"spansFlushed", stats.Flushed,
+		"spansFailed", stats.Failed,
+		"spansAbandoned", stats.Abandoned,
+		"duplicateRate", processor.DuplicateRate(),
+		"elapsed", time.Since(start),
+	)
+	if reporter != nil && !within(deadline, reporter.Close) {
+		sugar.Warnw("The self metrics were not reported before the shutdown timeout.", "timeout", timeout)
+	}
+	if err := tracer.Close(time.Until(deadline)); err != nil {
+		sugar.Warnw("Failed to flush the self tracing", "exception", err)
+	}
+}
+
+// within runs the step until the deadline, it tells whether the step completed.
+// A step which did not is left running in the background.
+func within(deadline time.Time, step func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		step()
+	}()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// setLogLevel applies the validated log level of the configuration.
+func setLogLevel(level string) {
+	_ = logLevel.UnmarshalText([]byte(level))
+}
+
+func provisionLogstores(config *configure.Configuration, sugar *zap.SugaredLogger) {
+	targets := []*configure.Configuration{config}
+	for _, route := range config.Routes {
+		targets = append(targets, config.ForRoute(route))
+	}
+
+	for _, target := range targets {
+		if err := exporter.ProvisionTraceLogstore(target, sugar); err != nil {
+			sugar.Errorw("Failed to provision the trace logstore", "project", target.Project, "logstore", target.TraceLogstore(), "exception", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.TraceSummaryEnabled {
+		if err := exporter.ProvisionTraceSummaryLogstore(config, sugar); err != nil {
+			sugar.Errorw("Failed to provision the trace summary logstore", "project", config.Project, "logstore", config.TraceSummaryLogstore(), "exception", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func parseMessage(c *converter.TimestampConverter, msg *receiver.Message, sugar *zap.SugaredLogger) (*processor.Batch, error) {
+	payload, err := converter.Decompress(msg.Value, converter.ContentEncoding(msg.Headers))
+	if err != nil {
+		sugar.Warnw("Failed to decompress spans ", "Exception", err, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+		return nil, err
+	}
+	spans, err := c.ParseMessage(payload, false, msg.Timestamp)
+	var partial *converter.PartialError
+	if errors.As(err, &partial) && len(spans) > 0 {
+		for _, spanErr := range partial.Spans {
+			sugar.Warnw("Failed to parse span ", "Exception", spanErr.Err, "index", spanErr.Index, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
 		}
+	} else if err != nil {
+		sugar.Warnw("Failed to parse spans ", "Exception", err, "originData", payloadPrefix(msg.Value), "originSize", len(msg.Value))
+		return nil, err
+	}
+
+	if audit {
+		for _, span := range spans {
+			sugar.Infow("Receive Span", "TraceID", span.TraceID, "SpanID", span.ID, "parentSpanID", span.ParentID, "name", span.Name, "originData", payloadPrefix(msg.Value), "originSize", len(msg.Value))
+		}
+	}
+	return &processor.Batch{Topic: msg.Topic, Partition: msg.Partition, Spans: spans}, nil
+}
+
+// payloadPrefix encodes the beginning of a payload for the logs, a message may be megabytes large.
+func payloadPrefix(value []byte) string {
+	if len(value) > maxLoggedPayload {
+		value = value[:maxLoggedPayload]
+	}
+	return hex.EncodeToString(value)
+}
+
+func exportBatch(zipkinClient *exporter.RoutingExporter, batch *processor.Batch, sugar *zap.SugaredLogger) error {
+	if batch == nil || len(batch.Spans) == 0 {
+		return nil
+	}
+	err := zipkinClient.SendRouteData(batch.Route, batch.Topic, batch.Spans)
+	if err != nil {
+		sugar.Warnw("Failed to send zipking data", "Exception", err, "topic", batch.Topic, "route", batch.Route, "spans", len(batch.Spans))
 	}
+	return err
 }
 
 func getAuditMode() bool {
@@ -109,7 +422,78 @@ func getAuditMode() bool {
 		return auditMode
 	}
 }
-func readConfiguration(sugared *zap.SugaredLogger) *configure.Configuration {
+
+func getEnvBool(key string) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err != nil {
+		return false
+	} else {
+		return value
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err != nil {
+		return defaultValue
+	} else {
+		return value
+	}
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err != nil {
+		return defaultValue
+	} else {
+		return value
+	}
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err != nil {
+		return defaultValue
+	} else {
+		return value
+	}
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// readConfiguration loads the configuration before the logger exists, so it
+// reports an invalid configuration on stderr.
+func readConfiguration() *configure.Configuration {
+	config, err := loadConfiguration()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The configuration is invalid: %v\n", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+func logConfiguration(config *configure.Configuration, sugared *zap.SugaredLogger) {
+	sugared.Infow("Configuration:",
+		"BootstrapServers", config.BootstrapServers,
+		"Topic", config.Topic,
+		"Sources", len(config.Sources),
+		"Project", config.Project,
+		"Instance", config.Instance,
+		"CredentialProvider", config.CredentialProvider,
+		"Endpoint", config.Endpoint,
+		"Protocol", config.Protocol,
+		"TimestampPolicy", config.TimestampPolicy,
+		"SpanMetricsEnabled", config.SpanMetricsEnabled,
+		"Routes", len(config.Routes),
+		"LogFormat", config.LogFormat,
+		"LogFile", config.LogFile,
+	)
+}
+
+// loadConfiguration reads the flags and the configuration file, it is called
+// again on every reload.
+func loadConfiguration() (*configure.Configuration, error) {
 	config := &configure.Configuration{
 		BootstrapServers: bootstrapServers,
 		GroupID: func(consumerGroup string) string {
@@ -120,65 +504,234 @@ func readConfiguration(sugared *zap.SugaredLogger) *configure.Configuration {
 		}(consumerGroup),
 		AutoOffsetRest: "latest",
 		Topic:          strings.Split(topic, ","),
+		Assignor:       assignor,
 		Project:        project,
 		Instance:       instance,
 		AccessKey:      accessKey,
 		AccessSecret:   accessSecret,
 		Endpoint:       endpoint,
 		Protocol:       protocol,
+
+		SecurityProtocol: kafkaSecurityProtocol,
+		SaslMechanism:    kafkaSaslMechanism,
+		SaslUsername:     kafkaSaslUsername,
+		SaslPassword:     kafkaSaslPassword,
+		SslCaLocation:    kafkaSslCaLocation,
+
+		SpanMetricsEnabled:    spanMetrics,
+		SpanMetricsStore:      spanMetricsStore,
+		SpanMetricsInterval:   spanMetricsInterval,
+		SpanMetricsDimensions: splitList(spanMetricsDimensions),
+		SpanMetricsMaxSeries:  spanMetricsMaxSeries,
+		SelfMetricsEnabled:    selfMetrics,
+		SelfTracingEnabled:    selfTracing,
+		SelfTracingEndpoint:   selfTracingEndpoint,
+		SelfTracingInsecure:   selfTracingInsecure,
+		SelfTracingService:    selfTracingService,
+		SelfTracingSampling:   selfTracingSampling,
+		TimestampPolicy:       timestampPolicy,
+		ClockSkewEnabled:      clockSkew,
+		ClockSkewWindow:       clockSkewWindow,
+		ClockSkewMaxTraces:    clockSkewMaxTraces,
+		Exporters:             splitList(exporters),
+		ZipkinURL:             zipkinURL,
+		ZipkinEncoding:        zipkinEncoding,
+		ZipkinTimeout:         zipkinTimeout,
+		ProvisionLogstore:     provisionLogstore,
+		LogstoreTTL:           logstoreTTL,
+		LogstoreShards:        logstoreShards,
+		CredentialProvider:    credentialProvider,
+		StsTokenFile:          stsTokenFile,
+		RamRole:               ramRole,
+		MetadataEndpoint:      metadataEndpoint,
+		ShutdownTimeout:       shutdownTimeout,
+		AdminAddr:             adminAddr,
+		AdminToken:            adminToken,
+		TraceSummaryEnabled:   traceSummary,
+		TraceSummaryStore:     traceSummaryStore,
+		TraceSummaryWindow:    traceSummaryWindow,
+		TraceSummaryMaxTraces: traceSummaryMaxTraces,
+		DedupEnabled:          dedup,
+		DedupWindow:           dedupWindow,
+		DedupMaxEntries:       dedupMaxEntries,
+		SpanMaxTagCount:       spanMaxTagCount,
+		SpanMaxTagValueLength: spanMaxTagValueLength,
+		SpanMaxAnnotations:    spanMaxAnnotations,
+		SpanMaxSize:           spanMaxSize,
+		RateLimitSpans:        rateLimitSpans,
+		RateLimitBytes:        rateLimitBytes,
+		RateLimitServiceSpans: rateLimitServiceSpans,
+		RateLimitServiceBytes: rateLimitServiceBytes,
+		RateLimitAction:       rateLimitAction,
+		LogLevel:              logLevelName,
+		LogFormat:             logFormat,
+		LogFile:               logFile,
+		LogMaxSize:            logMaxSize,
+		LogMaxBackups:         logMaxBackups,
+		LogMaxAge:             logMaxAge,
+		LogSampleInitial:      logSampleInitial,
+		LogSampleThereafter:   logSampleThereafter,
+		ReloadOnChange:        reloadOnChange,
 	}
 
-	checkParameters(sugared, config)
+	if configFile != "" {
+		fileConfig, err := configure.Load(configFile, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the configuration file %s: %w", configFile, err)
+		}
+		config = fileConfig
+	}
 
-	sugared.Infow("Configuration:",
-		"BootstrapServers", bootstrapServers,
-		"Topic", config.Topic,
-		"Project", config.Project,
-		"Instance", config.Instance,
-		"AccessKey", config.AccessKey,
-		"Endpoint", config.Endpoint,
-		"Protocol", config.Protocol,
-	)
-	return config
+	if err := checkParameters(config); err != nil {
+		return nil, err
+	}
+
+	provider, err := credentials.NewProvider(config.CredentialsConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to init the credential provider: %w", err)
+	}
+	config.Credentials = provider
+	return config, nil
 }
 
-func checkParameters(sugared *zap.SugaredLogger, config *configure.Configuration) {
-	if config.BootstrapServers == "" {
-		sugared.Warn("The bootstrap servers is empty.")
-		panic("The bootstrap servers is empty")
+// checkRoutes validates the routes, they are referred to by their name which
+// must be unique, and their access key comes with its secret.
+func checkRoutes(config *configure.Configuration) error {
+	names := make(map[string]bool)
+	for _, route := range config.Routes {
+		if route.Name == "" {
+			return errors.New("the route name is empty")
+		}
+		if route.Name == exporter.DefaultRouteName {
+			return fmt.Errorf("the route name %s is reserved for the top level configuration", route.Name)
+		}
+		if names[route.Name] {
+			return fmt.Errorf("the route %s is defined twice", route.Name)
+		}
+		names[route.Name] = true
+
+		if (route.AccessKey == "") != (route.AccessSecret == "") {
+			return fmt.Errorf("the access key and the access secret of the route %s must be set together", route.Name)
+		}
 	}
+	return nil
+}
 
-	if len(config.Topic) == 0 {
-		sugared.Warn("The topic is empty.")
-		panic("The topic is empty.")
+// checkSources validates the effective sources, their route must be a configured route.
+func checkSources(config *configure.Configuration) error {
+	routes := map[string]bool{exporter.DefaultRouteName: true}
+	for _, route := range config.Routes {
+		routes[route.Name] = true
 	}
+	names := make(map[string]bool)
+	for _, source := range config.EffectiveSources() {
+		if source.Name == "" {
+			return errors.New("the source name is empty")
+		}
+		if names[source.Name] {
+			return fmt.Errorf("the source %s is defined twice", source.Name)
+		}
+		names[source.Name] = true
 
-	if config.Project == "" {
-		sugared.Warn("The project is empty.")
-		panic("The project is empty.")
+		if source.BootstrapServers == "" {
+			return fmt.Errorf("the bootstrap servers of the source %s is empty", source.Name)
+		}
+		if len(strings.Join(source.Topics, "")) == 0 && source.TopicPattern == "" {
+			return fmt.Errorf("the topic of the source %s is empty", source.Name)
+		}
+		if source.TopicPattern != "" {
+			if _, err := regexp.Compile(source.TopicPattern); err != nil {
+				return fmt.Errorf("the topic pattern of the source %s is invalid: %w", source.Name, err)
+			}
+		}
+		if source.Route != "" && !routes[source.Route] {
+			return fmt.Errorf("the route %s of the source %s is not defined", source.Route, source.Name)
+		}
 	}
+	return nil
+}
 
-	if config.Instance == "" {
-		sugared.Warn("The instance is empty.")
-		panic("The instance is empty.")
+func checkParameters(config *configure.Configuration) error {
+	if config.Protocol == "" {
+		config.Protocol = "protobuf"
 	}
 
-	if config.AccessKey == "" {
-		sugared.Warn("The access key is empty.")
-		panic("The access key is empty")
+	if err := checkRoutes(config); err != nil {
+		return err
 	}
 
-	if config.AccessSecret == "" {
-		sugared.Warn("The access secret is empty.")
-		panic("The access secret is empty")
+	if err := checkSources(config); err != nil {
+		return err
 	}
 
-	if config.Endpoint == "" {
-		sugared.Warn("The endpoint is empty.")
-		panic("The endpoint is empty")
+	if policy, err := converter.ParseTimestampPolicy(config.TimestampPolicy); err != nil {
+		return err
+	} else {
+		config.TimestampPolicy = string(policy)
 	}
 
-	if config.Protocol == "" {
-		config.Protocol = "protobuf"
+	if action, err := processor.ParseRateLimitAction(config.RateLimitAction); err != nil {
+		return err
+	} else {
+		config.RateLimitAction = action
+	}
+
+	if err := processor.CheckSpanMetricsDimensions(config.SpanMetricsDimensions); err != nil {
+		return err
+	}
+
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return fmt.Errorf("the log level is invalid: %w", err)
+	}
+	if format := strings.ToLower(config.LogFormat); format != "" && format != logging.FormatJSON && format != logging.FormatConsole {
+		return fmt.Errorf("unknown log format %s, expected json or console", config.LogFormat)
+	}
+
+	if config.SpanMaxSize != 0 && config.SpanMaxSize < converter.MinSpanSize {
+		return fmt.Errorf("the span max size %d is smaller than %d bytes", config.SpanMaxSize, converter.MinSpanSize)
+	}
+
+	if config.AdminAddr != "" && config.AdminToken == "" {
+		return errors.New("the admin token is required by the admin API")
+	}
+
+	if config.ExportsTo(exporter.ExporterZipkin) && config.ZipkinURL == "" {
+		return errors.New("the zipkin url is empty")
+	}
+
+	if config.SelfTracingSampling < 0 || config.SelfTracingSampling > 1 {
+		return fmt.Errorf("the self tracing sampling %v is not between 0 and 1", config.SelfTracingSampling)
+	}
+
+	selfTracingToSls := config.SelfTracingEnabled && config.SelfTracingEndpoint == ""
+	if !config.ExportsTo(exporter.ExporterSLS) && !config.SpanMetricsEnabled && !config.SelfMetricsEnabled && !config.TraceSummaryEnabled && !selfTracingToSls {
+		return nil
+	}
+
+	if config.Project == "" {
+		return errors.New("the project is empty")
+	}
+
+	if config.Instance == "" {
+		return errors.New("the instance is empty")
+	}
+
+	if config.CredentialProvider == "" || strings.EqualFold(config.CredentialProvider, credentials.ProviderStatic) {
+		if config.AccessKey == "" {
+			return errors.New("the access key is empty")
+		}
+
+		if config.AccessSecret == "" {
+			return errors.New("the access secret is empty")
+		}
+	}
+
+	if config.Endpoint == "" {
+		return errors.New("the endpoint is empty")
 	}
+	return nil
 }
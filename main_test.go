@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+)
+
+func TestCheckRoutes(t *testing.T) {
+	cases := []struct {
+		routes []configure.Route
+		err    string
+	}{
+		{[]configure.Route{{Name: "prod", AccessKey: "id", AccessSecret: "secret"}, {Name: "orders"}}, ""},
+		{[]configure.Route{{Project: "prod-project"}}, "the route name is empty"},
+		{[]configure.Route{{Name: "default"}}, "reserved"},
+		{[]configure.Route{{Name: "prod"}, {Name: "prod"}}, "the route prod is defined twice"},
+		{[]configure.Route{{Name: "prod", AccessKey: "id"}}, "must be set together"},
+		{[]configure.Route{{Name: "prod", AccessSecret: "secret"}}, "must be set together"},
+	}
+	for i, c := range cases {
+		err := checkRoutes(&configure.Configuration{Routes: c.routes})
+		if (c.err == "" && err != nil) || (c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err))) {
+			t.Errorf("Case %d: Expected %q, Actual: %v", i, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// Point is a single sample of a time series.
+type Point struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// SortedLabelNames returns the label names of the point in lexical order.
+func (p *Point) SortedLabelNames() []string {
+	names := make([]string, 0, len(p.Labels))
+	for name := range p.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Exporter interface {
+	ExportMetrics(points []*Point) error
+
+	Close()
+}
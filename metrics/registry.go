@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Counter is a monotonically increasing self-metric of the ingester.
+type Counter struct {
+	name   string
+	labels map[string]string
+	value  uint64
+}
+
+func (c *Counter) Add(delta uint64) {
+	atomic.AddUint64(&c.value, delta)
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+type registry struct {
+	lock     sync.Mutex
+	counters map[string]*Counter
+}
+
+var defaultRegistry = &registry{counters: make(map[string]*Counter)}
+
+// NewCounter returns the counter registered with the name and labels, creating it if absent.
+func NewCounter(name string, labels map[string]string) *Counter {
+	key := name + "\x00" + labelsKey(labels)
+
+	defaultRegistry.lock.Lock()
+	defer defaultRegistry.lock.Unlock()
+	if c, ok := defaultRegistry.counters[key]; ok {
+		return c
+	}
+	c := &Counter{name: name, labels: labels}
+	defaultRegistry.counters[key] = c
+	return c
+}
+
+// Collect snapshots all registered counters.
+func Collect(now time.Time) []*Point {
+	defaultRegistry.lock.Lock()
+	defer defaultRegistry.lock.Unlock()
+
+	points := make([]*Point, 0, len(defaultRegistry.counters))
+	for _, c := range defaultRegistry.counters {
+		points = append(points, &Point{
+			Name:   c.name,
+			Labels: c.labels,
+			Value:  float64(c.Value()),
+			Time:   now,
+		})
+	}
+	return points
+}
+
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(0)
+		sb.WriteString(labels[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// Reporter periodically exports the registered counters.
+type Reporter struct {
+	exporter Exporter
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	sugar    *zap.SugaredLogger
+}
+
+func NewReporter(exporter Exporter, interval time.Duration, sugar *zap.SugaredLogger) *Reporter {
+	r := &Reporter{
+		exporter: exporter,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		sugar:    sugar,
+	}
+	if r.interval <= 0 {
+		r.interval = 15 * time.Second
+	}
+	go r.run()
+	return r
+}
+
+func (r *Reporter) Close() {
+	close(r.stop)
+	<-r.done
+	r.exporter.Close()
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.report()
+		case <-r.stop:
+			r.report()
+			return
+		}
+	}
+}
+
+func (r *Reporter) report() {
+	points := Collect(time.Now())
+	if len(points) == 0 {
+		return
+	}
+	if err := r.exporter.ExportMetrics(points); err != nil {
+		r.sugar.Warnw("Failed to export self metrics", "exception", err, "points", len(points))
+	}
+}
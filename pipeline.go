@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/exporter"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"github.com/aliyun-sls/zipkin-ingester/telemetry"
+	"go.uber.org/zap"
+)
+
+// pipeline is the part of the ingester built from the configuration that can be
+// replaced while consuming: the converters, the processors and the exporters.
+type pipeline struct {
+	config *configure.Configuration
+	// sources and converters are keyed by the source name
+	sources    map[string]configure.Source
+	converters map[string]*converter.TimestampConverter
+	stages     []stage
+	processors processor.Chain
+	exporter   *exporter.RoutingExporter
+}
+
+// newPipeline builds a pipeline from the configuration, the processors of the
+// previous pipeline are kept if their settings did not change.
+func newPipeline(config *configure.Configuration, previous *pipeline, sugar *zap.SugaredLogger) (*pipeline, error) {
+	zipkinClient, err := exporter.NewRoutingExporter(config, exporter.NewExporters, sugar)
+	if err != nil {
+		return nil, err
+	}
+	var kept []stage
+	if previous != nil {
+		kept = previous.stages
+	}
+	stages := newProcessors(config, kept, sugar)
+	p := &pipeline{
+		config:     config,
+		sources:    make(map[string]configure.Source),
+		converters: make(map[string]*converter.TimestampConverter),
+		stages:     stages,
+		processors: chain(stages),
+		exporter:   zipkinClient,
+	}
+	for _, source := range config.EffectiveSources() {
+		p.sources[source.Name] = source
+		p.converters[source.Name] = converter.NewConverterWithPolicy(source.Protocol, converter.TimestampPolicy(config.TimestampPolicy))
+	}
+	return p, nil
+}
+
+func (p *pipeline) handle(msg receiver.SourceMessage, trace *telemetry.MessageTrace, sugar *zap.SugaredLogger) {
+	// a source removed from the configuration keeps being consumed until a restart
+	source, ok := p.sources[msg.Source.Name]
+	if !ok {
+		source = msg.Source.Source
+		p.sources[source.Name] = source
+		p.converters[source.Name] = converter.NewConverterWithPolicy(source.Protocol, converter.TimestampPolicy(p.config.TimestampPolicy))
+	}
+
+	decode := trace.Start(telemetry.StageDecode)
+	batch, err := parseMessage(p.converters[source.Name], msg.Message, sugar)
+	msg.Source.Decoded(batchSize(batch), err)
+	if err != nil {
+		decode.End(0, err)
+		return
+	}
+	decode.End(len(batch.Spans), nil)
+	batch.Source = source.Name
+	batch.Route = source.Route
+
+	process := trace.Start(telemetry.StageProcess)
+	batch = p.processors.Process(batch)
+	process.End(batchSize(batch), nil)
+
+	export := trace.Start(telemetry.StageExport)
+	export.End(batchSize(batch), exportBatch(p.exporter, batch, sugar))
+}
+
+func (p *pipeline) flush(force bool, sugar *zap.SugaredLogger) {
+	for _, batch := range p.processors.Flush(force) {
+		_ = exportBatch(p.exporter, batch, sugar)
+	}
+}
+
+// checkpoint tells, without waiting, whether the spans handled so far were
+// released by the processors and then delivered by the exporters.
+func (p *pipeline) checkpoint() func() bool {
+	released := p.processors.Checkpoint()
+	var delivered func() bool
+	return func() bool {
+		if delivered == nil {
+			if !released() {
+				return false
+			}
+			delivered = exporter.Checkpoint(p.exporter)
+		}
+		return delivered()
+	}
+}
+
+// drain exports the spans buffered by the processors and waits until the
+// exporters delivered them, so that the consumed offsets can be committed.
+func (p *pipeline) drain(sugar *zap.SugaredLogger) {
+	p.flush(true, sugar)
+	if err := p.exporter.Flush(p.config.ShutdownTimeout); err != nil {
+		sugar.Warnw("Failed to flush the exporters before the rebalance.", "exception", err)
+	}
+}
+
+// handOver makes the processors kept by the next pipeline pass-through in this
+// one, so that closing it neither flushes nor closes them.
+func (p *pipeline) handOver(next *pipeline) {
+	for i, s := range p.stages {
+		for _, kept := range next.stages {
+			if kept.processor == s.processor {
+				p.processors[i] = carried{s.processor}
+			}
+		}
+	}
+}
+
+// close exports the spans buffered by the processors and flushes the exporters.
+func (p *pipeline) close(sugar *zap.SugaredLogger) {
+	p.flush(true, sugar)
+	p.processors.Close()
+	p.exporter.Close()
+}
+
+func batchSize(batch *processor.Batch) int {
+	if batch == nil {
+		return 0
+	}
+	return len(batch.Spans)
+}
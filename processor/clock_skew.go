@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+type spanNode struct {
+	span     *zipkinmodel.SpanModel
+	parent   *spanNode
+	children []*spanNode
+}
+
+type clockSkew struct {
+	endpoint *zipkinmodel.Endpoint
+	skew     time.Duration
+}
+
+// CorrectClockSkew adjusts in place the timestamps of the spans of one trace
+// following the algorithm of the Zipkin UI: the server side of a RPC recorded on
+// another host is centered in its client span, assuming the network latency is
+// evenly split between request and response, and the same offset is applied to
+// the descendants of the server span recorded on the same host.
+func CorrectClockSkew(spans []*zipkinmodel.SpanModel) {
+	for _, root := range buildSpanTree(spans) {
+		adjustClockSkew(root, nil)
+	}
+}
+
+func buildSpanTree(spans []*zipkinmodel.SpanModel) []*spanNode {
+	nodes := make([]*spanNode, 0, len(spans))
+	byID := make(map[zipkinmodel.ID][]*spanNode, len(spans))
+	for _, span := range spans {
+		if span == nil {
+			continue
+		}
+		node := &spanNode{span: span}
+		nodes = append(nodes, node)
+		byID[span.ID] = append(byID[span.ID], node)
+	}
+
+	var roots []*spanNode
+	for _, node := range nodes {
+		if node.parent = findParent(node, byID); node.parent != nil {
+			node.parent.children = append(node.parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+func findParent(node *spanNode, byID map[zipkinmodel.ID][]*spanNode) *spanNode {
+	span := node.span
+	// The server side of a shared span is the child of the client side with the same ID.
+	if span.Shared {
+		for _, candidate := range byID[span.ID] {
+			if candidate != node && !candidate.span.Shared {
+				return candidate
+			}
+		}
+	}
+
+	if span.ParentID == nil || *span.ParentID == span.ID {
+		return nil
+	}
+	// The children of a shared span are recorded on the server side.
+	var parent *spanNode
+	for _, candidate := range byID[*span.ParentID] {
+		if parent == nil || candidate.span.Shared {
+			parent = candidate
+		}
+	}
+	return parent
+}
+
+func adjustClockSkew(node *spanNode, skewFromParent *clockSkew) {
+	if skewFromParent != nil {
+		adjustTimestamps(node.span, skewFromParent)
+	}
+
+	skew := getClockSkew(node)
+	if skew != nil {
+		adjustTimestamps(node.span, skew)
+	} else if skewFromParent != nil && isLocalSpan(node.span) {
+		skew = skewFromParent
+	}
+
+	for _, child := range node.children {
+		adjustClockSkew(child, skew)
+	}
+}
+
+func getClockSkew(node *spanNode) *clockSkew {
+	if node.parent == nil {
+		return nil
+	}
+	server, client := node.span, node.parent.span
+	if server.Kind != zipkinmodel.Server || client.Kind != zipkinmodel.Client {
+		return nil
+	}
+
+	serverEndpoint := server.LocalEndpoint
+	if serverEndpoint == nil || (serverEndpoint.IPv4 == nil && serverEndpoint.IPv6 == nil) {
+		return nil
+	}
+	// There's no skew if the RPC is going on the same host
+	if ipsMatch(client.LocalEndpoint, serverEndpoint) {
+		return nil
+	}
+
+	if server.Timestamp.IsZero() || client.Timestamp.IsZero() || server.Duration == 0 || client.Duration == 0 {
+		return nil
+	}
+	latency := (client.Duration - server.Duration) / 2
+	// We can't see skew when send happens before receive
+	if latency < 0 {
+		return nil
+	}
+
+	skew := server.Timestamp.Sub(client.Timestamp) - latency
+	if skew == 0 {
+		return nil
+	}
+	return &clockSkew{endpoint: serverEndpoint, skew: skew}
+}
+
+func adjustTimestamps(span *zipkinmodel.SpanModel, skew *clockSkew) {
+	if !ipsMatch(span.LocalEndpoint, skew.endpoint) {
+		return
+	}
+	if !span.Timestamp.IsZero() {
+		span.Timestamp = span.Timestamp.Add(-skew.skew)
+	}
+	for i := range span.Annotations {
+		span.Annotations[i].Timestamp = span.Annotations[i].Timestamp.Add(-skew.skew)
+	}
+}
+
+func isLocalSpan(span *zipkinmodel.SpanModel) bool {
+	return span.Kind == zipkinmodel.Undetermined && span.RemoteEndpoint == nil
+}
+
+func ipsMatch(a, b *zipkinmodel.Endpoint) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.IPv6 != nil && b.IPv6 != nil && a.IPv6.Equal(b.IPv6) {
+		return true
+	}
+	if a.IPv4 == nil || b.IPv4 == nil {
+		return false
+	}
+	return a.IPv4.Equal(b.IPv4)
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+type bufferedTrace struct {
+	// seq numbers the traces in the order they are buffered.
+	seq      uint64
+	deadline time.Time
+	origins  []batchOrigin
+	spans    []*zipkinmodel.SpanModel
+}
+
+// batchOrigin is the batch a buffered span came with.
+type batchOrigin struct {
+	origin Origin
+	route  string
+}
+
+// clockSkewProcessor buffers the spans of each trace for a window after its first
+// span arrives, then corrects the clock skew between its client and server spans.
+type clockSkewProcessor struct {
+	lock      sync.Mutex
+	window    time.Duration
+	maxTraces int
+	traces    map[zipkinmodel.TraceID]*bufferedTrace
+	order     []zipkinmodel.TraceID
+	buffered  uint64
+	now       func() time.Time
+}
+
+func NewClockSkewProcessor(config *configure.Configuration) Processor {
+	p := &clockSkewProcessor{
+		window:    config.ClockSkewWindow,
+		maxTraces: config.ClockSkewMaxTraces,
+		traces:    make(map[zipkinmodel.TraceID]*bufferedTrace),
+		now:       time.Now,
+	}
+	if p.window <= 0 {
+		p.window = 10 * time.Second
+	}
+	return p
+}
+
+func (p *clockSkewProcessor) Process(batch *Batch) *Batch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		trace, ok := p.traces[span.TraceID]
+		if !ok {
+			p.buffered++
+			trace = &bufferedTrace{seq: p.buffered, deadline: now.Add(p.window)}
+			p.traces[span.TraceID] = trace
+			p.order = append(p.order, span.TraceID)
+		}
+		trace.spans = append(trace.spans, span)
+		trace.origins = append(trace.origins, batchOrigin{origin: batch.Origin(), route: batch.Route})
+	}
+	return nil
+}
+
+func (p *clockSkewProcessor) Flush(force bool) []*Batch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	var batches []*Batch
+	byOrigin := make(map[batchOrigin]*Batch)
+	for len(p.order) > 0 {
+		traceID := p.order[0]
+		trace := p.traces[traceID]
+		if !force && now.Before(trace.deadline) && (p.maxTraces <= 0 || len(p.traces) <= p.maxTraces) {
+			break
+		}
+		p.order = p.order[1:]
+		delete(p.traces, traceID)
+
+		CorrectClockSkew(trace.spans)
+		for i, span := range trace.spans {
+			origin := trace.origins[i]
+			batch, ok := byOrigin[origin]
+			if !ok {
+				batch = &Batch{Source: origin.origin.Source, Topic: origin.origin.Topic, Partition: origin.origin.Partition, Route: origin.route}
+				byOrigin[origin] = batch
+				batches = append(batches, batch)
+			}
+			batch.Spans = append(batch.Spans, span)
+		}
+	}
+	return batches
+}
+
+// Checkpoint tells whether the traces buffered before it were released, the
+// traces are released in the order they are buffered.
+func (p *clockSkewProcessor) Checkpoint() func() bool {
+	p.lock.Lock()
+	last := p.buffered
+	p.lock.Unlock()
+	return func() bool {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		return len(p.order) == 0 || p.traces[p.order[0]].seq > last
+	}
+}
+
+func (p *clockSkewProcessor) Close() {
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func TestCorrectClockSkew(t *testing.T) {
+	frontend := &zipkinmodel.Endpoint{ServiceName: "frontend", IPv4: net.ParseIP("172.16.8.1")}
+	backend := &zipkinmodel.Endpoint{ServiceName: "backend", IPv4: net.ParseIP("172.16.8.2")}
+	base := time.Unix(1659409534, 0)
+	traceID := zipkinmodel.TraceID{Low: 1}
+	rootID, rpcID, dbID := zipkinmodel.ID(1), zipkinmodel.ID(2), zipkinmodel.ID(3)
+
+	root := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: rootID},
+		Kind:        zipkinmodel.Server, LocalEndpoint: frontend,
+		Timestamp: base, Duration: 100 * time.Millisecond,
+	}
+	client := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: rpcID, ParentID: &rootID},
+		Kind:        zipkinmodel.Client, LocalEndpoint: frontend,
+		Timestamp: base.Add(10 * time.Millisecond), Duration: 50 * time.Millisecond,
+	}
+	// The backend clock is 1s behind: its server span starts before its client.
+	server := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: rpcID, ParentID: &rootID},
+		Kind:        zipkinmodel.Server, LocalEndpoint: backend, Shared: true,
+		Timestamp: base.Add(-990 * time.Millisecond), Duration: 40 * time.Millisecond,
+		Annotations: []zipkinmodel.Annotation{{Timestamp: base.Add(-980 * time.Millisecond), Value: "cache miss"}},
+	}
+	db := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: dbID, ParentID: &rpcID},
+		Kind:        zipkinmodel.Client, LocalEndpoint: backend,
+		Timestamp: base.Add(-985 * time.Millisecond), Duration: 20 * time.Millisecond,
+	}
+
+	CorrectClockSkew([]*zipkinmodel.SpanModel{db, server, client, root})
+
+	expected := map[*zipkinmodel.SpanModel]time.Time{
+		root:   base,
+		client: base.Add(10 * time.Millisecond),
+		server: base.Add(15 * time.Millisecond),
+		db:     base.Add(20 * time.Millisecond),
+	}
+	for span, ts := range expected {
+		if !span.Timestamp.Equal(ts) {
+			t.Errorf("Span %v: Expected %v, Actual: %v", span.ID, ts, span.Timestamp)
+		}
+	}
+	if anno := server.Annotations[0].Timestamp; !anno.Equal(base.Add(25 * time.Millisecond)) {
+		t.Errorf("Annotation: Expected %v, Actual: %v", base.Add(25*time.Millisecond), anno)
+	}
+}
+
+func TestClockSkewProcessorKeepsTheOrigins(t *testing.T) {
+	p := NewClockSkewProcessor(&configure.Configuration{ClockSkewWindow: time.Minute}).(*clockSkewProcessor)
+	traceID := zipkinmodel.TraceID{Low: 1}
+	span := func(id uint64) *zipkinmodel.SpanModel {
+		return &zipkinmodel.SpanModel{SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: zipkinmodel.ID(id)}}
+	}
+	p.Process(&Batch{Topic: "zipkin", Route: "team", Spans: []*zipkinmodel.SpanModel{span(1)}})
+	p.Process(&Batch{Source: "kafka", Topic: "zipkin", Partition: 1, Spans: []*zipkinmodel.SpanModel{span(2)}})
+
+	if batches := p.Flush(false); len(batches) != 0 {
+		t.Fatalf("Expected the trace to be buffered, Actual: %d batches", len(batches))
+	}
+	batches := p.Flush(true)
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches, Actual: %d", len(batches))
+	}
+	if batches[0].Route != "team" || batches[0].Spans[0].ID != 1 {
+		t.Errorf("Expected the span 1 routed to team, Actual: %+v", batches[0])
+	}
+	if batches[1].Route != "" || batches[1].Spans[0].ID != 2 || batches[1].Origin() != (Origin{Source: "kafka", Topic: "zipkin", Partition: 1}) {
+		t.Errorf("Expected the span 2 without route from its partition, Actual: %+v", batches[1])
+	}
+}
+
+func TestClockSkewProcessorCheckpoint(t *testing.T) {
+	p := NewClockSkewProcessor(&configure.Configuration{ClockSkewWindow: time.Minute}).(*clockSkewProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+	span := func(traceID uint64) *zipkinmodel.SpanModel {
+		return &zipkinmodel.SpanModel{SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: traceID}, ID: 1}}
+	}
+	if released := p.Checkpoint(); !released() {
+		t.Error("Expected an empty buffer to be released")
+	}
+
+	p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{span(1)}})
+	released := p.Checkpoint()
+	now = now.Add(30 * time.Second)
+	p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{span(2)}})
+	if released() {
+		t.Error("Expected the trace 1 to be buffered")
+	}
+	now = now.Add(30 * time.Second)
+	if batches := p.Flush(false); len(batches) != 1 || batches[0].Spans[0].TraceID.Low != 1 {
+		t.Fatalf("Expected the trace 1 to be released, Actual: %v", batches)
+	}
+	if !released() {
+		t.Error("Expected the checkpoint to be released with the trace 1, though the trace 2 is buffered")
+	}
+}
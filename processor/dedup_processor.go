@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+const (
+	MetricDedupSpansTotal     = "zipkin_ingester_dedup_spans_total"
+	MetricDuplicateSpansTotal = "zipkin_ingester_duplicate_spans_total"
+
+	defaultDedupWindow     = 10 * time.Minute
+	defaultDedupMaxEntries = 1000000
+)
+
+// spanKey identifies a span, the client and server sides of a shared span are different spans.
+type spanKey struct {
+	traceID zipkinmodel.TraceID
+	id      zipkinmodel.ID
+	kind    zipkinmodel.Kind
+	shared  bool
+}
+
+type seenSpan struct {
+	key     spanKey
+	expires time.Time
+}
+
+// dedupProcessor drops the spans already seen within the window, e.g. redelivered
+// by kafka after a rebalance or replayed. The cache is bounded both by the window
+// and by the max entries, the oldest entries are evicted first.
+type dedupProcessor struct {
+	lock       sync.Mutex
+	window     time.Duration
+	maxEntries int
+	seen       map[spanKey]time.Time
+	// order is a FIFO of the keys by insertion time, it may hold stale keys
+	// that were seen again since, they are skipped on eviction.
+	order []seenSpan
+	now   func() time.Time
+
+	spans      *metrics.Counter
+	duplicates *metrics.Counter
+}
+
+func NewDedupProcessor(config *configure.Configuration) Processor {
+	p := &dedupProcessor{
+		window:     config.DedupWindow,
+		maxEntries: config.DedupMaxEntries,
+		seen:       make(map[spanKey]time.Time),
+		now:        time.Now,
+		spans:      metrics.NewCounter(MetricDedupSpansTotal, nil),
+		duplicates: metrics.NewCounter(MetricDuplicateSpansTotal, nil),
+	}
+	if p.window <= 0 {
+		p.window = defaultDedupWindow
+	}
+	if p.maxEntries <= 0 {
+		p.maxEntries = defaultDedupMaxEntries
+	}
+	return p
+}
+
+func (p *dedupProcessor) Process(batch *Batch) *Batch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	p.evict(now)
+
+	spans := batch.Spans[:0]
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		p.spans.Inc()
+		key := spanKey{traceID: span.TraceID, id: span.ID, kind: span.Kind, shared: span.Shared}
+		if expires, ok := p.seen[key]; ok && now.Before(expires) {
+			p.duplicates.Inc()
+			continue
+		}
+
+		expires := now.Add(p.window)
+		p.seen[key] = expires
+		p.order = append(p.order, seenSpan{key: key, expires: expires})
+		if len(p.seen) > p.maxEntries {
+			p.evictOldest()
+		}
+		spans = append(spans, span)
+	}
+	batch.Spans = spans
+	return batch
+}
+
+// evict removes the expired entries.
+func (p *dedupProcessor) evict(now time.Time) {
+	i := 0
+	for ; i < len(p.order) && !now.Before(p.order[i].expires); i++ {
+		if p.seen[p.order[i].key] == p.order[i].expires {
+			delete(p.seen, p.order[i].key)
+		}
+	}
+	p.order = p.order[i:]
+}
+
+// evictOldest removes the oldest live entry to keep the cache bounded.
+func (p *dedupProcessor) evictOldest() {
+	for len(p.order) > 0 {
+		oldest := p.order[0]
+		p.order = p.order[1:]
+		if p.seen[oldest.key] == oldest.expires {
+			delete(p.seen, oldest.key)
+			return
+		}
+	}
+}
+
+// DuplicateRate is the ratio of the duplicate spans among the spans checked so far.
+func DuplicateRate() float64 {
+	spans := metrics.NewCounter(MetricDedupSpansTotal, nil).Value()
+	if spans == 0 {
+		return 0
+	}
+	return float64(metrics.NewCounter(MetricDuplicateSpansTotal, nil).Value()) / float64(spans)
+}
+
+func (p *dedupProcessor) Close() {
+}
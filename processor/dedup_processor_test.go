@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func dedupSpan(id zipkinmodel.ID, kind zipkinmodel.Kind, shared bool) *zipkinmodel.SpanModel {
+	return &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: id},
+		Kind:        kind,
+		Shared:      shared,
+	}
+}
+
+func TestDedupProcessor(t *testing.T) {
+	p := NewDedupProcessor(&configure.Configuration{DedupWindow: time.Minute}).(*dedupProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	batch := p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{
+		dedupSpan(1, zipkinmodel.Client, false),
+		dedupSpan(1, zipkinmodel.Server, true),
+		dedupSpan(1, zipkinmodel.Client, false),
+		dedupSpan(2, zipkinmodel.Client, false),
+	}})
+	if len(batch.Spans) != 3 {
+		t.Fatalf("Expected 3 spans, the shared server span is not a duplicate, Actual: %d", len(batch.Spans))
+	}
+
+	now = now.Add(30 * time.Second)
+	if batch = p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{dedupSpan(2, zipkinmodel.Client, false)}}); len(batch.Spans) != 0 {
+		t.Errorf("Expected the redelivered span to be dropped, Actual: %d", len(batch.Spans))
+	}
+
+	now = now.Add(31 * time.Second)
+	if batch = p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{dedupSpan(2, zipkinmodel.Client, false)}}); len(batch.Spans) != 1 {
+		t.Errorf("Expected the span to pass after the window, Actual: %d", len(batch.Spans))
+	}
+	if len(p.seen) != 1 || len(p.order) != 1 {
+		t.Errorf("Expected the expired entries to be evicted, Actual: %d, %d", len(p.seen), len(p.order))
+	}
+}
+
+func TestDedupProcessorMaxEntries(t *testing.T) {
+	p := NewDedupProcessor(&configure.Configuration{DedupMaxEntries: 2}).(*dedupProcessor)
+
+	p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{
+		dedupSpan(1, zipkinmodel.Client, false),
+		dedupSpan(2, zipkinmodel.Client, false),
+		dedupSpan(3, zipkinmodel.Client, false),
+	}})
+	if len(p.seen) != 2 {
+		t.Fatalf("Expected the cache to be bounded to 2 entries, Actual: %d", len(p.seen))
+	}
+	// the oldest span was evicted, so it is not recognized anymore
+	if batch := p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{dedupSpan(1, zipkinmodel.Client, false), dedupSpan(3, zipkinmodel.Client, false)}}); len(batch.Spans) != 1 || batch.Spans[0].ID != 1 {
+		t.Errorf("Expected only the evicted span to pass, Actual: %d", len(batch.Spans))
+	}
+	if DuplicateRate() <= 0 {
+		t.Error("Expected a duplicate rate")
+	}
+}
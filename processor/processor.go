@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+// Batch is a group of spans consumed from the same partition.
+type Batch struct {
+	// Source is the name of the source the spans were consumed from.
+	Source    string
+	Topic     string
+	Partition int32
+	// Route is the route forced by the source of the spans, empty if the route is selected by its conditions.
+	Route string
+	Spans []*zipkinmodel.SpanModel
+}
+
+// Origin identifies the partition of a source the spans of a batch were consumed from.
+type Origin struct {
+	Source    string
+	Topic     string
+	Partition int32
+}
+
+// Origin returns the partition the spans were consumed from.
+func (b *Batch) Origin() Origin {
+	return Origin{Source: b.Source, Topic: b.Topic, Partition: b.Partition}
+}
+
+type Processor interface {
+	Process(batch *Batch) *Batch
+
+	Close()
+}
+
+// Flusher is implemented by the processors holding spans back between batches.
+type Flusher interface {
+	// Flush returns the buffered spans which are ready to be exported, or all of them if force is set.
+	Flush(force bool) []*Batch
+}
+
+// Checkpointer is implemented by the flushers, the returned function tells
+// without waiting whether the spans buffered at the checkpoint were released.
+type Checkpointer interface {
+	Checkpoint() func() bool
+}
+
+// Chain runs the processors in order, feeding the output of one into the next.
+type Chain []Processor
+
+func (c Chain) Process(batch *Batch) *Batch {
+	for _, p := range c {
+		if batch == nil || len(batch.Spans) == 0 {
+			return batch
+		}
+		batch = p.Process(batch)
+	}
+	return batch
+}
+
+// Flush collects the spans released by the flushers and runs them through the rest of the chain.
+func (c Chain) Flush(force bool) []*Batch {
+	var pending []*Batch
+	for _, p := range c {
+		next := make([]*Batch, 0, len(pending))
+		for _, batch := range pending {
+			if batch = p.Process(batch); batch != nil && len(batch.Spans) > 0 {
+				next = append(next, batch)
+			}
+		}
+		if f, ok := p.(Flusher); ok {
+			next = append(next, f.Flush(force)...)
+		}
+		pending = next
+	}
+	return pending
+}
+
+// Checkpoint tells whether the processors released the spans they held at the
+// checkpoint, which were then exported by the caller of Flush.
+func (c Chain) Checkpoint() func() bool {
+	var checkpoints []func() bool
+	for _, p := range c {
+		if checkpointer, ok := p.(Checkpointer); ok {
+			checkpoints = append(checkpoints, checkpointer.Checkpoint())
+		}
+	}
+	return func() bool {
+		for _, released := range checkpoints {
+			if !released() {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Throttled merges the partitions throttled by the processors, see Throttler.
+func (c Chain) Throttled() map[Origin]time.Time {
+	var throttled map[Origin]time.Time
+	for _, p := range c {
+		if throttler, ok := p.(Throttler); ok {
+			for origin, until := range throttler.Throttled() {
+				if throttled == nil {
+					throttled = make(map[Origin]time.Time)
+				}
+				if until.After(throttled[origin]) {
+					throttled[origin] = until
+				}
+			}
+		}
+	}
+	return throttled
+}
+
+func (c Chain) Close() {
+	for _, p := range c {
+		p.Close()
+	}
+}
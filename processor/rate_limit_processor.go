@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+)
+
+const (
+	MetricThrottledSpansTotal = "zipkin_ingester_throttled_spans_total"
+	MetricThrottledBytesTotal = "zipkin_ingester_throttled_bytes_total"
+
+	LabelAction = "action"
+
+	// RateLimitDrop drops the spans over the limit.
+	RateLimitDrop = "drop"
+	// RateLimitBlock keeps the spans over the limit and pauses the consumption
+	// of the partitions they came from until the debt is paid back.
+	RateLimitBlock = "block"
+
+	maxRateLimitedServices = 10000
+)
+
+// ParseRateLimitAction validates the action on limit, the default is drop.
+func ParseRateLimitAction(action string) (string, error) {
+	switch strings.ToLower(action) {
+	case "", RateLimitDrop:
+		return RateLimitDrop, nil
+	case RateLimitBlock:
+		return RateLimitBlock, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit action %q, expected drop or block", action)
+	}
+}
+
+// RateLimitEnabled tells whether any rate limit is configured.
+func RateLimitEnabled(config *configure.Configuration) bool {
+	if config.RateLimitSpans > 0 || config.RateLimitBytes > 0 || config.RateLimitServiceSpans > 0 || config.RateLimitServiceBytes > 0 {
+		return true
+	}
+	for _, limit := range config.RateLimitServices {
+		if limit.Spans > 0 || limit.Bytes > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// limiter holds the span and byte buckets of a scope, a nil bucket means unlimited.
+type limiter struct {
+	spans *tokenBucket
+	bytes *tokenBucket
+}
+
+func newLimiter(spans, bytes float64, now time.Time) *limiter {
+	l := &limiter{}
+	if spans > 0 {
+		l.spans = newTokenBucket(spans, now)
+	}
+	if bytes > 0 {
+		l.bytes = newTokenBucket(bytes, now)
+	}
+	return l
+}
+
+func (l *limiter) available(size float64, now time.Time) bool {
+	return (l.spans == nil || l.spans.available(1, now)) && (l.bytes == nil || l.bytes.available(size, now))
+}
+
+func (l *limiter) reserve(size float64, now time.Time) {
+	if l.spans != nil {
+		l.spans.reserve(1, now)
+	}
+	if l.bytes != nil {
+		l.bytes.reserve(size, now)
+	}
+}
+
+// delay is how long to wait until the debt is paid back once the span is reserved.
+func (l *limiter) delay(size float64, now time.Time) time.Duration {
+	var delay time.Duration
+	if l.spans != nil {
+		delay = l.spans.delay(1, now)
+	}
+	if l.bytes != nil {
+		delay = maxDuration(delay, l.bytes.delay(size, now))
+	}
+	return delay
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// serviceLimiter accounts the throttled spans to the service.
+type serviceLimiter struct {
+	*limiter
+	throttledSpans *metrics.Counter
+	throttledBytes *metrics.Counter
+}
+
+func newServiceLimiter(spans, bytes float64, labels map[string]string, now time.Time) *serviceLimiter {
+	return &serviceLimiter{
+		limiter:        newLimiter(spans, bytes, now),
+		throttledSpans: metrics.NewCounter(MetricThrottledSpansTotal, labels),
+		throttledBytes: metrics.NewCounter(MetricThrottledBytesTotal, labels),
+	}
+}
+
+func (s *serviceLimiter) throttle(size float64) {
+	s.throttledSpans.Inc()
+	s.throttledBytes.Add(uint64(size))
+}
+
+// Throttler is implemented by the processors slowing the consumption down
+// instead of dropping the spans over a limit.
+type Throttler interface {
+	// Throttled returns until when the consumption of the partitions must pause,
+	// the partitions are returned once.
+	Throttled() map[Origin]time.Time
+}
+
+// rateLimitProcessor limits the spans per second and bytes per second sent to
+// the exporters, globally and per service of the local endpoint. In block mode
+// the spans are reserved ahead and the partitions they came from are throttled
+// until the debt is paid back, the consuming loop pauses them meanwhile.
+type rateLimitProcessor struct {
+	lock      sync.Mutex
+	action    string
+	global    *limiter
+	services  map[string]*serviceLimiter
+	overflow  *serviceLimiter
+	throttled map[Origin]time.Time
+	config    *configure.Configuration
+	now       func() time.Time
+}
+
+func NewRateLimitProcessor(config *configure.Configuration) Processor {
+	action, _ := ParseRateLimitAction(config.RateLimitAction)
+	p := &rateLimitProcessor{
+		action:    action,
+		services:  make(map[string]*serviceLimiter),
+		throttled: make(map[Origin]time.Time),
+		config:    config,
+		now:       time.Now,
+	}
+	p.global = newLimiter(config.RateLimitSpans, config.RateLimitBytes, p.now())
+	return p
+}
+
+func (p *rateLimitProcessor) Process(batch *Batch) *Batch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	spans := batch.Spans[:0]
+	var delay time.Duration
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		service := p.lookupLimiter(serviceName(span), now)
+		size := float64(converter.EstimateSpanSize(span))
+
+		if p.action == RateLimitBlock {
+			// reserve ahead, the partition is then paused once for the whole batch
+			d := maxDuration(service.delay(size, now), p.global.delay(size, now))
+			if d > 0 {
+				service.throttle(size)
+			}
+			service.reserve(size, now)
+			p.global.reserve(size, now)
+			delay = maxDuration(delay, d)
+			spans = append(spans, span)
+			continue
+		}
+
+		if !service.available(size, now) || !p.global.available(size, now) {
+			service.throttle(size)
+			continue
+		}
+		service.reserve(size, now)
+		p.global.reserve(size, now)
+		spans = append(spans, span)
+	}
+
+	if until := now.Add(delay); delay > 0 && until.After(p.throttled[batch.Origin()]) {
+		p.throttled[batch.Origin()] = until
+	}
+	batch.Spans = spans
+	return batch
+}
+
+func (p *rateLimitProcessor) Throttled() map[Origin]time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.throttled) == 0 {
+		return nil
+	}
+	throttled := p.throttled
+	p.throttled = make(map[Origin]time.Time)
+	return throttled
+}
+
+func (p *rateLimitProcessor) lookupLimiter(service string, now time.Time) *serviceLimiter {
+	if l, ok := p.services[service]; ok {
+		return l
+	}
+	if len(p.services) >= maxRateLimitedServices {
+		if p.overflow == nil {
+			p.overflow = newServiceLimiter(p.config.RateLimitServiceSpans, p.config.RateLimitServiceBytes,
+				map[string]string{LabelOverflow: "true", LabelAction: p.action}, now)
+		}
+		return p.overflow
+	}
+
+	spans, bytes := p.config.RateLimitServiceSpans, p.config.RateLimitServiceBytes
+	if limit, ok := p.config.RateLimitServices[service]; ok {
+		spans, bytes = limit.Spans, limit.Bytes
+	}
+	l := newServiceLimiter(spans, bytes, map[string]string{LabelService: service, LabelAction: p.action}, now)
+	p.services[service] = l
+	return l
+}
+
+func (p *rateLimitProcessor) Close() {
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+)
+
+func serviceSpans(service string, count int) []*zipkinmodel.SpanModel {
+	endpoint := &zipkinmodel.Endpoint{ServiceName: service}
+	spans := make([]*zipkinmodel.SpanModel, count)
+	for i := range spans {
+		spans[i] = &zipkinmodel.SpanModel{Name: "get", LocalEndpoint: endpoint}
+	}
+	return spans
+}
+
+func TestRateLimitProcessorDrop(t *testing.T) {
+	config := &configure.Configuration{
+		RateLimitServiceSpans: 2,
+		RateLimitServices:     map[string]configure.ServiceRateLimit{"drop-vip": {Spans: 10}},
+	}
+	p := NewRateLimitProcessor(config).(*rateLimitProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	batch := p.Process(&Batch{Spans: append(serviceSpans("drop-order", 5), serviceSpans("drop-vip", 5)...)})
+	if len(batch.Spans) != 7 {
+		t.Fatalf("Expected 7 spans, Actual: %d", len(batch.Spans))
+	}
+	throttled := metrics.NewCounter(MetricThrottledSpansTotal, map[string]string{LabelService: "drop-order", LabelAction: RateLimitDrop})
+	if throttled.Value() != 3 {
+		t.Errorf("Expected 3 throttled spans, Actual: %d", throttled.Value())
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if batch = p.Process(&Batch{Spans: serviceSpans("drop-order", 5)}); len(batch.Spans) != 1 {
+		t.Errorf("Expected 1 span after the refill, Actual: %d", len(batch.Spans))
+	}
+}
+
+func TestRateLimitProcessorGlobalBytes(t *testing.T) {
+	span := serviceSpans("bytes-order", 1)[0]
+	size := float64(converter.EstimateSpanSize(span))
+	p := NewRateLimitProcessor(&configure.Configuration{RateLimitBytes: size * 3}).(*rateLimitProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	if batch := p.Process(&Batch{Spans: serviceSpans("bytes-order", 5)}); len(batch.Spans) != 3 {
+		t.Errorf("Expected 3 spans, Actual: %d", len(batch.Spans))
+	}
+	throttled := metrics.NewCounter(MetricThrottledBytesTotal, map[string]string{LabelService: "bytes-order", LabelAction: RateLimitDrop})
+	if throttled.Value() != uint64(size)*2 {
+		t.Errorf("Expected %d throttled bytes, Actual: %d", uint64(size)*2, throttled.Value())
+	}
+}
+
+func TestRateLimitProcessorOversizedSpan(t *testing.T) {
+	span := serviceSpans("oversized-order", 1)[0]
+	size := float64(converter.EstimateSpanSize(span))
+	p := NewRateLimitProcessor(&configure.Configuration{RateLimitBytes: size / 2}).(*rateLimitProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	// the span is larger than the burst, it passes once the bucket is full
+	if batch := p.Process(&Batch{Spans: serviceSpans("oversized-order", 2)}); len(batch.Spans) != 1 {
+		t.Errorf("Expected the first span to pass, Actual: %d", len(batch.Spans))
+	}
+	now = now.Add(time.Second)
+	if batch := p.Process(&Batch{Spans: serviceSpans("oversized-order", 1)}); len(batch.Spans) != 0 {
+		t.Errorf("Expected the span to wait for the debt to be paid back, Actual: %d", len(batch.Spans))
+	}
+	now = now.Add(2 * time.Second)
+	if batch := p.Process(&Batch{Spans: serviceSpans("oversized-order", 1)}); len(batch.Spans) != 1 {
+		t.Errorf("Expected the span to pass once the bucket is full again, Actual: %d", len(batch.Spans))
+	}
+}
+
+func TestRateLimitProcessorBlock(t *testing.T) {
+	config := &configure.Configuration{RateLimitSpans: 2, RateLimitAction: RateLimitBlock}
+	p := NewRateLimitProcessor(config).(*rateLimitProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	// 2 spans are in the burst, the 4 others are kept and paid back in 2s
+	if batch := p.Process(&Batch{Source: "kafka", Topic: "zipkin", Partition: 3, Spans: serviceSpans("block-order", 6)}); len(batch.Spans) != 6 {
+		t.Errorf("Expected no span to be dropped, Actual: %d", len(batch.Spans))
+	}
+	throttled := p.Throttled()
+	if until := throttled[Origin{Source: "kafka", Topic: "zipkin", Partition: 3}]; len(throttled) != 1 || !until.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected the partition to pause for 2s, Actual: %v", throttled)
+	}
+	if throttled := p.Throttled(); len(throttled) != 0 {
+		t.Errorf("Expected the throttled partitions to be returned once, Actual: %v", throttled)
+	}
+	delayed := metrics.NewCounter(MetricThrottledSpansTotal, map[string]string{LabelService: "block-order", LabelAction: RateLimitBlock})
+	if delayed.Value() != 4 {
+		t.Errorf("Expected 4 throttled spans, Actual: %d", delayed.Value())
+	}
+
+	now = now.Add(3 * time.Second)
+	if batch := p.Process(&Batch{Topic: "zipkin", Spans: serviceSpans("block-order", 1)}); len(batch.Spans) != 1 {
+		t.Errorf("Expected the span to pass once the debt is paid back, Actual: %d", len(batch.Spans))
+	}
+	if throttled := p.Throttled(); len(throttled) != 0 {
+		t.Errorf("Expected no throttled partition, Actual: %v", throttled)
+	}
+}
+
+func TestParseRateLimitAction(t *testing.T) {
+	if action, err := ParseRateLimitAction(""); err != nil || action != RateLimitDrop {
+		t.Errorf("Expected the drop default, Actual: %s %v", action, err)
+	}
+	if _, err := ParseRateLimitAction("pause"); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+)
+
+// spanLimitsProcessor truncates the oversized spans before they are converted
+// for SLS or OTLP, so a single span can't fail a whole batch.
+type spanLimitsProcessor struct {
+	limits converter.SpanLimits
+}
+
+// SpanLimits returns the span size limits of the configuration.
+func SpanLimits(config *configure.Configuration) converter.SpanLimits {
+	return converter.SpanLimits{
+		MaxTagCount:        config.SpanMaxTagCount,
+		MaxTagValueLength:  config.SpanMaxTagValueLength,
+		MaxAnnotationCount: config.SpanMaxAnnotations,
+		MaxSpanSize:        config.SpanMaxSize,
+	}
+}
+
+func NewSpanLimitsProcessor(config *configure.Configuration) Processor {
+	return &spanLimitsProcessor{limits: SpanLimits(config)}
+}
+
+func (p *spanLimitsProcessor) Process(batch *Batch) *Batch {
+	for _, span := range batch.Spans {
+		if span != nil {
+			p.limits.Apply(span)
+		}
+	}
+	return batch
+}
+
+func (p *spanLimitsProcessor) Close() {
+}
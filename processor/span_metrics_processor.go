@@ -0,0 +1,279 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+const (
+	MetricCallsTotal     = "zipkin_span_calls_total"
+	MetricErrorsTotal    = "zipkin_span_errors_total"
+	MetricDurationBucket = "zipkin_span_duration_ms_bucket"
+	MetricDurationSum    = "zipkin_span_duration_ms_sum"
+	MetricDurationCount  = "zipkin_span_duration_ms_count"
+
+	LabelService    = "service"
+	LabelOperation  = "operation"
+	LabelSpanKind   = "span_kind"
+	LabelStatusCode = "status_code"
+	LabelOverflow   = "overflow"
+	LabelLe         = "le"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in milliseconds, of the latency histogram.
+var DefaultLatencyBuckets = []float64{2, 4, 6, 8, 10, 50, 100, 200, 400, 800, 1000, 1400, 2000, 5000, 10000, 15000}
+
+type spanSeries struct {
+	labels  map[string]string
+	calls   uint64
+	errors  uint64
+	buckets []uint64
+	sum     float64
+}
+
+// spanMetricsProcessor aggregates request rate, error rate and latency (RED) per
+// service, operation, kind and status, and periodically writes the cumulative
+// values to a metric exporter. The spans themselves pass through unchanged.
+type spanMetricsProcessor struct {
+	lock       sync.Mutex
+	series     map[string]*spanSeries
+	dimensions []string
+	maxSeries  int
+	buckets    []float64
+	overflowed uint64
+
+	exporter metrics.Exporter
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	sugar    *zap.SugaredLogger
+}
+
+// CheckSpanMetricsDimensions rejects the dimensions whose label name clashes with
+// a built-in label or with another dimension once sanitized.
+func CheckSpanMetricsDimensions(dimensions []string) error {
+	labels := map[string]string{
+		LabelService:    "",
+		LabelOperation:  "",
+		LabelSpanKind:   "",
+		LabelStatusCode: "",
+		LabelOverflow:   "",
+		LabelLe:         "",
+	}
+	for _, dimension := range dimensions {
+		name := sanitizeLabelName(dimension)
+		if other, ok := labels[name]; ok {
+			if other == "" {
+				return fmt.Errorf("the span metrics dimension %s clashes with the built-in label %s", dimension, name)
+			}
+			return fmt.Errorf("the span metrics dimensions %s and %s have the same label %s", other, dimension, name)
+		}
+		labels[name] = dimension
+	}
+	return nil
+}
+
+func NewSpanMetricsProcessor(config *configure.Configuration, exporter metrics.Exporter, sugar *zap.SugaredLogger) Processor {
+	p := &spanMetricsProcessor{
+		series:     make(map[string]*spanSeries),
+		dimensions: config.SpanMetricsDimensions,
+		maxSeries:  config.SpanMetricsMaxSeries,
+		buckets:    DefaultLatencyBuckets,
+		exporter:   exporter,
+		interval:   config.SpanMetricsInterval,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		sugar:      sugar,
+	}
+	if p.interval <= 0 {
+		p.interval = 15 * time.Second
+	}
+	go p.run()
+	return p
+}
+
+func (p *spanMetricsProcessor) Process(batch *Batch) *Batch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		status := converter.SpanStatus(span)
+		s := p.lookupSeries(p.spanLabels(span, status))
+		s.calls++
+		if status.Code == tracepb.Status_STATUS_CODE_ERROR {
+			s.errors++
+		}
+		latency := float64(span.Duration.Microseconds()) / 1000
+		s.sum += latency
+		for i, bound := range p.buckets {
+			if latency <= bound {
+				s.buckets[i]++
+			}
+		}
+	}
+	return batch
+}
+
+func (p *spanMetricsProcessor) Close() {
+	close(p.stop)
+	<-p.done
+	p.exporter.Close()
+}
+
+func (p *spanMetricsProcessor) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.flush()
+		case <-p.stop:
+			p.flush()
+			return
+		}
+	}
+}
+
+func (p *spanMetricsProcessor) flush() {
+	points := p.collect(time.Now())
+	if len(points) == 0 {
+		return
+	}
+	if err := p.exporter.ExportMetrics(points); err != nil {
+		p.sugar.Warnw("Failed to export span metrics", "exception", err, "points", len(points))
+	}
+}
+
+func (p *spanMetricsProcessor) collect(now time.Time) []*metrics.Point {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.overflowed > 0 {
+		p.sugar.Warnw("Span metrics series limit reached", "maxSeries", p.maxSeries, "overflowedSpans", p.overflowed)
+		p.overflowed = 0
+	}
+
+	points := make([]*metrics.Point, 0, len(p.series)*(len(p.buckets)+5))
+	for _, s := range p.series {
+		points = append(points,
+			newPoint(MetricCallsTotal, s.labels, float64(s.calls), now),
+			newPoint(MetricErrorsTotal, s.labels, float64(s.errors), now),
+			newPoint(MetricDurationSum, s.labels, s.sum, now),
+			newPoint(MetricDurationCount, s.labels, float64(s.calls), now),
+		)
+		for i, bound := range p.buckets {
+			labels := copyLabels(s.labels)
+			labels[LabelLe] = formatBound(bound)
+			points = append(points, newPoint(MetricDurationBucket, labels, float64(s.buckets[i]), now))
+		}
+		labels := copyLabels(s.labels)
+		labels[LabelLe] = "+Inf"
+		points = append(points, newPoint(MetricDurationBucket, labels, float64(s.calls), now))
+	}
+	return points
+}
+
+func (p *spanMetricsProcessor) spanLabels(span *zipkinmodel.SpanModel, status *tracepb.Status) map[string]string {
+	labels := make(map[string]string, 4+len(p.dimensions))
+	labels[LabelService] = serviceName(span)
+	labels[LabelOperation] = span.Name
+	labels[LabelSpanKind] = strings.ToLower(string(span.Kind))
+	labels[LabelStatusCode] = converter.StatusCodeName(status.Code)
+	for _, dimension := range p.dimensions {
+		labels[sanitizeLabelName(dimension)] = span.Tags[dimension]
+	}
+	return labels
+}
+
+func (p *spanMetricsProcessor) lookupSeries(labels map[string]string) *spanSeries {
+	key := seriesKey(labels)
+	if s, ok := p.series[key]; ok {
+		return s
+	}
+
+	if p.maxSeries > 0 && len(p.series) >= p.maxSeries {
+		p.overflowed++
+		labels = map[string]string{LabelOverflow: "true"}
+		key = seriesKey(labels)
+		if s, ok := p.series[key]; ok {
+			return s
+		}
+	}
+
+	s := &spanSeries{
+		labels:  labels,
+		buckets: make([]uint64, len(p.buckets)),
+	}
+	p.series[key] = s
+	return s
+}
+
+func serviceName(span *zipkinmodel.SpanModel) string {
+	if span.LocalEndpoint == nil {
+		return ""
+	}
+	return span.LocalEndpoint.ServiceName
+}
+
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(0)
+		sb.WriteString(labels[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	dest := make(map[string]string, len(labels)+1)
+	for key, val := range labels {
+		dest[key] = val
+	}
+	return dest
+}
+
+func newPoint(name string, labels map[string]string, value float64, t time.Time) *metrics.Point {
+	return &metrics.Point{
+		Name:   name,
+		Labels: labels,
+		Value:  value,
+		Time:   t,
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"go.uber.org/zap"
+)
+
+type fakeMetricExporter struct {
+	points []*metrics.Point
+}
+
+func (f *fakeMetricExporter) ExportMetrics(points []*metrics.Point) error {
+	f.points = append(f.points, points...)
+	return nil
+}
+
+func (f *fakeMetricExporter) Close() {
+}
+
+func TestSpanMetricsProcessor(t *testing.T) {
+	exporter := &fakeMetricExporter{}
+	config := &configure.Configuration{
+		SpanMetricsInterval:   time.Hour,
+		SpanMetricsDimensions: []string{"http.method"},
+		SpanMetricsMaxSeries:  2,
+	}
+	p := NewSpanMetricsProcessor(config, exporter, zap.NewNop().Sugar())
+
+	endpoint := &zipkinmodel.Endpoint{ServiceName: "frontend"}
+	p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{
+		{Name: "get", Kind: zipkinmodel.Server, LocalEndpoint: endpoint, Duration: 3 * time.Millisecond, Tags: map[string]string{"http.method": "GET"}},
+		{Name: "get", Kind: zipkinmodel.Server, LocalEndpoint: endpoint, Duration: 30 * time.Millisecond, Tags: map[string]string{"http.method": "GET", "error": "timeout"}},
+		{Name: "post", Kind: zipkinmodel.Server, LocalEndpoint: endpoint, Duration: 3 * time.Millisecond},
+		{Name: "put", Kind: zipkinmodel.Server, LocalEndpoint: endpoint, Duration: 3 * time.Millisecond},
+	}})
+	p.Close()
+
+	calls := make(map[string]float64)
+	for _, point := range exporter.points {
+		if point.Name == MetricCallsTotal {
+			key := point.Labels[LabelOperation] + "/" + point.Labels[LabelStatusCode] + "/" + point.Labels["http_method"]
+			if point.Labels[LabelOverflow] == "true" {
+				key = "overflow"
+			}
+			calls[key] = point.Value
+		}
+	}
+
+	expected := map[string]float64{"get/UNSET/GET": 1, "get/ERROR/GET": 1, "overflow": 2}
+	if len(calls) != len(expected) {
+		t.Fatalf("Series: Expected %v, Actual: %v", expected, calls)
+	}
+	for key, value := range expected {
+		if calls[key] != value {
+			t.Errorf("Calls of %s: Expected %v, Actual: %v", key, value, calls[key])
+		}
+	}
+}
+
+func TestCheckSpanMetricsDimensions(t *testing.T) {
+	cases := []struct {
+		dimensions []string
+		err        string
+	}{
+		{[]string{"http.method", "http.status_code"}, ""},
+		{[]string{"service"}, "clashes with the built-in label service"},
+		{[]string{"span.kind"}, "clashes with the built-in label span_kind"},
+		{[]string{"le"}, "clashes with the built-in label le"},
+		{[]string{"http.method", "http_method"}, "have the same label http_method"},
+	}
+	for i, c := range cases {
+		err := CheckSpanMetricsDimensions(c.dimensions)
+		if (c.err == "" && err != nil) || (c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err))) {
+			t.Errorf("Case %d: Expected %q, Actual: %v", i, c.err, err)
+		}
+	}
+}
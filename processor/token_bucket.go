@@ -0,0 +1,54 @@
+package processor
+
+import "time"
+
+// tokenBucket refills rate tokens per second up to a burst of one second worth of tokens.
+// The tokens may go negative when reserved ahead, the debt is then paid back over time.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// available tells whether n tokens can be taken without debt. More tokens than
+// the burst are available once the bucket is full, they are then paid back over
+// time, otherwise the spans larger than one second of rate would never pass.
+func (b *tokenBucket) available(n float64, now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= n || b.tokens >= b.burst
+}
+
+// reserve takes n tokens even if there are not enough of them.
+func (b *tokenBucket) reserve(n float64, now time.Time) {
+	b.refill(now)
+	b.tokens -= n
+}
+
+// delay is how long to wait until the debt of the bucket is paid back once n
+// more tokens are reserved.
+func (b *tokenBucket) delay(n float64, now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
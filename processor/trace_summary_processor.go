@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/converter"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTraceSummaryWindow    = 30 * time.Second
+	defaultTraceSummaryMaxTraces = 100000
+)
+
+// TraceSummary describes a whole trace assembled from its spans.
+type TraceSummary struct {
+	TraceID       zipkinmodel.TraceID
+	RootService   string
+	RootOperation string
+	Start         time.Time
+	End           time.Time
+	SpanCount     int
+	Services      []string
+	Error         bool
+	// OrphanCount is the count of spans whose parent was not received.
+	OrphanCount int
+}
+
+func (s *TraceSummary) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type TraceSummaryExporter interface {
+	ExportTraceSummaries(summaries []*TraceSummary) error
+	Close()
+}
+
+// spanInfo keeps what the summary needs of a span, not the span itself.
+type spanInfo struct {
+	id        zipkinmodel.ID
+	parentID  *zipkinmodel.ID
+	service   string
+	operation string
+	start     time.Time
+	end       time.Time
+}
+
+type assembledTrace struct {
+	deadline time.Time
+	spans    []spanInfo
+	services map[string]struct{}
+	error    bool
+}
+
+// traceSummaryProcessor assembles the spans of each trace until no span of the
+// trace arrived for the window, then writes a summary of the trace. The spans
+// themselves pass through unchanged.
+type traceSummaryProcessor struct {
+	lock      sync.Mutex
+	window    time.Duration
+	maxTraces int
+	traces    map[zipkinmodel.TraceID]*assembledTrace
+	order     []zipkinmodel.TraceID
+	exporter  TraceSummaryExporter
+	now       func() time.Time
+	sugar     *zap.SugaredLogger
+}
+
+func NewTraceSummaryProcessor(config *configure.Configuration, exporter TraceSummaryExporter, sugar *zap.SugaredLogger) Processor {
+	p := &traceSummaryProcessor{
+		window:    config.TraceSummaryWindow,
+		maxTraces: config.TraceSummaryMaxTraces,
+		traces:    make(map[zipkinmodel.TraceID]*assembledTrace),
+		exporter:  exporter,
+		now:       time.Now,
+		sugar:     sugar,
+	}
+	if p.window <= 0 {
+		p.window = defaultTraceSummaryWindow
+	}
+	if p.maxTraces <= 0 {
+		p.maxTraces = defaultTraceSummaryMaxTraces
+	}
+	return p
+}
+
+func (p *traceSummaryProcessor) Process(batch *Batch) *Batch {
+	p.lock.Lock()
+	var evicted []*TraceSummary
+	now := p.now()
+	for _, span := range batch.Spans {
+		if span == nil {
+			continue
+		}
+		trace, ok := p.traces[span.TraceID]
+		if !ok {
+			if len(p.traces) >= p.maxTraces {
+				if summary := p.evictOldest(); summary != nil {
+					evicted = append(evicted, summary)
+				}
+			}
+			trace = &assembledTrace{services: make(map[string]struct{})}
+			p.traces[span.TraceID] = trace
+			p.order = append(p.order, span.TraceID)
+		}
+		trace.deadline = now.Add(p.window)
+		trace.spans = append(trace.spans, spanInfo{
+			id:        span.ID,
+			parentID:  span.ParentID,
+			service:   serviceName(span),
+			operation: span.Name,
+			start:     span.Timestamp,
+			end:       span.Timestamp.Add(span.Duration),
+		})
+		if service := serviceName(span); service != "" {
+			trace.services[service] = struct{}{}
+		}
+		if !trace.error && converter.SpanStatus(span).Code == tracepb.Status_STATUS_CODE_ERROR {
+			trace.error = true
+		}
+	}
+	p.lock.Unlock()
+
+	p.export(evicted)
+	return batch
+}
+
+// Flush writes the summaries of the traces idle for the window, or of all traces if force is set.
+func (p *traceSummaryProcessor) Flush(force bool) []*Batch {
+	p.lock.Lock()
+	now := p.now()
+	var summaries []*TraceSummary
+	remaining := p.order[:0]
+	for _, traceID := range p.order {
+		trace, ok := p.traces[traceID]
+		if !ok {
+			continue
+		}
+		if force || !now.Before(trace.deadline) {
+			summaries = append(summaries, summarize(traceID, trace))
+			delete(p.traces, traceID)
+			continue
+		}
+		remaining = append(remaining, traceID)
+	}
+	p.order = remaining
+	p.lock.Unlock()
+
+	p.export(summaries)
+	return nil
+}
+
+func (p *traceSummaryProcessor) Close() {
+	p.Flush(true)
+	p.exporter.Close()
+}
+
+func (p *traceSummaryProcessor) evictOldest() *TraceSummary {
+	for len(p.order) > 0 {
+		traceID := p.order[0]
+		p.order = p.order[1:]
+		if trace, ok := p.traces[traceID]; ok {
+			delete(p.traces, traceID)
+			return summarize(traceID, trace)
+		}
+	}
+	return nil
+}
+
+func (p *traceSummaryProcessor) export(summaries []*TraceSummary) {
+	if len(summaries) == 0 {
+		return
+	}
+	if err := p.exporter.ExportTraceSummaries(summaries); err != nil {
+		p.sugar.Warnw("Failed to export trace summaries", "exception", err, "traces", len(summaries))
+	}
+}
+
+func summarize(traceID zipkinmodel.TraceID, trace *assembledTrace) *TraceSummary {
+	summary := &TraceSummary{TraceID: traceID, SpanCount: len(trace.spans), Error: trace.error}
+
+	ids := make(map[zipkinmodel.ID]struct{}, len(trace.spans))
+	for _, span := range trace.spans {
+		ids[span.id] = struct{}{}
+	}
+
+	var root *spanInfo
+	for i := range trace.spans {
+		span := &trace.spans[i]
+		if summary.Start.IsZero() || span.start.Before(summary.Start) {
+			summary.Start = span.start
+		}
+		if span.end.After(summary.End) {
+			summary.End = span.end
+		}
+
+		if span.parentID == nil || *span.parentID == span.id {
+			// the earliest root wins if there are several of them
+			if root == nil || span.start.Before(root.start) {
+				root = span
+			}
+		} else if _, ok := ids[*span.parentID]; !ok {
+			summary.OrphanCount++
+		}
+	}
+	if root != nil {
+		summary.RootService = root.service
+		summary.RootOperation = root.operation
+	}
+
+	for service := range trace.services {
+		summary.Services = append(summary.Services, service)
+	}
+	sort.Strings(summary.Services)
+	return summary
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"go.uber.org/zap"
+)
+
+type fakeSummaryExporter struct {
+	summaries []*TraceSummary
+	closed    bool
+}
+
+func (f *fakeSummaryExporter) ExportTraceSummaries(summaries []*TraceSummary) error {
+	f.summaries = append(f.summaries, summaries...)
+	return nil
+}
+
+func (f *fakeSummaryExporter) Close() {
+	f.closed = true
+}
+
+func TestTraceSummaryProcessor(t *testing.T) {
+	exporter := &fakeSummaryExporter{}
+	p := NewTraceSummaryProcessor(&configure.Configuration{TraceSummaryWindow: 10 * time.Second}, exporter, zap.NewNop().Sugar()).(*traceSummaryProcessor)
+	now := time.Unix(1659409534, 0)
+	p.now = func() time.Time { return now }
+
+	frontend := &zipkinmodel.Endpoint{ServiceName: "frontend"}
+	backend := &zipkinmodel.Endpoint{ServiceName: "backend"}
+	traceID := zipkinmodel.TraceID{Low: 1}
+	rootID, rpcID, missingID := zipkinmodel.ID(1), zipkinmodel.ID(2), zipkinmodel.ID(9)
+	spans := []*zipkinmodel.SpanModel{
+		{
+			SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: rootID},
+			Name:        "get /order", LocalEndpoint: frontend, Timestamp: now, Duration: 100 * time.Millisecond,
+		},
+		{
+			SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: rpcID, ParentID: &rootID},
+			Name:        "query", LocalEndpoint: backend, Timestamp: now.Add(10 * time.Millisecond), Duration: 120 * time.Millisecond,
+			Tags: map[string]string{"error": "timeout"},
+		},
+	}
+	orphan := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{TraceID: traceID, ID: 3, ParentID: &missingID},
+		Name:        "cache", LocalEndpoint: backend, Timestamp: now.Add(20 * time.Millisecond), Duration: time.Millisecond,
+	}
+
+	if batch := p.Process(&Batch{Spans: spans}); len(batch.Spans) != 2 {
+		t.Fatalf("The spans should pass through, Actual: %d", len(batch.Spans))
+	}
+	now = now.Add(8 * time.Second)
+	p.Process(&Batch{Spans: []*zipkinmodel.SpanModel{orphan}})
+
+	// the window restarts with every span of the trace
+	now = now.Add(8 * time.Second)
+	p.Flush(false)
+	if len(exporter.summaries) != 0 {
+		t.Fatalf("The trace is still active, Actual: %d summaries", len(exporter.summaries))
+	}
+
+	now = now.Add(3 * time.Second)
+	p.Flush(false)
+	if len(exporter.summaries) != 1 {
+		t.Fatalf("Expected 1 summary, Actual: %d", len(exporter.summaries))
+	}
+	summary := exporter.summaries[0]
+	if summary.RootService != "frontend" || summary.RootOperation != "get /order" {
+		t.Errorf("Unexpected root %s %s", summary.RootService, summary.RootOperation)
+	}
+	if summary.SpanCount != 3 || summary.OrphanCount != 1 || !summary.Error {
+		t.Errorf("Unexpected summary %+v", *summary)
+	}
+	if summary.Duration() != 130*time.Millisecond {
+		t.Errorf("Expected 130ms, Actual: %v", summary.Duration())
+	}
+	if !reflect.DeepEqual(summary.Services, []string{"backend", "frontend"}) {
+		t.Errorf("Unexpected services %v", summary.Services)
+	}
+
+	p.Process(&Batch{Spans: spans[1:]})
+	p.Close()
+	if len(exporter.summaries) != 2 || !exporter.closed {
+		t.Fatal("Expected the remaining trace to be summarized on close")
+	}
+	if last := exporter.summaries[1]; last.RootService != "" || last.OrphanCount != 1 {
+		t.Errorf("A trace without root has no root service, Actual: %+v", *last)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/credentials"
+	"github.com/aliyun-sls/zipkin-ingester/exporter"
+	"github.com/aliyun-sls/zipkin-ingester/logging"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	"go.uber.org/zap"
+)
+
+// stage is a processor of the chain with the settings it was built from. A
+// reload keeps the processors whose settings did not change, so that their state
+// carries over: the dedup cache, the metric series, the rate limit buckets and
+// the buffered traces.
+type stage struct {
+	name      string
+	settings  interface{}
+	processor processor.Processor
+}
+
+// stageBuilder builds the processor of a stage, it returns nil if the stage is skipped.
+type stageBuilder struct {
+	name     string
+	settings interface{}
+	build    func() processor.Processor
+}
+
+// destination is where a processor writes to SLS by itself.
+type destination struct {
+	Endpoint    string
+	Project     string
+	Instance    string
+	Store       string
+	Credentials credentials.Config
+	LogLevel    string
+	Logging     logging.Config
+}
+
+func newDestination(config *configure.Configuration, store string) destination {
+	return destination{
+		Endpoint:    config.Endpoint,
+		Project:     config.Project,
+		Instance:    config.Instance,
+		Store:       store,
+		Credentials: config.CredentialsConfig(),
+		LogLevel:    config.LogLevel,
+		Logging:     config.LoggingConfig(),
+	}
+}
+
+func stageBuilders(config *configure.Configuration, sugar *zap.SugaredLogger) []stageBuilder {
+	var builders []stageBuilder
+	if config.DedupEnabled {
+		builders = append(builders, stageBuilder{
+			name: "dedup",
+			settings: struct {
+				Window     time.Duration
+				MaxEntries int
+			}{config.DedupWindow, config.DedupMaxEntries},
+			build: func() processor.Processor { return processor.NewDedupProcessor(config) },
+		})
+	}
+	if config.ClockSkewEnabled {
+		builders = append(builders, stageBuilder{
+			name: "clockSkew",
+			settings: struct {
+				Window    time.Duration
+				MaxTraces int
+			}{config.ClockSkewWindow, config.ClockSkewMaxTraces},
+			build: func() processor.Processor { return processor.NewClockSkewProcessor(config) },
+		})
+	}
+	if config.SpanMetricsEnabled {
+		builders = append(builders, stageBuilder{
+			name: "spanMetrics",
+			settings: struct {
+				Dimensions  []string
+				MaxSeries   int
+				Interval    time.Duration
+				Destination destination
+			}{config.SpanMetricsDimensions, config.SpanMetricsMaxSeries, config.SpanMetricsInterval, newDestination(config, config.SpanMetricsStore)},
+			build: func() processor.Processor {
+				metricExporter, err := exporter.NewMetricStoreExporter(config, sugar)
+				if err != nil {
+					sugar.Warnw("Failed to init span metrics exporter", "exception", err)
+					return nil
+				}
+				return processor.NewSpanMetricsProcessor(config, metricExporter, sugar)
+			},
+		})
+	}
+	if config.TraceSummaryEnabled {
+		builders = append(builders, stageBuilder{
+			name: "traceSummary",
+			settings: struct {
+				Window      time.Duration
+				MaxTraces   int
+				Destination destination
+			}{config.TraceSummaryWindow, config.TraceSummaryMaxTraces, newDestination(config, config.TraceSummaryLogstore())},
+			build: func() processor.Processor {
+				summaryExporter, err := exporter.NewTraceSummaryExporter(config, sugar)
+				if err != nil {
+					sugar.Warnw("Failed to init trace summary exporter", "exception", err)
+					return nil
+				}
+				return processor.NewTraceSummaryProcessor(config, summaryExporter, sugar)
+			},
+		})
+	}
+	if limits := processor.SpanLimits(config); limits.Enabled() {
+		builders = append(builders, stageBuilder{
+			name:     "spanLimits",
+			settings: limits,
+			build:    func() processor.Processor { return processor.NewSpanLimitsProcessor(config) },
+		})
+	}
+	if processor.RateLimitEnabled(config) {
+		builders = append(builders, stageBuilder{
+			name: "rateLimit",
+			settings: struct {
+				Spans, Bytes, ServiceSpans, ServiceBytes float64
+				Services                                 map[string]configure.ServiceRateLimit
+				Action                                   string
+			}{config.RateLimitSpans, config.RateLimitBytes, config.RateLimitServiceSpans, config.RateLimitServiceBytes, config.RateLimitServices, config.RateLimitAction},
+			build: func() processor.Processor { return processor.NewRateLimitProcessor(config) },
+		})
+	}
+	return builders
+}
+
+// newProcessors builds the stages enabled by the configuration, the stages of
+// the previous pipeline whose settings did not change are kept instead.
+func newProcessors(config *configure.Configuration, previous []stage, sugar *zap.SugaredLogger) []stage {
+	var stages []stage
+	for _, builder := range stageBuilders(config, sugar) {
+		if kept, ok := findStage(previous, builder); ok {
+			stages = append(stages, kept)
+			continue
+		}
+		if p := builder.build(); p != nil {
+			stages = append(stages, stage{name: builder.name, settings: builder.settings, processor: p})
+		}
+	}
+	return stages
+}
+
+func findStage(stages []stage, builder stageBuilder) (stage, bool) {
+	for _, s := range stages {
+		if s.name == builder.name && reflect.DeepEqual(s.settings, builder.settings) {
+			return s, true
+		}
+	}
+	return stage{}, false
+}
+
+// keptStages returns the names of the stages of next kept from previous.
+func keptStages(previous, next []stage) []string {
+	var names []string
+	for _, n := range next {
+		for _, p := range previous {
+			if n.processor == p.processor {
+				names = append(names, n.name)
+			}
+		}
+	}
+	return names
+}
+
+func chain(stages []stage) processor.Chain {
+	processors := make(processor.Chain, 0, len(stages))
+	for _, s := range stages {
+		processors = append(processors, s.processor)
+	}
+	return processors
+}
+
+// carried is a processor handed over to the next pipeline, the replaced
+// pipeline still runs its spans through it but neither flushes nor closes it.
+type carried struct {
+	processor.Processor
+}
+
+func (carried) Close() {
+}
@@ -0,0 +1,128 @@
+package receiver
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Offset is the next offset to consume from a partition once the messages
+// before it are handled.
+type Offset struct {
+	Partition
+	Offset int64
+	// assignment tells the offsets handled before a partition was revoked from
+	// those handled after it was assigned again.
+	assignment uint64
+}
+
+// Committer is implemented by the ingesters which commit the offsets of the
+// messages whose spans are exported, rather than those of the polled messages.
+type Committer interface {
+	// Handled records that the spans of the message were handed to the pipeline.
+	Handled(msg *Message)
+	// Checkpoint returns the offsets following the messages handled so far.
+	Checkpoint() []Offset
+	// Commit commits the offsets of a checkpoint once its spans are exported, the
+	// offsets of the partitions revoked meanwhile are skipped.
+	Commit(offsets []Offset)
+	// Abandon forgets the offsets handled so far, so that they are not committed
+	// when the ingester is closed. It is called when the spans were not exported.
+	Abandon()
+}
+
+// handledOffset is the offset following the last message handled in a partition.
+type handledOffset struct {
+	offset     int64
+	assignment uint64
+}
+
+func (i *ingesterImpl) Handled(msg *Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	key := partitionKey{msg.Topic, msg.Partition}
+	handled, ok := i.handled[key]
+	if !ok {
+		i.assignments++
+		handled.assignment = i.assignments
+	}
+	handled.offset = msg.Offset + 1
+	i.handled[key] = handled
+}
+
+func (i *ingesterImpl) Checkpoint() []Offset {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	offsets := make([]Offset, 0, len(i.handled))
+	for key, handled := range i.handled {
+		offsets = append(offsets, Offset{
+			Partition:  Partition{Topic: key.topic, Partition: key.partition},
+			Offset:     handled.offset,
+			assignment: handled.assignment,
+		})
+	}
+	return offsets
+}
+
+func (i *ingesterImpl) Commit(offsets []Offset) {
+	i.mu.Lock()
+	partitions := make([]kafka.TopicPartition, 0, len(offsets))
+	for _, offset := range offsets {
+		if handled, ok := i.handled[partitionKey{offset.Topic, offset.Partition.Partition}]; ok && handled.assignment == offset.assignment {
+			partitions = append(partitions, topicPartition(offset.Topic, offset.Partition.Partition, offset.Offset))
+		}
+	}
+	i.mu.Unlock()
+
+	committed, err := i.commit(partitions)
+	if err != nil {
+		i.sugar.Warnw("Failed to commit the offsets of the exported spans.", "exception", err)
+		return
+	}
+	for _, partition := range committed {
+		i.sugar.Debugw("Committed the offset.", "topic", topicName(partition), "partition", partition.Partition, "offset", partition.Offset)
+	}
+}
+
+func (i *ingesterImpl) Abandon() {
+	if offsets := i.releaseAll(); len(offsets) > 0 {
+		i.sugar.Warnw("Abandoned the offsets of the spans which were not exported, they are consumed again by the next consumer.", "partitions", len(offsets))
+	}
+}
+
+// release forgets the offsets handled in the partitions and returns them, so
+// that a checkpoint taken before does not commit them once they are revoked.
+func (i *ingesterImpl) release(partitions []Partition) []kafka.TopicPartition {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	offsets := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, partition := range partitions {
+		key := partitionKey{partition.Topic, partition.Partition}
+		if handled, ok := i.handled[key]; ok {
+			offsets = append(offsets, topicPartition(key.topic, key.partition, handled.offset))
+			delete(i.handled, key)
+		}
+	}
+	return offsets
+}
+
+// releaseAll is release for all the partitions a message was handled from.
+func (i *ingesterImpl) releaseAll() []kafka.TopicPartition {
+	i.mu.Lock()
+	partitions := make([]Partition, 0, len(i.handled))
+	for key := range i.handled {
+		partitions = append(partitions, Partition{Topic: key.topic, Partition: key.partition})
+	}
+	i.mu.Unlock()
+	return i.release(partitions)
+}
+
+// commit commits the offsets and returns those committed.
+func (i *ingesterImpl) commit(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+	return i.consumer.CommitOffsets(offsets)
+}
+
+func topicPartition(topic string, partition int32, offset int64) kafka.TopicPartition {
+	return kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)}
+}
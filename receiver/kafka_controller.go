@@ -0,0 +1,350 @@
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// controlTimeoutMs bounds the requests to the brokers made on behalf of the admin API.
+const controlTimeoutMs = 5000
+
+// Controller inspects and steers the consumption of a running ingester, it is
+// safe to call from another goroutine than the one polling the messages.
+type Controller interface {
+	// Assignment returns the partitions assigned to the consumer.
+	Assignment() ([]PartitionState, error)
+	// Pause stops fetching the assigned partitions of the topic, or only one
+	// of them if partition is not nil. It returns the paused partitions.
+	Pause(topic string, partition *int32) ([]PartitionState, error)
+	// Resume is the inverse of Pause.
+	Resume(topic string, partition *int32) ([]PartitionState, error)
+	// Seek moves the next message consumed from the partition to the offset.
+	Seek(topic string, partition int32, offset int64) error
+	// SeekTimestamp moves the partition to the first message at or after the time,
+	// it returns the offset of that message.
+	SeekTimestamp(topic string, partition int32, timestamp time.Time) (int64, error)
+}
+
+// Throttler pauses the partitions on behalf of the rate limit. The throttled
+// partitions are tracked apart from those paused through the Controller, a
+// partition is consumed again once neither of them pauses it.
+type Throttler interface {
+	// Throttle pauses the assigned partition.
+	Throttle(topic string, partition int32) error
+	// Unthrottle is the inverse of Throttle.
+	Unthrottle(topic string, partition int32) error
+}
+
+// PartitionState is the position of the consumer in an assigned partition. The
+// offsets are negative if unknown, the lag is the count of messages not consumed yet.
+type PartitionState struct {
+	Source        string `json:"source,omitempty"`
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Position      int64  `json:"position"`
+	Committed     int64  `json:"committed"`
+	HighWatermark int64  `json:"highWatermark"`
+	Lag           int64  `json:"lag"`
+	Paused        bool   `json:"paused"`
+	// Throttled tells whether the rate limit pauses the partition.
+	Throttled bool `json:"throttled"`
+}
+
+// ErrNotAssigned is returned when the partitions to control are not assigned to the consumer.
+var ErrNotAssigned = errors.New("is not assigned to this consumer")
+
+// ErrNotControllable is returned when the ingester of a source cannot be controlled.
+var ErrNotControllable = errors.New("cannot be controlled")
+
+// sourcesController controls the consumers of all the sources, a partition is
+// controlled in every source it is assigned to.
+type sourcesController []*Source
+
+// NewSourcesController returns the controller of the sources.
+func NewSourcesController(sources []*Source) Controller {
+	return sourcesController(sources)
+}
+
+func (c sourcesController) Assignment() ([]PartitionState, error) {
+	var states []PartitionState
+	for _, source := range c {
+		controller, ok := source.Controller()
+		if !ok {
+			return nil, fmt.Errorf("source %s %w", source.Name, ErrNotControllable)
+		}
+		assignment, err := controller.Assignment()
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", source.Name, err)
+		}
+		for _, state := range assignment {
+			state.Source = source.Name
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+func (c sourcesController) Pause(topic string, partition *int32) ([]PartitionState, error) {
+	return c.each(func(controller Controller) ([]PartitionState, error) {
+		return controller.Pause(topic, partition)
+	})
+}
+
+func (c sourcesController) Resume(topic string, partition *int32) ([]PartitionState, error) {
+	return c.each(func(controller Controller) ([]PartitionState, error) {
+		return controller.Resume(topic, partition)
+	})
+}
+
+func (c sourcesController) Seek(topic string, partition int32, offset int64) error {
+	_, err := c.each(func(controller Controller) ([]PartitionState, error) {
+		return nil, controller.Seek(topic, partition, offset)
+	})
+	return err
+}
+
+func (c sourcesController) SeekTimestamp(topic string, partition int32, timestamp time.Time) (int64, error) {
+	var offset int64
+	_, err := c.each(func(controller Controller) ([]PartitionState, error) {
+		var err error
+		offset, err = controller.SeekTimestamp(topic, partition, timestamp)
+		return nil, err
+	})
+	return offset, err
+}
+
+// each applies the action to the sources the partitions are assigned to, it
+// fails if they are assigned to none of them. The sources which cannot be
+// controlled have no partition assigned.
+func (c sourcesController) each(action func(controller Controller) ([]PartitionState, error)) ([]PartitionState, error) {
+	var states []PartitionState
+	applied := false
+	err := ErrNotAssigned
+	for _, source := range c {
+		controller, ok := source.Controller()
+		if !ok {
+			if err == ErrNotAssigned {
+				err = fmt.Errorf("source %s %w", source.Name, ErrNotControllable)
+			}
+			continue
+		}
+		result, e := action(controller)
+		if errors.Is(e, ErrNotAssigned) {
+			err = e
+			continue
+		}
+		if e != nil {
+			return nil, fmt.Errorf("source %s: %w", source.Name, e)
+		}
+		applied = true
+		for _, state := range result {
+			state.Source = source.Name
+			states = append(states, state)
+		}
+	}
+	if !applied {
+		return nil, err
+	}
+	return states, nil
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+func (i *ingesterImpl) Assignment() ([]PartitionState, error) {
+	assignment, err := i.consumer.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	positions, err := i.consumer.Position(assignment)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := i.consumer.Committed(assignment, controlTimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	states := make([]PartitionState, 0, len(positions))
+	for index, position := range positions {
+		state := PartitionState{
+			Topic:         topicName(position),
+			Partition:     position.Partition,
+			Position:      int64(position.Offset),
+			Committed:     int64(kafka.OffsetInvalid),
+			HighWatermark: int64(kafka.OffsetInvalid),
+		}
+		if index < len(committed) {
+			state.Committed = int64(committed[index].Offset)
+		}
+		state.Paused = i.paused[partitionKey{state.Topic, state.Partition}]
+		state.Throttled = i.throttled[partitionKey{state.Topic, state.Partition}]
+		if _, high, err := i.consumer.GetWatermarkOffsets(state.Topic, state.Partition); err == nil && high >= 0 {
+			state.HighWatermark = high
+			switch {
+			case state.Position >= 0:
+				state.Lag = high - state.Position
+			case state.Committed >= 0:
+				state.Lag = high - state.Committed
+			}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (i *ingesterImpl) Pause(topic string, partition *int32) ([]PartitionState, error) {
+	return i.setPaused(topic, partition, true)
+}
+
+func (i *ingesterImpl) Resume(topic string, partition *int32) ([]PartitionState, error) {
+	return i.setPaused(topic, partition, false)
+}
+
+func (i *ingesterImpl) setPaused(topic string, partition *int32, paused bool) ([]PartitionState, error) {
+	partitions, err := i.assigned(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	// the throttled partitions stay paused until the rate limit resumes them
+	var changed []kafka.TopicPartition
+	for _, tp := range partitions {
+		if !i.throttled[partitionKey{topicName(tp), tp.Partition}] {
+			changed = append(changed, tp)
+		}
+	}
+	if len(changed) > 0 {
+		if paused {
+			err = i.consumer.Pause(changed)
+		} else {
+			err = i.consumer.Resume(changed)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]PartitionState, 0, len(partitions))
+	for _, tp := range partitions {
+		key := partitionKey{topicName(tp), tp.Partition}
+		if paused {
+			i.paused[key] = true
+		} else {
+			delete(i.paused, key)
+		}
+		states = append(states, PartitionState{Topic: key.topic, Partition: key.partition, Paused: paused, Throttled: i.throttled[key]})
+	}
+	i.sugar.Infow("Changed the consumption of the partitions.", "topic", topic, "partitions", len(partitions), "paused", paused)
+	return states, nil
+}
+
+func (i *ingesterImpl) Throttle(topic string, partition int32) error {
+	return i.setThrottled(topic, partition, true)
+}
+
+func (i *ingesterImpl) Unthrottle(topic string, partition int32) error {
+	return i.setThrottled(topic, partition, false)
+}
+
+func (i *ingesterImpl) setThrottled(topic string, partition int32, throttled bool) error {
+	partitions, err := i.assigned(topic, &partition)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	key := partitionKey{topic, partition}
+	if i.throttled[key] == throttled {
+		return nil
+	}
+	// the partitions paused through the Controller stay paused
+	if !i.paused[key] {
+		if throttled {
+			err = i.consumer.Pause(partitions)
+		} else {
+			err = i.consumer.Resume(partitions)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if throttled {
+		i.throttled[key] = true
+	} else {
+		delete(i.throttled, key)
+	}
+	i.sugar.Debugw("Changed the throttling of the partition.", "topic", topic, "partition", partition, "throttled", throttled)
+	return nil
+}
+
+func (i *ingesterImpl) Seek(topic string, partition int32, offset int64) error {
+	if _, err := i.assigned(topic, &partition); err != nil {
+		return err
+	}
+	if err := i.consumer.Seek(kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(offset)}, controlTimeoutMs); err != nil {
+		return err
+	}
+	i.sugar.Infow("Seeked the partition.", "topic", topic, "partition", partition, "offset", offset)
+	return nil
+}
+
+func (i *ingesterImpl) SeekTimestamp(topic string, partition int32, timestamp time.Time) (int64, error) {
+	if _, err := i.assigned(topic, &partition); err != nil {
+		return 0, err
+	}
+	times := []kafka.TopicPartition{{Topic: &topic, Partition: partition, Offset: kafka.Offset(timestamp.UnixNano() / int64(time.Millisecond))}}
+	offsets, err := i.consumer.OffsetsForTimes(times, controlTimeoutMs)
+	if err != nil {
+		return 0, err
+	}
+	if len(offsets) != 1 || offsets[0].Error != nil {
+		return 0, fmt.Errorf("failed to look up the offset of %s/%d at %s: %v", topic, partition, timestamp, offsets)
+	}
+	// the end offset is returned when no message is that recent
+	offset := int64(offsets[0].Offset)
+	if offsets[0].Offset == kafka.OffsetEnd {
+		if _, high, err := i.consumer.GetWatermarkOffsets(topic, partition); err == nil {
+			offset = high
+		}
+	}
+	return offset, i.Seek(topic, partition, offset)
+}
+
+// assigned returns the assigned partitions of the topic, or the partition if it is not nil.
+func (i *ingesterImpl) assigned(topic string, partition *int32) ([]kafka.TopicPartition, error) {
+	assignment, err := i.consumer.Assignment()
+	if err != nil {
+		return nil, err
+	}
+	var partitions []kafka.TopicPartition
+	for _, tp := range assignment {
+		if topicName(tp) == topic && (partition == nil || tp.Partition == *partition) {
+			partitions = append(partitions, tp)
+		}
+	}
+	if len(partitions) == 0 {
+		if partition != nil {
+			return nil, fmt.Errorf("the partition %s/%d %w", topic, *partition, ErrNotAssigned)
+		}
+		return nil, fmt.Errorf("the topic %s %w", topic, ErrNotAssigned)
+	}
+	return partitions, nil
+}
+
+func topicName(tp kafka.TopicPartition) string {
+	if tp.Topic == nil {
+		return ""
+	}
+	return *tp.Topic
+}
@@ -0,0 +1,95 @@
+package receiver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.uber.org/zap"
+)
+
+// plainIngester is an ingester which cannot be controlled, like the fakes of the tests.
+type plainIngester struct {
+	Ingester
+}
+
+// assignedConsumer has the partitions of a topic assigned.
+type assignedConsumer struct {
+	fakeConsumer
+	assignment []kafka.TopicPartition
+	paused     []kafka.TopicPartition
+	resumed    []kafka.TopicPartition
+}
+
+func (a *assignedConsumer) Assignment() ([]kafka.TopicPartition, error) {
+	return a.assignment, nil
+}
+
+func (a *assignedConsumer) Pause(partitions []kafka.TopicPartition) error {
+	a.paused = append(a.paused, partitions...)
+	return nil
+}
+
+func (a *assignedConsumer) Resume(partitions []kafka.TopicPartition) error {
+	a.resumed = append(a.resumed, partitions...)
+	return nil
+}
+
+func TestThrottleIsIndependentOfTheControllerPauses(t *testing.T) {
+	topic := "zipkin"
+	consumer := &assignedConsumer{assignment: []kafka.TopicPartition{{Topic: &topic, Partition: 0}, {Topic: &topic, Partition: 1}}}
+	ingester := newIngester(consumer, nil, zap.NewNop().Sugar())
+
+	if err := ingester.Throttle("zipkin", 0); err != nil || len(consumer.paused) != 1 {
+		t.Fatalf("Expected the partition to be paused, Actual: %v %v", consumer.paused, err)
+	}
+	// the admin API does not resume the throttled partition
+	states, err := ingester.Resume("zipkin", nil)
+	if err != nil || len(consumer.resumed) != 1 || consumer.resumed[0].Partition != 1 {
+		t.Errorf("Expected only the partition 1 to be resumed, Actual: %v %v", consumer.resumed, err)
+	}
+	if len(states) != 2 || !states[0].Throttled || states[1].Throttled {
+		t.Errorf("Expected the partition 0 to be reported as throttled, Actual: %v", states)
+	}
+
+	// the rate limit does not resume the partition paused through the admin API
+	if _, err := ingester.Pause("zipkin", nil); err != nil {
+		t.Fatal(err)
+	}
+	consumer.resumed = nil
+	if err := ingester.Unthrottle("zipkin", 0); err != nil || len(consumer.resumed) != 0 {
+		t.Errorf("Expected the partition to stay paused, Actual: %v %v", consumer.resumed, err)
+	}
+	if err := ingester.Throttle("zipkin", 2); !errors.Is(err, ErrNotAssigned) {
+		t.Errorf("Expected %v, Actual: %v", ErrNotAssigned, err)
+	}
+}
+
+func TestSourcesControllerSkipsTheIngestersWithoutController(t *testing.T) {
+	sugar := zap.NewNop().Sugar()
+	plain := NewSourceWithIngester(configure.Source{Name: "plain"}, plainIngester{}, sugar)
+	controller := NewSourcesController([]*Source{plain})
+
+	if _, err := controller.Assignment(); !errors.Is(err, ErrNotControllable) {
+		t.Errorf("Expected %v, Actual: %v", ErrNotControllable, err)
+	}
+	if _, err := controller.Pause("zipkin", nil); !errors.Is(err, ErrNotControllable) {
+		t.Errorf("Expected %v, Actual: %v", ErrNotControllable, err)
+	}
+	if err := controller.Seek("zipkin", 0, 1); !errors.Is(err, ErrNotControllable) {
+		t.Errorf("Expected %v, Actual: %v", ErrNotControllable, err)
+	}
+
+	topic := "zipkin"
+	consumer := &assignedConsumer{assignment: []kafka.TopicPartition{{Topic: &topic, Partition: 0}}}
+	kafkaSource := NewSourceWithIngester(configure.Source{Name: "kafka"}, newIngester(consumer, nil, sugar), sugar)
+	controller = NewSourcesController([]*Source{plain, kafkaSource})
+	states, err := controller.Pause("zipkin", nil)
+	if err != nil || len(states) != 1 || states[0].Source != "kafka" || len(consumer.paused) != 1 {
+		t.Errorf("Expected the partition of the kafka source to be paused, Actual: %v %v", states, err)
+	}
+	if _, err := controller.Pause("other", nil); !errors.Is(err, ErrNotAssigned) {
+		t.Errorf("Expected %v, Actual: %v", ErrNotAssigned, err)
+	}
+}
@@ -1,41 +1,129 @@
 package receiver
 
 import (
+	"sync"
+
 	"github.com/aliyun-sls/zipkin-ingester/configure"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"go.uber.org/zap"
 )
 
+// consumer is the part of the kafka consumer used by the ingester.
+type consumer interface {
+	Poll(timeoutMs int) kafka.Event
+	Assign(partitions []kafka.TopicPartition) error
+	Unassign() error
+	IncrementalAssign(partitions []kafka.TopicPartition) error
+	IncrementalUnassign(partitions []kafka.TopicPartition) error
+	Assignment() ([]kafka.TopicPartition, error)
+	AssignmentLost() bool
+	GetRebalanceProtocol() string
+	CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+	Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	GetWatermarkOffsets(topic string, partition int32) (low, high int64, err error)
+	OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+	Pause(partitions []kafka.TopicPartition) error
+	Resume(partitions []kafka.TopicPartition) error
+	Seek(partition kafka.TopicPartition, timeoutMs int) error
+	Close() error
+}
+
 type ingesterImpl struct {
-	consumer *kafka.Consumer
+	consumer     consumer
+	sugar        *zap.SugaredLogger
+	beforeRevoke RevokeHandler
+
+	// mu guards paused, the partitions paused through the Controller, throttled,
+	// those paused by the rate limit, and handled, the offsets following the
+	// messages handed to the pipeline.
+	mu          sync.Mutex
+	paused      map[partitionKey]bool
+	throttled   map[partitionKey]bool
+	handled     map[partitionKey]handledOffset
+	assignments uint64
 }
 
-func (i ingesterImpl) Close() {
-	i.consumer.Close()
+func newIngester(c consumer, beforeRevoke RevokeHandler, sugar *zap.SugaredLogger) *ingesterImpl {
+	return &ingesterImpl{
+		consumer:     c,
+		sugar:        sugar,
+		beforeRevoke: beforeRevoke,
+		paused:       make(map[partitionKey]bool),
+		throttled:    make(map[partitionKey]bool),
+		handled:      make(map[partitionKey]handledOffset),
+	}
 }
 
-func NewIngester(config *configure.Configuration, sugar *zap.SugaredLogger) (Ingester, error) {
-	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":  config.BootstrapServers,
-		"group.id":           config.GroupID,
-		"session.timeout.ms": 6000,
-		"auto.offset.reset":  config.AutoOffsetRest,
-	})
+// Close commits the offsets of the handled messages and leaves the consumer group,
+// it must be called once the exporters flushed the spans.
+func (i *ingesterImpl) Close() {
+	if partitions, err := i.commit(i.releaseAll()); err == nil {
+		for _, partition := range partitions {
+			i.sugar.Infow("Committed the final offset.", "topic", topicName(partition), "partition", partition.Partition, "offset", partition.Offset)
+		}
+	} else {
+		i.sugar.Warnw("Failed to commit the final offsets.", "exception", err)
+	}
+
+	if err := i.consumer.Close(); err != nil {
+		i.sugar.Warnw("Failed to leave the consumer group.", "exception", err)
+	} else {
+		i.sugar.Info("Left the consumer group.")
+	}
+}
+
+// NewIngester subscribes to the topics of the configuration, beforeRevoke is
+// called before partitions are revoked from the consumer. The offsets are not
+// committed automatically when the messages are polled, but through the Committer
+// once the spans of the handled messages are exported.
+func NewIngester(config *configure.Configuration, beforeRevoke RevokeHandler, sugar *zap.SugaredLogger) (Ingester, error) {
+	consumerConfig := &kafka.ConfigMap{
+		"bootstrap.servers":        config.BootstrapServers,
+		"group.id":                 config.GroupID,
+		"session.timeout.ms":       6000,
+		"auto.offset.reset":        config.AutoOffsetRest,
+		"enable.auto.commit":       false,
+		"enable.auto.offset.store": false,
+	}
+	optional := map[string]string{
+		"partition.assignment.strategy": config.Assignor,
+		"security.protocol":             config.SecurityProtocol,
+		"sasl.mechanisms":               config.SaslMechanism,
+		"sasl.username":                 config.SaslUsername,
+		"sasl.password":                 config.SaslPassword,
+		"ssl.ca.location":               config.SslCaLocation,
+	}
+	for key, value := range optional {
+		if value != "" {
+			_ = consumerConfig.SetKey(key, value)
+		}
+	}
+	c, err := kafka.NewConsumer(consumerConfig)
 
 	if err != nil {
 		sugar.Warnw("Failed to new kafka consumer.", "exception", err)
 		return nil, err
 	}
 
-	if e := c.SubscribeTopics(config.Topic, nil); e != nil {
+	ingester := newIngester(c, beforeRevoke, sugar)
+	if e := c.SubscribeTopics(config.Topic, ingester.rebalance); e != nil {
 		sugar.Warnw("Failed to subscribe topic.", "exception", e)
 		return nil, e
 	} else {
-		return &ingesterImpl{consumer: c}, nil
+		return ingester, nil
+	}
+}
+
+func (i *ingesterImpl) IngestTrace(suager *zap.SugaredLogger) ([]byte, error) {
+	msg, err := i.IngestMessage(suager)
+	if msg == nil {
+		return nil, err
 	}
+	return msg.Value, err
 }
 
-func (i ingesterImpl) IngestTrace(suager *zap.SugaredLogger) ([]byte, error) {
+func (i *ingesterImpl) IngestMessage(suager *zap.SugaredLogger) (*Message, error) {
 	ev := i.consumer.Poll(1000)
 	if ev == nil {
 		return nil, nil
@@ -43,7 +131,7 @@ func (i ingesterImpl) IngestTrace(suager *zap.SugaredLogger) ([]byte, error) {
 
 	switch e := ev.(type) {
 	case *kafka.Message:
-		return e.Value, nil
+		return toMessage(e), nil
 	case kafka.Error:
 		suager.Warnw("Receive a kafka error.", "Kafka error code", e.Code(), "exception", e)
 		if e.Code() == kafka.ErrAllBrokersDown {
@@ -54,3 +142,22 @@ func (i ingesterImpl) IngestTrace(suager *zap.SugaredLogger) ([]byte, error) {
 		return nil, nil
 	}
 }
+
+func toMessage(e *kafka.Message) *Message {
+	msg := &Message{
+		Partition: e.TopicPartition.Partition,
+		Offset:    int64(e.TopicPartition.Offset),
+		Timestamp: e.Timestamp,
+		Value:     e.Value,
+	}
+	if e.TopicPartition.Topic != nil {
+		msg.Topic = *e.TopicPartition.Topic
+	}
+	if len(e.Headers) > 0 {
+		msg.Headers = make(map[string]string, len(e.Headers))
+		for _, header := range e.Headers {
+			msg.Headers[header.Key] = string(header.Value)
+		}
+	}
+	return msg
+}
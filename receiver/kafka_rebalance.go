@@ -0,0 +1,108 @@
+package receiver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+const (
+	MetricPartitionsAssignedTotal = "zipkin_ingester_partitions_assigned_total"
+	MetricPartitionsRevokedTotal  = "zipkin_ingester_partitions_revoked_total"
+	MetricPartitionsLostTotal     = "zipkin_ingester_partitions_lost_total"
+
+	// AssignorCooperativeSticky moves only the partitions that change owner on
+	// rebalance, the other ones keep being consumed.
+	AssignorCooperativeSticky = "cooperative-sticky"
+
+	protocolCooperative = "COOPERATIVE"
+)
+
+// Partition identifies a partition of a topic.
+type Partition struct {
+	Topic     string
+	Partition int32
+}
+
+func (p Partition) String() string {
+	return fmt.Sprintf("%s/%d", p.Topic, p.Partition)
+}
+
+// RevokeHandler is called from the polling goroutine before partitions are revoked,
+// it must flush the spans consumed from them so that their offsets can be committed.
+type RevokeHandler func(partitions []Partition)
+
+func (i *ingesterImpl) rebalance(_ *kafka.Consumer, event kafka.Event) error {
+	switch e := event.(type) {
+	case kafka.AssignedPartitions:
+		return i.assign(e.Partitions)
+	case kafka.RevokedPartitions:
+		return i.revoke(e.Partitions)
+	default:
+		return nil
+	}
+}
+
+func (i *ingesterImpl) assign(partitions []kafka.TopicPartition) error {
+	cooperative := i.consumer.GetRebalanceProtocol() == protocolCooperative
+	countPartitions(MetricPartitionsAssignedTotal, partitions)
+	i.sugar.Infow("Partitions assigned.", "partitions", toPartitions(partitions), "cooperative", cooperative)
+	if cooperative {
+		return i.consumer.IncrementalAssign(partitions)
+	}
+	return i.consumer.Assign(partitions)
+}
+
+// revoke flushes the pending spans and commits the offsets of the messages
+// handled from the revoked partitions, unless they were lost and may already be
+// consumed by another member.
+func (i *ingesterImpl) revoke(partitions []kafka.TopicPartition) error {
+	start := time.Now()
+	revoked := toPartitions(partitions)
+	if i.consumer.AssignmentLost() {
+		i.release(revoked)
+		countPartitions(MetricPartitionsLostTotal, partitions)
+		i.sugar.Warnw("Partitions lost, their offsets are not committed.", "partitions", revoked)
+	} else {
+		if i.beforeRevoke != nil {
+			i.beforeRevoke(revoked)
+		}
+		if committed, err := i.commit(i.release(revoked)); err != nil {
+			i.sugar.Warnw("Failed to commit the offsets of the revoked partitions.", "exception", err)
+		} else {
+			for _, partition := range committed {
+				i.sugar.Infow("Committed the offset of the revoked partition.", "topic", topicName(partition), "partition", partition.Partition, "offset", partition.Offset)
+			}
+		}
+		countPartitions(MetricPartitionsRevokedTotal, partitions)
+		i.sugar.Infow("Partitions revoked.", "partitions", revoked, "elapsed", time.Since(start))
+	}
+
+	i.mu.Lock()
+	for _, partition := range revoked {
+		delete(i.paused, partitionKey{partition.Topic, partition.Partition})
+		delete(i.throttled, partitionKey{partition.Topic, partition.Partition})
+	}
+	i.mu.Unlock()
+
+	if i.consumer.GetRebalanceProtocol() == protocolCooperative {
+		return i.consumer.IncrementalUnassign(partitions)
+	}
+	return i.consumer.Unassign()
+}
+
+func toPartitions(partitions []kafka.TopicPartition) []Partition {
+	result := make([]Partition, 0, len(partitions))
+	for _, tp := range partitions {
+		result = append(result, Partition{Topic: topicName(tp), Partition: tp.Partition})
+	}
+	return result
+}
+
+func countPartitions(name string, partitions []kafka.TopicPartition) {
+	for _, tp := range partitions {
+		metrics.NewCounter(name, map[string]string{"topic": topicName(tp)}).Inc()
+	}
+}
@@ -0,0 +1,130 @@
+package receiver
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.uber.org/zap"
+)
+
+// fakeConsumer records the commits of the ingester, the other calls are not expected.
+type fakeConsumer struct {
+	consumer
+	lost       bool
+	committed  []string
+	unassigned bool
+}
+
+func (f *fakeConsumer) AssignmentLost() bool {
+	return f.lost
+}
+
+func (f *fakeConsumer) GetRebalanceProtocol() string {
+	return "EAGER"
+}
+
+func (f *fakeConsumer) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	for _, offset := range offsets {
+		f.committed = append(f.committed, fmt.Sprintf("%s/%d@%d", topicName(offset), offset.Partition, offset.Offset))
+	}
+	sort.Strings(f.committed)
+	return offsets, nil
+}
+
+func (f *fakeConsumer) Unassign() error {
+	f.unassigned = true
+	return nil
+}
+
+func (f *fakeConsumer) Close() error {
+	return nil
+}
+
+func handle(ingester *ingesterImpl, topic string, partition int32, offsets ...int64) {
+	for _, offset := range offsets {
+		ingester.Handled(&Message{Topic: topic, Partition: partition, Offset: offset})
+	}
+}
+
+func TestRevokeCommitsTheHandledOffsetsAfterTheDrain(t *testing.T) {
+	fake := &fakeConsumer{}
+	var drained []Partition
+	ingester := newIngester(fake, func(partitions []Partition) {
+		if len(fake.committed) != 0 {
+			t.Errorf("Expected the drain before the commit, Actual: %v committed", fake.committed)
+		}
+		drained = partitions
+	}, zap.NewNop().Sugar())
+	handle(ingester, "zipkin", 0, 5, 6)
+	handle(ingester, "zipkin", 1, 9)
+
+	if err := ingester.revoke([]kafka.TopicPartition{topicPartition("zipkin", 0, int64(kafka.OffsetInvalid))}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Partition{{Topic: "zipkin", Partition: 0}}; !reflect.DeepEqual(drained, expected) {
+		t.Errorf("Expected %v to be drained, Actual: %v", expected, drained)
+	}
+	if expected := []string{"zipkin/0@7"}; !reflect.DeepEqual(fake.committed, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, fake.committed)
+	}
+	if !fake.unassigned {
+		t.Error("Expected the partitions to be unassigned")
+	}
+
+	ingester.Close()
+	if expected := []string{"zipkin/0@7", "zipkin/1@10"}; !reflect.DeepEqual(fake.committed, expected) {
+		t.Errorf("Expected the partition 1 to be committed on close, Actual: %v", fake.committed)
+	}
+}
+
+func TestRevokeDoesNotCommitTheLostPartitions(t *testing.T) {
+	fake := &fakeConsumer{lost: true}
+	ingester := newIngester(fake, func([]Partition) {
+		t.Error("Expected the lost partitions not to be drained")
+	}, zap.NewNop().Sugar())
+	handle(ingester, "zipkin", 0, 5)
+
+	if err := ingester.revoke([]kafka.TopicPartition{topicPartition("zipkin", 0, int64(kafka.OffsetInvalid))}); err != nil {
+		t.Fatal(err)
+	}
+	ingester.Close()
+	if len(fake.committed) != 0 {
+		t.Errorf("Expected no commit, Actual: %v", fake.committed)
+	}
+}
+
+func TestCommitSkipsThePartitionsRevokedSinceTheCheckpoint(t *testing.T) {
+	fake := &fakeConsumer{}
+	ingester := newIngester(fake, nil, zap.NewNop().Sugar())
+	handle(ingester, "zipkin", 0, 5)
+	handle(ingester, "zipkin", 1, 9)
+	checkpoint := ingester.Checkpoint()
+	handle(ingester, "zipkin", 1, 10)
+
+	if err := ingester.revoke([]kafka.TopicPartition{topicPartition("zipkin", 0, int64(kafka.OffsetInvalid))}); err != nil {
+		t.Fatal(err)
+	}
+	// the partition is assigned again and consumed from the committed offset
+	handle(ingester, "zipkin", 0, 6)
+	fake.committed = nil
+
+	ingester.Commit(checkpoint)
+	if expected := []string{"zipkin/1@10"}; !reflect.DeepEqual(fake.committed, expected) {
+		t.Errorf("Expected only the checkpoint of the partition 1, Actual: %v", fake.committed)
+	}
+}
+
+func TestCloseDoesNotCommitTheAbandonedOffsets(t *testing.T) {
+	fake := &fakeConsumer{}
+	ingester := newIngester(fake, nil, zap.NewNop().Sugar())
+	handle(ingester, "zipkin", 0, 5)
+
+	ingester.Abandon()
+	ingester.Close()
+	if len(fake.committed) != 0 {
+		t.Errorf("Expected no commit, Actual: %v", fake.committed)
+	}
+}
@@ -0,0 +1,175 @@
+package receiver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	MetricSourceMessagesTotal = "zipkin_ingester_source_messages_total"
+	MetricSourceBytesTotal    = "zipkin_ingester_source_bytes_total"
+	MetricSourceSpansTotal    = "zipkin_ingester_source_spans_total"
+	MetricSourceErrorsTotal   = "zipkin_ingester_source_errors_total"
+)
+
+// SourceMessage is a message consumed by a source, PollStart is when the poll returning it started.
+type SourceMessage struct {
+	*Message
+	Source    *Source
+	PollStart time.Time
+}
+
+// Source polls the kafka consumer of a source definition in its own goroutine.
+type Source struct {
+	configure.Source
+	ingester Ingester
+	stop     chan struct{}
+	done     chan struct{}
+	sugar    *zap.SugaredLogger
+
+	messages *metrics.Counter
+	bytes    *metrics.Counter
+	spans    *metrics.Counter
+	errors   *metrics.Counter
+}
+
+// NewSource subscribes to the topics of the effective source, beforeRevoke is
+// called from the polling goroutine of the source before partitions are revoked.
+func NewSource(config *configure.Configuration, source configure.Source, beforeRevoke RevokeHandler, sugar *zap.SugaredLogger) (*Source, error) {
+	sugar = sugar.With("source", source.Name)
+	ingester, err := NewIngester(config.ForSource(source), beforeRevoke, sugar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume the source %s: %w", source.Name, err)
+	}
+	return NewSourceWithIngester(source, ingester, sugar), nil
+}
+
+// NewSourceWithIngester polls the ingester as the source, e.g. a fake one in tests.
+func NewSourceWithIngester(source configure.Source, ingester Ingester, sugar *zap.SugaredLogger) *Source {
+	labels := map[string]string{"source": source.Name}
+	return &Source{
+		Source:   source,
+		ingester: ingester,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		sugar:    sugar,
+		messages: metrics.NewCounter(MetricSourceMessagesTotal, labels),
+		bytes:    metrics.NewCounter(MetricSourceBytesTotal, labels),
+		spans:    metrics.NewCounter(MetricSourceSpansTotal, labels),
+		errors:   metrics.NewCounter(MetricSourceErrorsTotal, labels),
+	}
+}
+
+// Start polls the source until Stop is called, every message is sent to the channel.
+func (s *Source) Start(messages chan<- SourceMessage) {
+	go func() {
+		defer close(s.done)
+		for {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+
+			pollStart := time.Now()
+			msg, err := s.ingester.IngestMessage(s.sugar)
+			if err != nil {
+				s.errors.Inc()
+				continue
+			}
+			if msg == nil || len(msg.Value) == 0 {
+				continue
+			}
+			s.messages.Inc()
+			s.bytes.Add(uint64(len(msg.Value)))
+			// the message is always delivered, the receiver keeps reading until Done
+			messages <- SourceMessage{Message: msg, Source: s, PollStart: pollStart}
+		}
+	}()
+}
+
+// Stop asks the polling goroutine to stop, the messages must be received until Done is closed.
+func (s *Source) Stop() {
+	close(s.stop)
+}
+
+// Done is closed once the source stopped polling.
+func (s *Source) Done() <-chan struct{} {
+	return s.done
+}
+
+// Decoded counts the spans decoded from a message of the source, or its decoding error.
+func (s *Source) Decoded(spans int, err error) {
+	if err != nil {
+		s.errors.Inc()
+		return
+	}
+	s.spans.Add(uint64(spans))
+}
+
+// Handled records that the spans of the message were handed to the pipeline,
+// its offset is committed with a later checkpoint.
+func (s *Source) Handled(msg *Message) {
+	if committer, ok := s.ingester.(Committer); ok {
+		committer.Handled(msg)
+	}
+}
+
+// Checkpoint returns the offsets following the messages handled so far, they
+// are committed once their spans are exported.
+func (s *Source) Checkpoint() []Offset {
+	if committer, ok := s.ingester.(Committer); ok {
+		return committer.Checkpoint()
+	}
+	return nil
+}
+
+// Commit commits the offsets of a checkpoint whose spans are exported.
+func (s *Source) Commit(offsets []Offset) {
+	if committer, ok := s.ingester.(Committer); ok && len(offsets) > 0 {
+		committer.Commit(offsets)
+	}
+}
+
+// Abandon forgets the offsets of the handled messages, Close then leaves the
+// consumer group without committing them.
+func (s *Source) Abandon() {
+	if committer, ok := s.ingester.(Committer); ok {
+		committer.Abandon()
+	}
+}
+
+// Close commits the offsets and leaves the consumer group, it must be called
+// once the source stopped and the exporters flushed the spans.
+func (s *Source) Close() {
+	s.ingester.Close()
+}
+
+// Throttle pauses the partition on behalf of the rate limit, see Throttler.
+func (s *Source) Throttle(topic string, partition int32) error {
+	throttler, ok := s.ingester.(Throttler)
+	if !ok {
+		return fmt.Errorf("source %s %w", s.Name, ErrNotControllable)
+	}
+	return throttler.Throttle(topic, partition)
+}
+
+// Unthrottle resumes the partition paused by Throttle.
+func (s *Source) Unthrottle(topic string, partition int32) error {
+	throttler, ok := s.ingester.(Throttler)
+	if !ok {
+		return fmt.Errorf("source %s %w", s.Name, ErrNotControllable)
+	}
+	return throttler.Unthrottle(topic, partition)
+}
+
+// Controller steers the consumer of the source, ok is false if its ingester
+// cannot be controlled.
+func (s *Source) Controller() (controller Controller, ok bool) {
+	controller, ok = s.ingester.(Controller)
+	return controller, ok
+}
@@ -1,8 +1,23 @@
 package receiver
 
-import "go.uber.org/zap"
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
 
 type Ingester interface {
 	IngestTrace(*zap.SugaredLogger) ([]byte, error)
+	IngestMessage(*zap.SugaredLogger) (*Message, error)
 	Close()
 }
+
+// Message is a record consumed from the source together with its metadata.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Headers   map[string]string
+	Value     []byte
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aliyun-sls/zipkin-ingester/admin"
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"go.uber.org/zap"
+)
+
+// reloader swaps the pipeline when the configuration is reloaded. It is only
+// called from the consuming loop, so the swap happens between two batches.
+type reloader struct {
+	sugar *zap.SugaredLogger
+	// admin shows the configuration in use, it is nil if the admin API is disabled.
+	admin *admin.Server
+	// retiring waits for the replaced pipelines to flush before the offsets are
+	// committed, pending counts them.
+	retiring sync.WaitGroup
+	pending  int32
+}
+
+// reload builds a pipeline from the new configuration, it keeps the current
+// pipeline if the configuration is invalid.
+func (r *reloader) reload(current *pipeline) *pipeline {
+	config, err := loadConfiguration()
+	if err != nil {
+		r.sugar.Warnw("Rejected the new configuration, keep the current one.", "exception", err)
+		return current
+	}
+	return r.apply(current, config)
+}
+
+// apply replaces the current pipeline by one built from the configuration. The
+// converters and the exporters are rebuilt, the processors whose settings did not
+// change are handed over with their state.
+func (r *reloader) apply(current *pipeline, config *configure.Configuration) *pipeline {
+	if restartRequired(current.config, config) {
+		r.sugar.Warn("The kafka settings changed, they take effect after a restart.")
+	}
+	if current.config.LoggingConfig() != config.LoggingConfig() {
+		r.sugar.Warn("The logging settings changed, only the log level takes effect before a restart.")
+	}
+
+	next, err := newPipeline(config, current, r.sugar)
+	if err != nil {
+		r.sugar.Warnw("Failed to apply the new configuration, keep the current one.", "exception", err)
+		return current
+	}
+	current.handOver(next)
+	setLogLevel(config.LogLevel)
+	if r.admin != nil {
+		r.admin.SetConfig(config)
+	}
+
+	r.retiring.Add(1)
+	atomic.AddInt32(&r.pending, 1)
+	go func() {
+		defer r.retiring.Done()
+		current.close(r.sugar)
+		atomic.AddInt32(&r.pending, -1)
+	}()
+	r.sugar.Infow("Configuration reloaded.",
+		"Protocol", config.Protocol,
+		"TimestampPolicy", config.TimestampPolicy,
+		"Exporters", config.Exporters,
+		"Routes", len(config.Routes),
+		"KeptProcessors", keptStages(current.stages, next.stages),
+		"LogLevel", config.LogLevel,
+	)
+	return next
+}
+
+// flushing returns the count of replaced pipelines which are still flushing.
+func (r *reloader) flushing() int {
+	return int(atomic.LoadInt32(&r.pending))
+}
+
+// restartRequired tells whether the settings of the consumers changed, the
+// consumers are not recreated on reload to avoid a rebalance of the groups.
+// The protocol and the route of the sources are applied on reload.
+func restartRequired(current, next *configure.Configuration) bool {
+	return !reflect.DeepEqual(consumerSettings(current), consumerSettings(next))
+}
+
+func consumerSettings(config *configure.Configuration) []configure.Source {
+	sources := config.EffectiveSources()
+	for i := range sources {
+		sources[i].Protocol = ""
+		sources[i].Route = ""
+	}
+	return sources
+}
+
+// watch triggers a reload whenever the configuration file changes.
+func watch(path string, reloads chan<- struct{}) {
+	configure.Watch(path, func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	"github.com/aliyun-sls/zipkin-ingester/slstest"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"go.uber.org/zap"
+)
+
+func stageProcessor(p *pipeline, name string) processor.Processor {
+	for _, s := range p.stages {
+		if s.name == name {
+			return s.processor
+		}
+	}
+	return nil
+}
+
+func TestReloadKeepsTheUnchangedProcessors(t *testing.T) {
+	server := slstest.NewServer(slstest.Options{})
+	defer server.Close()
+	config := &configure.Configuration{
+		Project:          "project",
+		Instance:         "instance",
+		Endpoint:         server.Endpoint,
+		AccessKey:        "test-id",
+		AccessSecret:     "test-secret",
+		LogLevel:         "error",
+		ShutdownTimeout:  time.Second,
+		DedupEnabled:     true,
+		ClockSkewEnabled: true,
+		ClockSkewWindow:  time.Minute,
+		RateLimitSpans:   100,
+	}
+	sugar := zap.NewNop().Sugar()
+	current, err := newPipeline(config, nil, sugar)
+	if err != nil {
+		t.Fatalf("Failed to create the pipeline. %v", err)
+	}
+	span := &zipkinmodel.SpanModel{SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: 1}}
+	current.processors.Process(&processor.Batch{Topic: "zipkin", Spans: []*zipkinmodel.SpanModel{span}})
+
+	changed := *config
+	changed.RateLimitSpans = 200
+	r := &reloader{sugar: sugar}
+	next := r.apply(current, &changed)
+	if next == current {
+		t.Fatal("Expected a new pipeline")
+	}
+	for _, name := range []string{"dedup", "clockSkew"} {
+		if stageProcessor(next, name) != stageProcessor(current, name) {
+			t.Errorf("Expected the %s processor to be kept", name)
+		}
+	}
+	if stageProcessor(next, "rateLimit") == stageProcessor(current, "rateLimit") {
+		t.Error("Expected the rate limit processor to be rebuilt")
+	}
+	if next.exporter == current.exporter {
+		t.Error("Expected the exporters to be rebuilt")
+	}
+
+	// the replaced pipeline is closed without flushing the trace kept by the next one
+	r.retiring.Wait()
+	batches := next.processors.Flush(true)
+	if len(batches) != 1 || len(batches[0].Spans) != 1 || batches[0].Spans[0] != span {
+		t.Errorf("Expected the buffered span to be released by the next pipeline, Actual: %v", batches)
+	}
+	if logs := server.Logs("project", "instance-traces"); len(logs) != 0 {
+		t.Errorf("Expected the replaced pipeline not to export the buffered span, Actual: %d logs", len(logs))
+	}
+	next.close(sugar)
+}
+
+func TestRestartRequired(t *testing.T) {
+	config := &configure.Configuration{
+		BootstrapServers: "kafka:9092",
+		GroupID:          "ingester",
+		Sources: []configure.Source{
+			{Name: "legacy", Topics: []string{"zipkin"}, Protocol: "json"},
+		},
+	}
+
+	reconverted := *config
+	reconverted.Sources = []configure.Source{{Name: "legacy", Topics: []string{"zipkin"}, Protocol: "protobuf", Route: "prod"}}
+	if restartRequired(config, &reconverted) {
+		t.Error("Expected the protocol and the route to be applied on reload")
+	}
+
+	resubscribed := *config
+	resubscribed.Sources = []configure.Source{{Name: "legacy", Topics: []string{"zipkin", "traces"}, Protocol: "json"}}
+	if !restartRequired(config, &resubscribed) {
+		t.Error("Expected the topics to require a restart")
+	}
+
+	regrouped := *config
+	regrouped.GroupID = "other"
+	if !restartRequired(config, &regrouped) {
+		t.Error("Expected the consumer group to require a restart")
+	}
+}
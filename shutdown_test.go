@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithinAbandonsTheStepsAfterTheDeadline(t *testing.T) {
+	deadline := time.Now().Add(100 * time.Millisecond)
+	if !within(deadline, func() {}) {
+		t.Error("Expected the step to complete")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	if within(deadline, func() { <-release }) {
+		t.Error("Expected the blocked step to be abandoned")
+	}
+	// the next steps only get the time left
+	if within(deadline, func() { time.Sleep(10 * time.Millisecond) }) {
+		t.Error("Expected the step after the deadline to be abandoned")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the steps to share the deadline, Actual: %s", elapsed)
+	}
+}
@@ -0,0 +1,300 @@
+// Package slstest is an in-process fake of the SLS PutLogs API, the SLS clients
+// and producers are pointed at it instead of a real project to test the exporters
+// without an Aliyun account.
+package slstest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pierrec/lz4"
+)
+
+const (
+	// waitPollInterval is how often WaitForLogs checks the received logs.
+	waitPollInterval = 5 * time.Millisecond
+	// maxBodyRawSize bounds the decompressed size of a request, as the real service does.
+	maxBodyRawSize = 10 << 20
+)
+
+// Options are the checks of the fake server.
+type Options struct {
+	// AccessKeys are the secrets of the access key ids. The signatures of the
+	// requests are only checked if it is not empty.
+	AccessKeys map[string]string
+}
+
+// Server is a fake SLS endpoint storing the log groups sent by PutLogs.
+type Server struct {
+	// Endpoint is the endpoint of the SLS clients, e.g. 127.0.0.1:34567. As with
+	// the real service the project is the first label of the request host, the
+	// SDK reaches it by using the IP endpoint as a proxy.
+	Endpoint string
+
+	server     *httptest.Server
+	accessKeys map[string]string
+	requestID  int64
+
+	mu        sync.Mutex
+	logGroups map[string][]*slsSdk.LogGroup
+	rejected  []error
+}
+
+// NewServer starts a fake server listening on a local port, it must be closed.
+func NewServer(options Options) *Server {
+	s := &Server{
+		accessKeys: options.AccessKeys,
+		logGroups:  make(map[string][]*slsSdk.LogGroup),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.Endpoint = strings.TrimPrefix(s.server.URL, "http://")
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// LogGroups returns the log groups received by the logstore of the project.
+func (s *Server) LogGroups(project string, logstore string) []*slsSdk.LogGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*slsSdk.LogGroup(nil), s.logGroups[key(project, logstore)]...)
+}
+
+// Logs returns the logs received by the logstore of the project, in the order of the requests.
+func (s *Server) Logs(project string, logstore string) []*slsSdk.Log {
+	var logs []*slsSdk.Log
+	for _, lg := range s.LogGroups(project, logstore) {
+		logs = append(logs, lg.Logs...)
+	}
+	return logs
+}
+
+// WaitForLogs waits until the logstore of the project received at least count
+// logs, it returns the logs received before the timeout otherwise.
+func (s *Server) WaitForLogs(project string, logstore string, count int, timeout time.Duration) []*slsSdk.Log {
+	deadline := time.Now().Add(timeout)
+	for {
+		logs := s.Logs(project, logstore)
+		if len(logs) >= count || time.Now().After(deadline) {
+			return logs
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// Rejected returns the errors of the requests rejected so far.
+func (s *Server) Rejected() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.rejected...)
+}
+
+// Contents returns the contents of the log by key.
+func Contents(log *slsSdk.Log) map[string]string {
+	contents := make(map[string]string, len(log.Contents))
+	for _, content := range log.Contents {
+		contents[content.GetKey()] = content.GetValue()
+	}
+	return contents
+}
+
+func key(project string, logstore string) string {
+	return project + "/" + logstore
+}
+
+// Error is the error response of the API.
+type Error struct {
+	HTTPCode     int    `json:"-"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.HTTPCode, e.ErrorCode, e.ErrorMessage)
+}
+
+func newError(httpCode int, errorCode string, format string, args ...interface{}) *Error {
+	return &Error{HTTPCode: httpCode, ErrorCode: errorCode, ErrorMessage: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	requestID := fmt.Sprintf("%016X", atomic.AddInt64(&s.requestID, 1))
+	w.Header().Set("x-log-requestid", requestID)
+	if err := s.putLogs(r); err != nil {
+		s.mu.Lock()
+		s.rejected = append(s.rejected, err)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(err.HTTPCode)
+		_ = json.NewEncoder(w).Encode(err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// putLogs stores the log group of a PutLogs request, PostLogStoreLogs requests
+// to a shard are accepted as well.
+func (s *Server) putLogs(r *http.Request) *Error {
+	project := strings.TrimSuffix(r.Host, "."+s.Endpoint)
+	if project == r.Host || project == "" {
+		return newError(http.StatusNotFound, "ProjectNotExist", "the host %s has no project", r.Host)
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodPost || parts[0] != "logstores" || (len(parts) != 2 && (len(parts) != 4 || parts[2] != "shards")) {
+		return newError(http.StatusNotImplemented, "NotImplemented", "%s %s is not supported by the fake", r.Method, r.URL.Path)
+	}
+	logstore := parts[1]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return newError(http.StatusBadRequest, "PostBodyInvalid", "failed to read the body: %v", err)
+	}
+	if md5Header := r.Header.Get("Content-MD5"); md5Header != "" && !strings.EqualFold(md5Header, fmt.Sprintf("%X", md5.Sum(body))) {
+		return newError(http.StatusBadRequest, "InvalidContentMD5", "the Content-MD5 %s does not match the body", md5Header)
+	}
+	if err := s.authorize(r); err != nil {
+		return err
+	}
+	raw, err := decompress(body, r.Header.Get("x-log-compresstype"), r.Header.Get("x-log-bodyrawsize"))
+	if err != nil {
+		return newError(http.StatusBadRequest, "PostBodyUncompressError", "%v", err)
+	}
+	lg := &slsSdk.LogGroup{}
+	if err := proto.Unmarshal(raw, lg); err != nil {
+		return newError(http.StatusBadRequest, "PostBodyInvalid", "failed to decode the log group: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logGroups[key(project, logstore)] = append(s.logGroups[key(project, logstore)], lg)
+	return nil
+}
+
+// decompress decompresses the body to its raw size, the compression is lz4, deflate or none.
+func decompress(body []byte, compressType string, bodyRawSize string) ([]byte, error) {
+	size, err := strconv.Atoi(bodyRawSize)
+	if err != nil || size < 0 || size > maxBodyRawSize {
+		return nil, fmt.Errorf("invalid x-log-bodyrawsize %q", bodyRawSize)
+	}
+	var raw []byte
+	switch strings.ToLower(compressType) {
+	case "":
+		raw = body
+	case "lz4":
+		raw = make([]byte, size)
+		n, err := lz4.UncompressBlock(body, raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to uncompress the lz4 body: %w", err)
+		}
+		raw = raw[:n]
+	case "deflate":
+		reader, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to uncompress the deflate body: %w", err)
+		}
+		if raw, err = ioutil.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("failed to uncompress the deflate body: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown x-log-compresstype %q", compressType)
+	}
+	if len(raw) != size {
+		return nil, fmt.Errorf("the raw size is %d, the x-log-bodyrawsize %d", len(raw), size)
+	}
+	return raw, nil
+}
+
+// authorize checks the hmac-sha1 signature of the Authorization header, if the
+// server has access keys.
+func (s *Server) authorize(r *http.Request) *Error {
+	if len(s.accessKeys) == 0 {
+		return nil
+	}
+	scheme, credential := "", r.Header.Get("Authorization")
+	if i := strings.IndexByte(credential, ' '); i >= 0 {
+		scheme, credential = credential[:i], credential[i+1:]
+	}
+	i := strings.LastIndexByte(credential, ':')
+	if (scheme != "SLS" && scheme != "LOG") || i < 0 {
+		return newError(http.StatusUnauthorized, "Unauthorized", "invalid Authorization header %q", r.Header.Get("Authorization"))
+	}
+	accessKeyID, digest := credential[:i], credential[i+1:]
+	secret, ok := s.accessKeys[accessKeyID]
+	if !ok {
+		return newError(http.StatusUnauthorized, "Unauthorized", "unknown access key id %q", accessKeyID)
+	}
+	if expected := signature(secret, r); !hmac.Equal([]byte(digest), []byte(expected)) {
+		return newError(http.StatusUnauthorized, "SignatureNotMatch", "the signature %s does not match", digest)
+	}
+	return nil
+}
+
+// signature is the digest of the request as computed by the SDK.
+func signature(secret string, r *http.Request) string {
+	var keys []string
+	headers := make(map[string]string)
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-log-") || strings.HasPrefix(name, "x-acs-") {
+			keys = append(keys, name)
+			headers[name] = strings.TrimSpace(values[0])
+		}
+	}
+	sort.Strings(keys)
+	canonicalHeaders := make([]string, 0, len(keys))
+	for _, name := range keys {
+		canonicalHeaders = append(canonicalHeaders, name+":"+headers[name])
+	}
+
+	resource := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		query, _ := url.ParseQuery(r.URL.RawQuery)
+		params := make([]string, 0, len(query))
+		for name := range query {
+			params = append(params, name)
+		}
+		sort.Strings(params)
+		// the values of a repeated parameter are concatenated, as the SDK does
+		for i, name := range params {
+			var param string
+			for _, value := range query[name] {
+				param += name + "=" + value
+			}
+			params[i] = param
+		}
+		resource += "?" + strings.Join(params, "&")
+	}
+
+	signed := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		r.Header.Get("Date"),
+		strings.Join(canonicalHeaders, "\n"),
+		resource,
+	}, "\n")
+	mac := hmac.New(sha1.New, []byte(secret))
+	_, _ = mac.Write([]byte(signed))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,157 @@
+package slstest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	slsSdk "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+)
+
+var testAccessKeys = map[string]string{"test-id": "test-secret"}
+
+func testLogGroup(values ...string) *slsSdk.LogGroup {
+	lg := &slsSdk.LogGroup{Topic: proto.String("0.0.0.0"), Source: proto.String("")}
+	for _, value := range values {
+		lg.Logs = append(lg.Logs, &slsSdk.Log{
+			Time:     proto.Uint32(1659409534),
+			Contents: []*slsSdk.LogContent{{Key: proto.String("name"), Value: proto.String(value)}},
+		})
+	}
+	return lg
+}
+
+func names(logs []*slsSdk.Log) []string {
+	var values []string
+	for _, log := range logs {
+		values = append(values, Contents(log)["name"])
+	}
+	return values
+}
+
+func TestServerReceivesTheLogsOfTheSDK(t *testing.T) {
+	server := NewServer(Options{AccessKeys: testAccessKeys})
+	defer server.Close()
+	client := &slsSdk.Client{Endpoint: server.Endpoint, AccessKeyID: "test-id", AccessKeySecret: "test-secret"}
+
+	if err := client.PutLogs("project", "traces", testLogGroup("lz4", "compressed")); err != nil {
+		t.Fatalf("Failed to put the lz4 logs. %v", err)
+	}
+	if err := client.PutLogsWithCompressType("project", "traces", testLogGroup("raw"), slsSdk.Compress_None); err != nil {
+		t.Fatalf("Failed to put the raw logs. %v", err)
+	}
+	hashKey := "00000000000000000000000000000000"
+	if err := client.PostLogStoreLogs("project", "traces", testLogGroup("hashed"), &hashKey); err != nil {
+		t.Fatalf("Failed to post the logs to a shard. %v", err)
+	}
+	if err := client.PutLogs("other", "traces", testLogGroup("other project")); err != nil {
+		t.Fatalf("Failed to put the logs of another project. %v", err)
+	}
+
+	if actual := fmt.Sprint(names(server.Logs("project", "traces"))); actual != "[lz4 compressed raw hashed]" {
+		t.Errorf("Expected the logs of the 3 requests, Actual: %s", actual)
+	}
+	if groups := server.LogGroups("project", "traces"); len(groups) != 3 || groups[0].GetTopic() != "0.0.0.0" {
+		t.Errorf("Expected 3 log groups, Actual: %v", groups)
+	}
+	if actual := fmt.Sprint(names(server.Logs("other", "traces"))); actual != "[other project]" {
+		t.Errorf("Expected the logs of the other project, Actual: %s", actual)
+	}
+	if rejected := server.Rejected(); len(rejected) != 0 {
+		t.Errorf("Expected no rejected request, Actual: %v", rejected)
+	}
+}
+
+func TestServerChecksTheSignature(t *testing.T) {
+	server := NewServer(Options{AccessKeys: testAccessKeys})
+	defer server.Close()
+
+	for _, client := range []*slsSdk.Client{
+		{Endpoint: server.Endpoint, AccessKeyID: "test-id", AccessKeySecret: "wrong-secret"},
+		{Endpoint: server.Endpoint, AccessKeyID: "unknown-id", AccessKeySecret: "test-secret"},
+	} {
+		err := client.PutLogs("project", "traces", testLogGroup("rejected"))
+		if slsErr, ok := err.(*slsSdk.Error); !ok || slsErr.HTTPCode != http.StatusUnauthorized {
+			t.Errorf("Expected an unauthorized error, Actual: %v", err)
+		}
+	}
+	if logs := server.Logs("project", "traces"); len(logs) != 0 {
+		t.Errorf("Expected no logs, Actual: %v", logs)
+	}
+	if rejected := server.Rejected(); len(rejected) != 2 {
+		t.Errorf("Expected 2 rejected requests, Actual: %v", rejected)
+	}
+
+	unchecked := NewServer(Options{})
+	defer unchecked.Close()
+	client := &slsSdk.Client{Endpoint: unchecked.Endpoint, AccessKeyID: "any", AccessKeySecret: "any"}
+	if err := client.PutLogs("project", "traces", testLogGroup("accepted")); err != nil {
+		t.Errorf("Expected the signature not to be checked, Actual: %v", err)
+	}
+}
+
+// put sends a PutLogs request as the SDK does, with a compression the SDK does not support.
+func put(t *testing.T, server *Server, body []byte, headers map[string]string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, "http://"+server.Endpoint+"/logstores/traces", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "project." + server.Endpoint
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-MD5", fmt.Sprintf("%X", md5.Sum(body)))
+	req.Header.Set("Date", "Tue, 02 Aug 2022 03:05:34 GMT")
+	req.Header.Set("x-log-apiversion", "0.6.0")
+	req.Header.Set("x-log-signaturemethod", "hmac-sha1")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Authorization", "LOG test-id:"+signature("test-secret", req))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestServerDecodesTheBody(t *testing.T) {
+	server := NewServer(Options{AccessKeys: testAccessKeys})
+	defer server.Close()
+	raw, _ := proto.Marshal(testLogGroup("deflate"))
+	var deflated bytes.Buffer
+	writer := zlib.NewWriter(&deflated)
+	_, _ = writer.Write(raw)
+	_ = writer.Close()
+	size := strconv.Itoa(len(raw))
+
+	if resp := put(t, server, deflated.Bytes(), map[string]string{"x-log-compresstype": "deflate", "x-log-bodyrawsize": size}); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the deflate body to be accepted, Actual: %s %v", resp.Status, server.Rejected())
+	}
+	if actual := fmt.Sprint(names(server.Logs("project", "traces"))); actual != "[deflate]" {
+		t.Errorf("Expected the deflate log, Actual: %s", actual)
+	}
+
+	invalid := []map[string]string{
+		{"x-log-compresstype": "deflate", "x-log-bodyrawsize": "1"},
+		{"x-log-compresstype": "zstd", "x-log-bodyrawsize": size},
+		{"x-log-compresstype": "lz4", "x-log-bodyrawsize": size},
+		{"x-log-bodyrawsize": size},
+		{"x-log-compresstype": "deflate"},
+	}
+	for _, headers := range invalid {
+		if resp := put(t, server, deflated.Bytes(), headers); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %v to be rejected, Actual: %s", headers, resp.Status)
+		}
+	}
+	if resp := put(t, server, []byte("not a log group"), map[string]string{"x-log-bodyrawsize": "15"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an invalid log group to be rejected, Actual: %s", resp.Status)
+	}
+	if logs := server.Logs("project", "traces"); len(logs) != 1 {
+		t.Errorf("Expected only the deflate log, Actual: %d logs", len(logs))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.uber.org/zap"
+)
+
+// sources polls the kafka sources concurrently, their messages and their drain
+// requests are handled one at a time by the consuming loop.
+type sources struct {
+	sources  []*receiver.Source
+	messages chan receiver.SourceMessage
+	drains   chan chan struct{}
+	// stopped is closed once the consuming loop no longer serves the drain requests.
+	stopped chan struct{}
+}
+
+func startSources(config *configure.Configuration, sugar *zap.SugaredLogger) (*sources, error) {
+	s := &sources{
+		messages: make(chan receiver.SourceMessage),
+		drains:   make(chan chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, definition := range config.EffectiveSources() {
+		source, err := receiver.NewSource(config, definition, s.drain, sugar)
+		if err != nil {
+			close(s.stopped)
+			s.close()
+			return nil, err
+		}
+		s.sources = append(s.sources, source)
+	}
+	for _, source := range s.sources {
+		source.Start(s.messages)
+	}
+	return s, nil
+}
+
+// drain is called by a source before its partitions are revoked. The spans
+// consumed from them may be buffered anywhere in the pipelines, so the consuming
+// loop flushes all of them before the offsets are committed.
+func (s *sources) drain(partitions []receiver.Partition) {
+	done := make(chan struct{})
+	select {
+	case s.drains <- done:
+		<-done
+	case <-s.stopped:
+		// the shutdown flushes the pipelines before leaving the consumer groups
+	}
+}
+
+// stop stops polling the sources, the messages polled meanwhile are still handled.
+func (s *sources) stop(handle func(receiver.SourceMessage), drain func()) {
+	for _, source := range s.sources {
+		source.Stop()
+	}
+	for _, source := range s.sources {
+		for stopping := true; stopping; {
+			select {
+			case msg := <-s.messages:
+				handle(msg)
+			case done := <-s.drains:
+				drain()
+				close(done)
+			case <-source.Done():
+				stopping = false
+			}
+		}
+	}
+	close(s.stopped)
+}
+
+// close commits the offsets of the sources and leaves their consumer groups.
+func (s *sources) close() {
+	for _, source := range s.sources {
+		source.Close()
+	}
+}
+
+// abandon forgets the offsets of the handled messages, so that close leaves
+// the consumer groups without committing them.
+func (s *sources) abandon() {
+	for _, source := range s.sources {
+		source.Abandon()
+	}
+}
+
+// committer commits the offsets of the handled messages once their spans are
+// exported. The buffers are not flushed for it: a checkpoint is taken at a tick
+// and committed at a later one, once the spans buffered when it was taken are
+// released and delivered.
+type committer struct {
+	sources *sources
+	// retiring counts the replaced pipelines which are still flushing, the spans
+	// handed to them are not covered by the checkpoints of the current one.
+	retiring func() int
+	offsets  [][]receiver.Offset
+	exported func() bool
+}
+
+// tick commits the pending checkpoint if its spans are exported, then takes the next one.
+func (c *committer) tick(current *pipeline) {
+	if c.retiring() > 0 {
+		return
+	}
+	if c.exported != nil {
+		if !c.exported() {
+			return
+		}
+		for i, source := range c.sources.sources {
+			source.Commit(c.offsets[i])
+		}
+	}
+	c.offsets = make([][]receiver.Offset, len(c.sources.sources))
+	for i, source := range c.sources.sources {
+		c.offsets[i] = source.Checkpoint()
+	}
+	c.exported = current.checkpoint()
+}
+
+func (s *sources) controller() receiver.Controller {
+	return receiver.NewSourcesController(s.sources)
+}
@@ -0,0 +1,151 @@
+// Package telemetry traces the ingester's own pipeline: every consumed message
+// becomes a trace with a span for each of the poll, decode, convert and export stages.
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/exporter"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	DefaultServiceName = "zipkin-ingester"
+	DefaultSampleRatio = 0.01
+
+	SpanMessage = "process message"
+	StagePoll   = "poll"
+	StageDecode = "decode"
+	// StageProcess covers the processors, which normalize, limit and de-duplicate the spans.
+	StageProcess = "process"
+	// StageExport covers the conversion of the spans to the format of the
+	// destinations and their sending.
+	StageExport = "export"
+
+	AttributeSpanCount = attribute.Key("zipkin.span_count")
+	AttributeOffset    = attribute.Key("messaging.kafka.offset")
+)
+
+// Tracer creates the traces of the consumed messages, it is a no-op if the self tracing is disabled.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// New creates the tracer of the configuration. The spans are sent to the OTLP
+// endpoint of the self tracing, or to the trace instance of the configuration if it is empty.
+func New(config *configure.Configuration) (*Tracer, error) {
+	if !config.SelfTracingEnabled {
+		return &Tracer{tracer: trace.NewNoopTracerProvider().Tracer(DefaultServiceName)}, nil
+	}
+
+	var client otlptrace.Client
+	if config.SelfTracingEndpoint == "" {
+		client = exporter.NewSlsTraceClient(config)
+	} else {
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.SelfTracingEndpoint)}
+		if config.SelfTracingInsecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		} else {
+			options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+		}
+		client = otlptracegrpc.NewClient(options...)
+	}
+	spanExporter, err := otlptrace.New(context.Background(), client)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := config.SelfTracingService
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	return newTracer(sdktrace.WithBatcher(spanExporter), serviceName, config.SelfTracingSampling), nil
+}
+
+func newTracer(processor sdktrace.TracerProviderOption, serviceName string, ratio float64) *Tracer {
+	provider := sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	return &Tracer{provider: provider, tracer: provider.Tracer(DefaultServiceName)}
+}
+
+// StartMessage starts the trace of a message polled since pollStart, the poll span is already ended.
+func (t *Tracer) StartMessage(pollStart time.Time, msg *receiver.Message) *MessageTrace {
+	ctx, root := t.tracer.Start(context.Background(), SpanMessage,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(pollStart),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKey.String(msg.Topic),
+			semconv.MessagingKafkaPartitionKey.Int64(int64(msg.Partition)),
+			AttributeOffset.Int64(msg.Offset),
+			semconv.MessagingMessagePayloadSizeBytesKey.Int(len(msg.Value)),
+		),
+	)
+	_, poll := t.tracer.Start(ctx, StagePoll, trace.WithTimestamp(pollStart))
+	poll.End()
+	return &MessageTrace{ctx: ctx, root: root, tracer: t.tracer}
+}
+
+// Close exports the ended spans.
+func (t *Tracer) Close(timeout time.Duration) error {
+	if t.provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return t.provider.Shutdown(ctx)
+}
+
+// MessageTrace is the trace of one message, its stages are children of the message span.
+type MessageTrace struct {
+	ctx    context.Context
+	root   trace.Span
+	tracer trace.Tracer
+	err    error
+}
+
+// Start starts the span of a stage.
+func (m *MessageTrace) Start(stage string) *Stage {
+	_, span := m.tracer.Start(m.ctx, stage)
+	return &Stage{span: span, message: m}
+}
+
+// End ends the message span, it is failed if any of its stages failed.
+func (m *MessageTrace) End() {
+	if m.err != nil {
+		m.root.SetStatus(codes.Error, m.err.Error())
+	}
+	m.root.End()
+}
+
+// Stage is the span of a stage of the message.
+type Stage struct {
+	span    trace.Span
+	message *MessageTrace
+}
+
+// End records the count of spans the stage output and its error.
+func (s *Stage) End(spanCount int, err error) {
+	s.span.SetAttributes(AttributeSpanCount.Int(spanCount))
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+		s.message.err = err
+	}
+	s.span.End()
+}
@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+func TestMessageTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newTracer(sdktrace.WithSyncer(exporter), "ingester-self", 1)
+
+	pollStart := time.Now().Add(-time.Second)
+	trace := tracer.StartMessage(pollStart, &receiver.Message{Topic: "zipkin", Partition: 3, Offset: 42, Value: make([]byte, 128)})
+	trace.Start(StageDecode).End(5, nil)
+	trace.Start(StageProcess).End(4, nil)
+	trace.Start(StageExport).End(4, errors.New("sls unavailable"))
+	trace.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 5 {
+		t.Fatalf("expected 5 spans, got %d", len(spans))
+	}
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	root := byName[SpanMessage]
+	if !root.StartTime.Equal(pollStart) || root.Status.Code != codes.Error {
+		t.Errorf("unexpected message span %+v", root)
+	}
+	if service, _ := root.Resource.Set().Value(semconv.ServiceNameKey); service.AsString() != "ingester-self" {
+		t.Errorf("unexpected service name %v", service)
+	}
+	attrs := attribute.NewSet(root.Attributes...)
+	if partition, _ := attrs.Value(semconv.MessagingKafkaPartitionKey); partition.AsInt64() != 3 {
+		t.Errorf("unexpected partition %v", partition)
+	}
+	if size, _ := attrs.Value(semconv.MessagingMessagePayloadSizeBytesKey); size.AsInt64() != 128 {
+		t.Errorf("unexpected message size %v", size)
+	}
+
+	for _, stage := range []string{StagePoll, StageDecode, StageProcess, StageExport} {
+		span, ok := byName[stage]
+		if !ok {
+			t.Fatalf("missing the %s span", stage)
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("the %s span is not a child of the message span", stage)
+		}
+	}
+	decodeAttrs := attribute.NewSet(byName[StageDecode].Attributes...)
+	if count, _ := decodeAttrs.Value(AttributeSpanCount); count.AsInt64() != 5 {
+		t.Errorf("unexpected span count %v", count)
+	}
+	if export := byName[StageExport]; export.Status.Code != codes.Error || len(export.Events) != 1 {
+		t.Errorf("the export error is not recorded: %+v", export)
+	}
+}
+
+func TestMessageTraceSampling(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newTracer(sdktrace.WithSyncer(exporter), DefaultServiceName, 0)
+
+	trace := tracer.StartMessage(time.Now(), &receiver.Message{Value: []byte("{}")})
+	trace.Start(StageDecode).End(1, nil)
+	trace.End()
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("expected no sampled span, got %d", len(spans))
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.uber.org/zap"
+)
+
+// throttle pauses the partitions throttled by the rate limit in block mode and
+// resumes them once their delay elapsed, so that the consuming loop keeps serving
+// the other partitions, the drains and the signals meanwhile. Only the partitions
+// it paused itself are resumed, the pauses of the admin API are left alone.
+type throttle struct {
+	// sources are keyed by name
+	sources map[string]*receiver.Source
+	sugar   *zap.SugaredLogger
+	paused  map[processor.Origin]time.Time
+	timer   *time.Timer
+}
+
+func newThrottle(sources []*receiver.Source, sugar *zap.SugaredLogger) *throttle {
+	t := &throttle{
+		sources: make(map[string]*receiver.Source, len(sources)),
+		sugar:   sugar,
+		paused:  make(map[processor.Origin]time.Time),
+	}
+	for _, source := range sources {
+		t.sources[source.Name] = source
+	}
+	return t
+}
+
+// pause pauses the partitions throttled by the processors until the given times.
+func (t *throttle) pause(throttled map[processor.Origin]time.Time) {
+	if len(throttled) == 0 {
+		return
+	}
+	for origin, until := range throttled {
+		if _, ok := t.paused[origin]; !ok {
+			source, ok := t.sources[origin.Source]
+			if !ok {
+				continue
+			}
+			if err := source.Throttle(origin.Topic, origin.Partition); err != nil {
+				if !errors.Is(err, receiver.ErrNotAssigned) {
+					t.sugar.Warnw("Failed to pause the throttled partition.", "topic", origin.Topic, "partition", origin.Partition, "exception", err)
+				}
+				continue
+			}
+		}
+		if until.After(t.paused[origin]) {
+			t.paused[origin] = until
+		}
+	}
+	t.schedule()
+}
+
+// resume resumes the partitions whose delay elapsed.
+func (t *throttle) resume(now time.Time) {
+	for origin, until := range t.paused {
+		if until.After(now) {
+			continue
+		}
+		delete(t.paused, origin)
+		if err := t.sources[origin.Source].Unthrottle(origin.Topic, origin.Partition); err != nil && !errors.Is(err, receiver.ErrNotAssigned) {
+			t.sugar.Warnw("Failed to resume the throttled partition.", "topic", origin.Topic, "partition", origin.Partition, "exception", err)
+		}
+	}
+	t.schedule()
+}
+
+// expired fires when the next paused partition must be resumed.
+func (t *throttle) expired() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+func (t *throttle) schedule() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	var next time.Time
+	for _, until := range t.paused {
+		if next.IsZero() || until.Before(next) {
+			next = until
+		}
+	}
+	if !next.IsZero() {
+		t.timer = time.NewTimer(time.Until(next))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aliyun-sls/zipkin-ingester/configure"
+	"github.com/aliyun-sls/zipkin-ingester/processor"
+	"github.com/aliyun-sls/zipkin-ingester/receiver"
+	"go.uber.org/zap"
+)
+
+// throttledIngester records the partitions throttled and resumed.
+type throttledIngester struct {
+	receiver.Ingester
+	calls []string
+}
+
+func (f *throttledIngester) Throttle(topic string, partition int32) error {
+	f.calls = append(f.calls, fmt.Sprintf("pause %s/%d", topic, partition))
+	if topic == "revoked" {
+		return fmt.Errorf("the topic %s %w", topic, receiver.ErrNotAssigned)
+	}
+	return nil
+}
+
+func (f *throttledIngester) Unthrottle(topic string, partition int32) error {
+	f.calls = append(f.calls, fmt.Sprintf("resume %s/%d", topic, partition))
+	return nil
+}
+
+func TestThrottlePausesThePartitionsUntilTheirDelayElapsed(t *testing.T) {
+	sugar := zap.NewNop().Sugar()
+	kafka, other := &throttledIngester{}, &throttledIngester{}
+	throttler := newThrottle([]*receiver.Source{
+		receiver.NewSourceWithIngester(configure.Source{Name: "kafka"}, kafka, sugar),
+		receiver.NewSourceWithIngester(configure.Source{Name: "other"}, other, sugar),
+	}, sugar)
+	now := time.Now()
+	zipkin := processor.Origin{Source: "kafka", Topic: "zipkin", Partition: 1}
+	revoked := processor.Origin{Source: "kafka", Topic: "revoked", Partition: 0}
+
+	throttler.pause(map[processor.Origin]time.Time{zipkin: now.Add(time.Second), revoked: now.Add(time.Second)})
+	throttler.pause(map[processor.Origin]time.Time{zipkin: now.Add(2 * time.Second)})
+	if expected := []string{"pause revoked/0", "pause zipkin/1"}; !reflect.DeepEqual(sorted(kafka.calls), expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, kafka.calls)
+	}
+	if len(other.calls) != 0 {
+		t.Errorf("Expected only the source of the spans to be paused, Actual: %v", other.calls)
+	}
+	if throttler.expired() == nil {
+		t.Fatal("Expected a timer to resume the partition")
+	}
+
+	kafka.calls = nil
+	throttler.resume(now.Add(time.Second))
+	if len(kafka.calls) != 0 {
+		t.Errorf("Expected the pause to be extended, Actual: %v", kafka.calls)
+	}
+	throttler.resume(now.Add(2 * time.Second))
+	if expected := []string{"resume zipkin/1"}; !reflect.DeepEqual(kafka.calls, expected) {
+		t.Errorf("Expected %v, Actual: %v", expected, kafka.calls)
+	}
+	if throttler.expired() != nil {
+		t.Error("Expected no timer once every partition is resumed")
+	}
+}
+
+func sorted(values []string) []string {
+	result := append([]string(nil), values...)
+	sort.Strings(result)
+	return result
+}